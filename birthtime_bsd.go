@@ -0,0 +1,25 @@
+//go:build darwin || freebsd || netbsd
+// +build darwin freebsd netbsd
+
+package dirtree
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+)
+
+// birthTime returns the creation time of the file described by fi, if known.
+// fullpath is the path of the file, onOS reports whether it's a path of the
+// OS filesystem.
+func birthTime(fi fs.FileInfo, fullpath string, onOS bool) (time.Time, bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return time.Time{}, false
+	}
+	sec, nsec := st.Birthtimespec.Unix()
+	if sec <= 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(sec, nsec), true
+}
@@ -0,0 +1,84 @@
+package dirtree
+
+import (
+	"io/fs"
+	"runtime"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// statx system call numbers, which aren't provided by the syscall package.
+var sysStatx = map[string]uintptr{
+	"386":      383,
+	"amd64":    332,
+	"arm":      397,
+	"arm64":    291,
+	"loong64":  291,
+	"mips":     4366,
+	"mipsle":   4366,
+	"mips64":   5326,
+	"mips64le": 5326,
+	"ppc64":    383,
+	"ppc64le":  383,
+	"riscv64":  291,
+	"s390x":    379,
+}[runtime.GOARCH]
+
+const (
+	atFdcwd           = -0x64
+	atSymlinkNofollow = 0x100
+	statxBtime        = 0x800
+)
+
+type statxTimestamp struct {
+	Sec  int64
+	Nsec uint32
+	_    int32
+}
+
+// statxT mirrors struct statx, see statx(2).
+type statxT struct {
+	Mask           uint32
+	Blksize        uint32
+	Attributes     uint64
+	Nlink          uint32
+	Uid            uint32
+	Gid            uint32
+	Mode           uint16
+	_              uint16
+	Ino            uint64
+	Size           uint64
+	Blocks         uint64
+	AttributesMask uint64
+	Atime          statxTimestamp
+	Btime          statxTimestamp
+	Ctime          statxTimestamp
+	Mtime          statxTimestamp
+	_              [128]byte
+}
+
+// birthTime returns the creation time of the file described by fi, if known.
+// fullpath is the path of the file, onOS reports whether it's a path of the
+// OS filesystem.
+//
+// On Linux, the creation time is obtained with statx(2), which requires the
+// file path on the OS filesystem and Linux 4.11.
+func birthTime(fi fs.FileInfo, fullpath string, onOS bool) (time.Time, bool) {
+	if !onOS || sysStatx == 0 {
+		return time.Time{}, false
+	}
+	p, err := syscall.BytePtrFromString(fullpath)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	var stx statxT
+	dirfd := atFdcwd
+	_, _, errno := syscall.Syscall6(sysStatx, uintptr(dirfd), uintptr(unsafe.Pointer(p)),
+		atSymlinkNofollow, statxBtime, uintptr(unsafe.Pointer(&stx)), 0)
+	if errno != 0 || stx.Mask&statxBtime == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(stx.Btime.Sec, int64(stx.Btime.Nsec)), true
+}
@@ -0,0 +1,16 @@
+//go:build !darwin && !freebsd && !netbsd && !linux && !windows
+// +build !darwin,!freebsd,!netbsd,!linux,!windows
+
+package dirtree
+
+import (
+	"io/fs"
+	"time"
+)
+
+// birthTime returns the creation time of the file described by fi, if known.
+// fullpath is the path of the file, onOS reports whether it's a path of the
+// OS filesystem.
+func birthTime(fi fs.FileInfo, fullpath string, onOS bool) (time.Time, bool) {
+	return time.Time{}, false
+}
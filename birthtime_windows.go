@@ -0,0 +1,18 @@
+package dirtree
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+)
+
+// birthTime returns the creation time of the file described by fi, if known.
+// fullpath is the path of the file, onOS reports whether it's a path of the
+// OS filesystem.
+func birthTime(fi fs.FileInfo, fullpath string, onOS bool) (time.Time, bool) {
+	data, ok := fi.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(0, data.CreationTime.Nanoseconds()), true
+}
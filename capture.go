@@ -0,0 +1,42 @@
+package dirtree
+
+import (
+	"fmt"
+	"io/fs"
+)
+
+// A Tree is a listing of a directory tree, indexed by relative path.
+type Tree map[string]*Entry
+
+// Capture walks the directory rooted at root in the given filesystem, or in the
+// OS filesystem if fsys is nil, and returns the listed entries indexed by their
+// relative path.
+//
+// Since Entry implements an Equal method, trees can directly be compared with
+// github.com/google/go-cmp, which reports the added, removed and modified
+// entries:
+//
+//	a, _ := dirtree.Capture(nil, "got", dirtree.ModeAll)
+//	b, _ := dirtree.Capture(nil, "want", dirtree.ModeAll)
+//	if diff := cmp.Diff(b, a); diff != "" {
+//		t.Errorf("trees differ (-want +got):\n%s", diff)
+//	}
+func Capture(fsys fs.FS, root string, opts ...Option) (Tree, error) {
+	cfg, err := newConfig(opts)
+	if err != nil {
+		return nil, fmt.Errorf("dirtree: %w", err)
+	}
+
+	var slab entrySlab
+	tree := make(Tree)
+	err = walkTree(root, fsys, &cfg, func(ent *Entry) error {
+		e := slab.new()
+		*e = *ent
+		tree[e.RelPath] = e
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("dirtree: %w", err)
+	}
+	return tree, nil
+}
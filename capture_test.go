@@ -0,0 +1,43 @@
+package dirtree
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestCapture(t *testing.T) {
+	a := fstest.MapFS{
+		"dir/file": &fstest.MapFile{Data: []byte("hello")},
+		"other":    &fstest.MapFile{Data: []byte("world")},
+	}
+	b := fstest.MapFS{
+		"sub/dir/file": &fstest.MapFile{Data: []byte("hello")},
+		"sub/other":    &fstest.MapFile{Data: []byte("World")},
+	}
+
+	ta, err := Capture(a, ".", ModeAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tb, err := Capture(b, "sub", ModeAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ta) != 4 || len(tb) != 4 {
+		t.Fatalf("got %d and %d entries, want 4", len(ta), len(tb))
+	}
+	for _, rel := range []string{".", "dir", "dir/file"} {
+		if !ta[rel].Equal(tb[rel]) {
+			t.Errorf("%s: %q != %q", rel, ta[rel], tb[rel])
+		}
+	}
+	if ta["other"].Equal(tb["other"]) {
+		t.Errorf("other: Equal() = true for different checksums")
+	}
+
+	var nilEntry *Entry
+	if !nilEntry.Equal(nil) || nilEntry.Equal(ta["."]) || ta["."].Equal(nil) {
+		t.Errorf("Equal() with nil entries is wrong")
+	}
+}
@@ -0,0 +1,87 @@
+package dirtree
+
+import (
+	"io"
+	"sort"
+	"strings"
+)
+
+// A Changeset describes the differences between two listings of the same tree,
+// for example taken at different times.
+type Changeset struct {
+	Added    []*Entry // Added holds the entries only present in the new listing
+	Removed  []*Entry // Removed holds the entries only present in the old listing
+	Modified []*Entry // Modified holds the new state of the entries which changed
+}
+
+// NewChangeset returns the changes between the old and cur listings, as
+// returned by List. Entries are identified by their relative path, and
+// considered modified when their summary string (see Entry.Format) differs,
+// so both listings should have been taken with the same options.
+//
+// Each slice of the returned Changeset is in listing order.
+func NewChangeset(old, cur []*Entry) Changeset {
+	var cs Changeset
+	for _, ev := range diffEntries(old, cur) {
+		switch ev.Op {
+		case Added:
+			cs.Added = append(cs.Added, ev.Entry)
+		case Removed:
+			cs.Removed = append(cs.Removed, ev.Entry)
+		case Modified:
+			cs.Modified = append(cs.Modified, ev.Entry)
+		}
+	}
+	return cs
+}
+
+// Empty reports whether cs has no changes.
+func (cs Changeset) Empty() bool {
+	return len(cs.Added) == 0 && len(cs.Removed) == 0 && len(cs.Modified) == 0
+}
+
+// changesetPrefixes are the line prefixes of the text form of a Changeset.
+var changesetPrefixes = [...]string{
+	Added:    "+ ",
+	Removed:  "- ",
+	Modified: "~ ",
+}
+
+// WriteTo writes the text form of cs to w: one line per change, sorted by
+// relative path, made of '+', '-' or '~' for added, removed and modified
+// entries, followed by a space and the entry line, as printed by Write. For a
+// given Changeset, the output is always the same.
+func (cs Changeset) WriteTo(w io.Writer) (int64, error) {
+	evs := make([]Event, 0, len(cs.Added)+len(cs.Removed)+len(cs.Modified))
+	for _, e := range cs.Removed {
+		evs = append(evs, Event{Op: Removed, Entry: e})
+	}
+	for _, e := range cs.Added {
+		evs = append(evs, Event{Op: Added, Entry: e})
+	}
+	for _, e := range cs.Modified {
+		evs = append(evs, Event{Op: Modified, Entry: e})
+	}
+	sort.SliceStable(evs, func(i, j int) bool {
+		if evs[i].Entry.RelPath != evs[j].Entry.RelPath {
+			return evs[i].Entry.RelPath < evs[j].Entry.RelPath
+		}
+		return evs[i].Op > evs[j].Op
+	})
+
+	var buf []byte
+	for _, ev := range evs {
+		buf = append(buf, changesetPrefixes[ev.Op]...)
+		buf = ev.Entry.appendLine(buf)
+		buf = append(buf, '\n')
+	}
+	n, err := w.Write(buf)
+	return int64(n), err
+}
+
+// String returns the text form of cs, as written by WriteTo.
+func (cs Changeset) String() string {
+	var sb strings.Builder
+	cs.WriteTo(&sb)
+	return sb.String()
+}
@@ -0,0 +1,48 @@
+package dirtree
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestChangeset(t *testing.T) {
+	old := fstest.MapFS{
+		"a":       &fstest.MapFile{Data: []byte("a")},
+		"b/file":  &fstest.MapFile{Data: []byte("b")},
+		"c":       &fstest.MapFile{Data: []byte("c")},
+		"removed": &fstest.MapFile{},
+	}
+	cur := fstest.MapFS{
+		"a":      &fstest.MapFile{Data: []byte("a")},
+		"b/file": &fstest.MapFile{Data: []byte("bb")},
+		"b/new":  &fstest.MapFile{},
+		"c":      &fstest.MapFile{Data: []byte("C")},
+	}
+
+	list := func(fsys fstest.MapFS) []*Entry {
+		t.Helper()
+		ents, err := ListFS(fsys, ".", ModeAll, ExcludeRoot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ents
+	}
+
+	cs := NewChangeset(list(old), list(cur))
+	if len(cs.Added) != 1 || len(cs.Removed) != 1 || len(cs.Modified) != 2 {
+		t.Fatalf("got %d added, %d removed, %d modified, want 1, 1, 2",
+			len(cs.Added), len(cs.Removed), len(cs.Modified))
+	}
+
+	want := "~ f 2b         crc=b5ae1bae b/file\n" +
+		"+ f 0b         crc=00000000 b/new\n" +
+		"~ f 1b         crc=3dd7ffa7 c\n" +
+		"- f 0b         crc=00000000 removed\n"
+	if got := cs.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	if cs := NewChangeset(list(cur), list(cur)); !cs.Empty() {
+		t.Errorf("Empty() = false for identical listings, got:\n%s", cs)
+	}
+}
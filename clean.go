@@ -0,0 +1,250 @@
+package dirtree
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	errChangedSincePlan = errors.New("file changed since the plan")
+	errSymlinkInPath    = errors.New("path goes through a symbolic link")
+)
+
+// A CleanPlan holds the files to delete from a directory tree, as planned by
+// PlanClean. It's meant to be reviewed, printed with WriteTo for example,
+// before being applied.
+type CleanPlan struct {
+	Root    string   // Root is the root of the walked tree
+	Entries []*Entry // Entries are the files to delete, in listing order
+}
+
+// PlanClean walks the directory rooted at root and returns the plan deleting
+// the listed files, at the exception of the root. Directories are deleted with
+// all their content, so the files of planned directories are not part of the
+// plan. ModeType and ModeSize are always set, Apply checking them.
+//
+// To prevent deleting whole trees by mistake, at least one Match pattern must
+// be provided, or PlanClean returns an error wrapping ErrInvalidOption. Ignore
+// and Prune patterns then protect files from deletion, Prune also protecting
+// the content of directories:
+//
+//	plan, err := dirtree.PlanClean("src", dirtree.Match("*/*.o"), dirtree.Match("*/build/"), dirtree.Prune("vendor/"))
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	plan.WriteTo(os.Stdout)
+//	// ask for confirmation
+//	err = plan.Apply()
+//
+// Protected files are also protected inside planned directories: a directory
+// having protected files below it is kept, and its other files are planned one
+// by one instead.
+//
+// FollowSymlinks can't be used, since files reached through links to
+// directories may be outside of root.
+func PlanClean(root string, opts ...Option) (*CleanPlan, error) {
+	cfg, err := newConfig(opts)
+	if err != nil {
+		return nil, fmt.Errorf("dirtree: %w", err)
+	}
+	hasMatch := false
+	for _, p := range cfg.globs {
+		hasMatch = hasMatch || p.moi == match
+	}
+	if !hasMatch {
+		return nil, fmt.Errorf("dirtree: %w: PlanClean requires a Match pattern", ErrInvalidOption)
+	}
+	if cfg.follow {
+		return nil, fmt.Errorf("dirtree: %w: PlanClean can't follow symbolic links", ErrInvalidOption)
+	}
+	cfg.mode |= ModeType | ModeSize
+
+	ents, err := listTree(nil, root, nil, &cfg)
+	if err != nil {
+		return nil, fmt.Errorf("dirtree: %w", err)
+	}
+
+	var (
+		kept     map[string]bool // directories having protected files below them
+		unwanted []*Entry        // files not protected, in listing order
+		index    map[string]int  // index of the unwanted files, by RelPath
+	)
+	for _, e := range ents {
+		if e.Type == Dir && e.RelPath != "." {
+			if kept, unwanted, err = protectedDirs(root, &cfg); err != nil {
+				return nil, fmt.Errorf("dirtree: %w", err)
+			}
+			index = make(map[string]int, len(unwanted))
+			for i, u := range unwanted {
+				index[u.RelPath] = i
+			}
+			break
+		}
+	}
+
+	plan := &CleanPlan{Root: root}
+	var dir string // last planned, or kept, directory
+	for _, e := range ents {
+		if e.RelPath == "." || dir != "" && strings.HasPrefix(e.RelPath, dir+"/") {
+			continue
+		}
+		if e.Type != Dir {
+			plan.Entries = append(plan.Entries, e)
+			continue
+		}
+		dir = e.RelPath
+		if !kept[dir] {
+			plan.Entries = append(plan.Entries, e)
+			continue
+		}
+		// Plan the unprotected content of the kept directory.
+		i, ok := index[dir]
+		if !ok {
+			continue
+		}
+		var sub string // last planned directory below dir
+		for _, u := range unwanted[i+1:] {
+			if !strings.HasPrefix(u.RelPath, dir+"/") {
+				break
+			}
+			if sub != "" && strings.HasPrefix(u.RelPath, sub+"/") || kept[u.RelPath] {
+				continue
+			}
+			if u.Type == Dir {
+				sub = u.RelPath
+			}
+			plan.Entries = append(plan.Entries, u)
+		}
+	}
+	return plan, nil
+}
+
+// protectedDirs walks the directory rooted at root twice: as is, and with the
+// options of cfg protecting files from deletion. It returns the directories
+// having files below them which are only found by the former, and the files
+// found by the latter.
+func protectedDirs(root string, cfg *config) (map[string]bool, []*Entry, error) {
+	all := defaultCfg
+	all.mode = ModeType
+	paths, err := listTree(nil, root, nil, &all)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	unprotected := *cfg
+	unprotected.globs = nil
+	for _, p := range cfg.globs {
+		if p.moi != match {
+			unprotected.globs = append(unprotected.globs, p)
+		}
+	}
+	unprotected.mode = ModeType | ModeSize
+	unprotected.showRoot = true
+	unprotected.types = defaultCfg.types
+	unprotected.depth = defaultCfg.depth
+	unprotected.cursor = ""
+	unprotected.limit = 0
+	unprotected.stats = nil
+	unprotected.collisions = nil
+	unprotected.loops = nil
+	unprotected.format.hashes = nil
+	unprotected.dirSums = false
+	unprotected.markEmpty = false
+	ents, err := listTree(nil, root, nil, &unprotected)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	listed := make(map[string]bool, len(ents))
+	for _, e := range ents {
+		listed[e.RelPath] = true
+	}
+	kept := make(map[string]bool)
+	for _, e := range paths {
+		if listed[e.RelPath] {
+			continue
+		}
+		for dir := path.Dir(e.RelPath); dir != "." && !kept[dir]; dir = path.Dir(dir) {
+			kept[dir] = true
+		}
+		if e.Type == Dir {
+			kept[e.RelPath] = true
+		}
+	}
+	return kept, ents, nil
+}
+
+// WriteTo writes the files of p to w, one per line, as printed by Write.
+func (p *CleanPlan) WriteTo(w io.Writer) (int64, error) {
+	return Entries(p.Entries).WriteTo(w)
+}
+
+// Apply deletes the files of p, in order. Before being deleted, each file is
+// checked to still have the planned type and, for regular files, size, and to
+// be reached from Root without going through symbolic links. Otherwise, or in
+// case of error, Apply stops and returns an error. Files already deleted are
+// skipped.
+func (p *CleanPlan) Apply() error {
+	for _, e := range p.Entries {
+		gone, err := p.symlinkInPath(e.RelPath)
+		if gone {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("dirtree: %w", &WalkError{Path: e.Path, Err: err})
+		}
+		path := filepath.Join(p.Root, filepath.FromSlash(e.RelPath))
+		fi, err := os.Lstat(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("dirtree: %w", &WalkError{Path: e.Path, Err: err})
+		}
+		if ft := filetypeFromDirEntry(infoDirEntry{fi}); ft != e.Type || ft == File && fi.Size() != e.Size {
+			return fmt.Errorf("dirtree: %w", &WalkError{Path: e.Path, Err: errChangedSincePlan})
+		}
+		if e.Type == Dir {
+			err = os.RemoveAll(path)
+		} else {
+			err = os.Remove(path)
+		}
+		if err != nil {
+			return fmt.Errorf("dirtree: %w", &WalkError{Path: e.Path, Err: err})
+		}
+	}
+	return nil
+}
+
+// symlinkInPath returns errSymlinkInPath if one of the parent directories of
+// rel, below Root, is a symbolic link. gone reports whether one of them
+// doesn't exist anymore.
+func (p *CleanPlan) symlinkInPath(rel string) (gone bool, err error) {
+	if !fs.ValidPath(rel) || rel == "." {
+		return false, errChangedSincePlan
+	}
+	var dirs []string
+	for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+		dirs = append(dirs, dir)
+	}
+	// From Root down, so that links are found before what they point to.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		fi, err := os.Lstat(filepath.Join(p.Root, filepath.FromSlash(dirs[i])))
+		if os.IsNotExist(err) {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if fi.Mode()&fs.ModeSymlink != 0 {
+			return false, errSymlinkInPath
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,162 @@
+package dirtree
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestClean(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.o", "a.c", "sub/b.o", "sub/b.c", "build/x", "build/y.o", "vendor/c.o"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := PlanClean(root, Ignore("*.c")); !errors.Is(err, ErrInvalidOption) {
+		t.Fatalf("PlanClean without Match: error = %v, want ErrInvalidOption", err)
+	}
+
+	plan, err := PlanClean(root, ModeType, Match("*.o"), Match("build/"), Prune("vendor/"), MatchBase(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sb strings.Builder
+	if _, err := plan.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	want := `f 3b         a.o
+d            build
+f 7b         sub/b.o
+`
+	if sb.String() != want {
+		t.Errorf("plan:\n%s\nwant:\n%s", sb.String(), want)
+	}
+
+	if err := plan.Apply(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Sprint(root, ModeType, ExcludeRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = `f a.c
+d sub
+f sub/b.c
+d vendor
+f vendor/c.o
+`
+	if got != want {
+		t.Errorf("after Apply:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Files changed since the plan aren't deleted.
+	if err := os.WriteFile(filepath.Join(root, "a.o"), []byte("new"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	plan, err = PlanClean(root, Match("*.o"), MatchBase(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "a.o"), []byte("changed"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := plan.Apply(); !errors.Is(err, errChangedSincePlan) {
+		t.Errorf("Apply: error = %v, want errChangedSincePlan", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "a.o")); err != nil {
+		t.Errorf("changed file deleted: %v", err)
+	}
+}
+
+func TestCleanProtectedInMatchedDir(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"build/keep/precious", "build/x.keep", "build/x.o", "build/sub/y.o", "build/sub/z.keep", "build/obj/z.o", "other/build/a.o"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	plan, err := PlanClean(root, ModeType, MatchBase(true), Match("build"), Prune("keep"), Prune("*.keep"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sb strings.Builder
+	if _, err := plan.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	want := `d            build/obj
+f 13b        build/sub/y.o
+f 9b         build/x.o
+d            other/build
+`
+	if sb.String() != want {
+		t.Errorf("plan:\n%s\nwant:\n%s", sb.String(), want)
+	}
+
+	if err := plan.Apply(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Sprint(root, ModeType, ExcludeRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = `d build
+d build/keep
+f build/keep/precious
+d build/sub
+f build/sub/z.keep
+f build/x.keep
+d other
+`
+	if got != want {
+		t.Errorf("after Apply:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCleanSymlinks(t *testing.T) {
+	root, outside := t.TempDir(), t.TempDir()
+	for _, name := range []string{filepath.Join(root, "sub", "a.o"), filepath.Join(outside, "a.o")} {
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte("obj"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	symlink(t, outside, filepath.Join(root, "ext"))
+
+	if _, err := PlanClean(root, Match("*/*.o"), FollowSymlinks(true)); !errors.Is(err, ErrInvalidOption) {
+		t.Fatalf("PlanClean with FollowSymlinks: error = %v, want ErrInvalidOption", err)
+	}
+
+	// A planned file's directory replaced by a link isn't deleted through it.
+	plan, err := PlanClean(root, Match("*/*.o"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Entries) != 1 || plan.Entries[0].RelPath != "sub/a.o" {
+		t.Fatalf("plan = %v, want sub/a.o", plan.Entries)
+	}
+	if err := os.RemoveAll(filepath.Join(root, "sub")); err != nil {
+		t.Fatal(err)
+	}
+	symlink(t, outside, filepath.Join(root, "sub"))
+	if err := plan.Apply(); !errors.Is(err, errSymlinkInPath) {
+		t.Errorf("Apply: error = %v, want errSymlinkInPath", err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "a.o")); err != nil {
+		t.Errorf("file outside of root deleted: %v", err)
+	}
+}
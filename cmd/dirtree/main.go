@@ -1,35 +1,559 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"math"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/template"
 
 	"github.com/arl/dirtree"
 )
 
+// Exit codes.
+const (
+	exitOK    = 0 // success
+	exitError = 1 // walk or I/O error
+	exitUsage = 2 // invalid command line, as set by the flag package
+	exitEmpty = 3 // nothing listed below the roots, with -fail-if-empty
+	exitDrift = 4 // the tree differs from the listing, with -check
+	exitLarge = 5 // the listed files exceed the size budget, with -max-size
+	exitSlow  = 6 // the walk lasted longer than the deadline, with -deadline
+)
+
 func main() {
 	log.SetFlags(0)
 	log.SetPrefix("[dirtree] ")
 
+	filesFrom := flag.String("files-from", "", "read root directories from `FILE`, one per line ('-' for stdin)")
+	output := flag.String("o", "", "write the listing to `FILE` instead of stdout")
+	gz := flag.Bool("gzip", false, "gzip-compress the listing")
+	format := flag.String("format", "", "output `FORMAT`: "+strings.Join(dirtree.Formats(), ", ")+", or a text/template printing each file, e.g '{{.Type}} {{.Size}} {{.RelPath}}'. sqlite-script writes SQL statements to pipe into sqlite3, not a database file")
+	stdin := flag.Bool("stdin", false, "list exactly the files which paths are read from stdin, one per line")
+	nul := flag.Bool("0", false, "with -stdin, paths are separated by NUL characters instead of newlines")
+	summary := flag.Bool("summary", false, "print a summary line after the listing")
+	summaryOnly := flag.Bool("summary-only", false, "only print the summary line")
+	var hashes stringsFlag
+	flag.Var(&hashes, "hash", "checksum `ALGO` to show: crc32 (default), md5, sha1, sha256, sha512 or xxh64, can be repeated")
+	jobs := flag.Int("j", runtime.GOMAXPROCS(0), "number of files to compute checksums of concurrently")
+	idleIO := flag.Bool("idle-io", false, "read files to compute checksums with the idle I/O priority (Linux only)")
+	throttle := flag.String("throttle", "", "read files to compute checksums at most at `RATE` bytes per second, with an optional K, M or G suffix (powers of 1024)")
+	var follow bool
+	flag.BoolVar(&follow, "L", false, "follow symbolic links, links looping to a parent directory are reported on stderr")
+	flag.BoolVar(&follow, "follow", false, "same as -L")
+	gitignore := flag.Bool("gitignore", false, "skip the files ignored by the .gitignore files met during the walk")
+	header := flag.Bool("header", false, "write a header line naming the columns and recording the listing options, for -check")
+	check := flag.String("check", "", "compare DIR with the listing saved in `LISTFILE`, print the differences")
+	var clean, keep stringsFlag
+	flag.Var(&clean, "clean", "list the files of DIR which name matches `PATTERN`, to delete them with -yes, can be repeated")
+	flag.Var(&keep, "keep", "with -clean, protect the files which name matches `PATTERN`, and their content, can be repeated")
+	yes := flag.Bool("yes", false, "with -clean, delete the listed files")
+	maxSize := flag.String("max-size", "", "fail if the listed files of a DIR weigh more than `SIZE` bytes, with an optional K, M or G suffix (powers of 1024)")
+	deadline := flag.Duration("deadline", 0, "fail if listing a DIR lasts longer than `DURATION`, e.g. 30s or 5m")
+	failIfEmpty := flag.Bool("fail-if-empty", false, fmt.Sprintf("exit with status %d if no file is listed below the root directories, or read with -stdin", exitEmpty))
+
 	flag.Usage = func() {
 		fmt.Fprintln(os.Stderr, "dirtree recursively lists a directory content")
-		fmt.Fprintln(os.Stderr, "usage: dirtree [DIR]")
+		fmt.Fprintln(os.Stderr, "usage: dirtree [flags] [DIR...]")
+		fmt.Fprintln(os.Stderr, "       dirtree [flags] -stdin [-0] < PATHS")
+		fmt.Fprintln(os.Stderr, "       dirtree -check LISTFILE [DIR]")
+		fmt.Fprintln(os.Stderr, "       dirtree -clean PATTERN [-keep PATTERN] [-yes] [DIR...]")
 		fmt.Fprintln(os.Stderr, "\tDIR defaults to current directory")
+		fmt.Fprintln(os.Stderr, "\tWhen more than one DIR is given, each listing is preceded by a header")
+		fmt.Fprintln(os.Stderr, "flags:")
+		flag.PrintDefaults()
+		fmt.Fprintln(os.Stderr, "exit status:")
+		fmt.Fprintf(os.Stderr, "\t%d on success\n", exitOK)
+		fmt.Fprintf(os.Stderr, "\t%d if an error occurred\n", exitError)
+		fmt.Fprintf(os.Stderr, "\t%d if the command line is invalid\n", exitUsage)
+		fmt.Fprintf(os.Stderr, "\t%d if nothing was listed, with -fail-if-empty\n", exitEmpty)
+		fmt.Fprintf(os.Stderr, "\t%d if the tree differs from LISTFILE, with -check\n", exitDrift)
+		fmt.Fprintf(os.Stderr, "\t%d if the listed files weigh more than SIZE, with -max-size\n", exitLarge)
+		fmt.Fprintf(os.Stderr, "\t%d if listing lasted longer than DURATION, with -deadline\n", exitSlow)
 	}
 	flag.Parse()
 
-	dir := "."
-	if flag.NArg() == 1 {
-		dir = flag.Args()[0]
+	if *check != "" {
+		if flag.NArg() > 1 || *format != "" || *stdin || *filesFrom != "" {
+			log.Printf("-check only accepts a single DIR")
+			os.Exit(exitUsage)
+		}
+		dir := "."
+		if flag.NArg() == 1 {
+			dir = flag.Arg(0)
+		}
+		same, err := checkListing(os.Stdout, *check, dir)
+		if err != nil {
+			log.Fatalf("error: %v", err)
+		}
+		if !same {
+			os.Exit(exitDrift)
+		}
+		return
+	}
+
+	if len(clean) != 0 {
+		if *format != "" || *stdin || *filesFrom != "" || *output != "" {
+			log.Printf("-clean only accepts DIR, -keep and -yes")
+			os.Exit(exitUsage)
+		}
+		dirs := flag.Args()
+		if len(dirs) == 0 {
+			dirs = []string{"."}
+		}
+		if err := cleanRoots(os.Stdout, dirs, clean, keep, *yes); err != nil {
+			log.Fatalf("error: %v", err)
+		}
+		return
+	}
+
+	mode := dirtree.ModeAll
+	if len(hashes) != 0 {
+		mode = dirtree.ModeType | dirtree.ModeSize
+		for _, algo := range hashes {
+			if algo == "crc32" {
+				mode |= dirtree.ModeCRC32
+				continue
+			}
+			if _, err := dirtree.ParseOptions("hash=" + algo); err != nil {
+				log.Printf("invalid -hash: %v", err)
+				os.Exit(exitUsage)
+			}
+		}
+	}
+	if *jobs < 1 {
+		log.Printf("-j must be positive")
+		os.Exit(exitUsage)
+	}
+	opts := []dirtree.Option{mode, dirtree.FollowSymlinks(follow), dirtree.RespectGitIgnore(*gitignore), dirtree.Jobs(*jobs), dirtree.IdleIO(*idleIO), dirtree.Header(*header)}
+	if *maxSize != "" {
+		n, err := parseSize(*maxSize)
+		if err != nil {
+			log.Printf("invalid -max-size: %v", err)
+			os.Exit(exitUsage)
+		}
+		opts = append(opts, dirtree.SizeBudget(n))
+	}
+	if *deadline < 0 {
+		log.Printf("-deadline must be positive")
+		os.Exit(exitUsage)
+	}
+	opts = append(opts, dirtree.Deadline(*deadline))
+	if *throttle != "" {
+		n, err := parseSize(*throttle)
+		if err != nil {
+			log.Printf("invalid -throttle: %v", err)
+			os.Exit(exitUsage)
+		}
+		opts = append(opts, dirtree.Throttle(n))
+	}
+	for _, algo := range hashes {
+		if algo != "crc32" {
+			opts = append(opts, dirtree.Hash(algo))
+		}
+	}
+	newEncoder := dirtree.EncoderFactory(dirtree.NewLineEncoder)
+	if *format != "" {
+		if enc, err := dirtree.FormatByName(*format); err == nil {
+			newEncoder = dirtree.EncoderFactory(enc)
+		} else {
+			tmpl, err := template.New("format").Parse(*format)
+			if err != nil {
+				log.Printf("invalid -format: %v", err)
+				os.Exit(exitUsage)
+			}
+			newEncoder = func(w io.Writer) dirtree.Encoder {
+				return dirtree.NewTemplateEncoder(w, tmpl)
+			}
+		}
+		opts = append(opts, dirtree.Encoding(newEncoder))
+	}
+
+	if *stdin {
+		if flag.NArg() != 0 || *filesFrom != "" {
+			log.Printf("-stdin can't be used with DIR or -files-from")
+			os.Exit(exitUsage)
+		}
+		var listed int
+		err := writeOutput(*output, *gz, func(w io.Writer) (err error) {
+			listed, err = writePaths(w, os.Stdin, *nul, newEncoder, opts...)
+			return err
+		})
+		if err != nil {
+			log.Fatalf("error: %v", err)
+		}
+		if *failIfEmpty && listed == 0 {
+			log.Printf("no files listed")
+			os.Exit(exitEmpty)
+		}
+		return
+	}
+
+	dirs := flag.Args()
+	if *filesFrom != "" {
+		roots, err := readRoots(*filesFrom)
+		if err != nil {
+			log.Fatalf("error: %v", err)
+		}
+		dirs = append(dirs, roots...)
 	}
-	if flag.NArg() > 1 {
-		flag.Usage()
-		os.Exit(1)
+	if len(dirs) == 0 {
+		dirs = []string{"."}
 	}
 
-	if err := dirtree.Write(os.Stdout, dir, dirtree.ModeAll); err != nil {
+	var (
+		total dirtree.Stats
+		below int // entries listed below the roots
+	)
+	err := writeOutput(*output, *gz, func(w io.Writer) (err error) {
+		lw := w
+		if *summaryOnly {
+			lw = io.Discard
+		}
+		total, below, err = writeRoots(lw, dirs, opts...)
+		if err != nil || !*summary && !*summaryOnly {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%d files, %d dirs, %d bytes, %d bytes hashed\n",
+			total.Files, total.Dirs, total.Bytes, total.BytesHashed)
+		return err
+	})
+	if errors.Is(err, dirtree.ErrOverBudget) {
+		log.Printf("error: %v", err)
+		os.Exit(exitLarge)
+	}
+	if errors.Is(err, dirtree.ErrDeadlineExceeded) {
+		log.Printf("error: %v", err)
+		os.Exit(exitSlow)
+	}
+	if err != nil {
 		log.Fatalf("error: %v", err)
 	}
+	if *failIfEmpty && below == 0 {
+		log.Printf("no files listed")
+		os.Exit(exitEmpty)
+	}
+}
+
+// writeOutput calls write with the writer the listing should be written to:
+// stdout if path is empty or a file otherwise, optionally gzip-compressed.
+//
+// When writing to a file, the listing is first written into a temporary file,
+// in the same directory, which is then renamed to path once complete. That way
+// path either holds a full listing or is left untouched.
+func writeOutput(path string, gz bool, write func(io.Writer) error) error {
+	if path == "" {
+		return writeMaybeGzip(os.Stdout, gz, write)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op after a successful rename
+
+	// CreateTemp creates files with 0600 permission.
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := writeMaybeGzip(tmp, gz, write); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func writeMaybeGzip(w io.Writer, gz bool, write func(io.Writer) error) error {
+	if !gz {
+		return write(w)
+	}
+
+	zw := gzip.NewWriter(w)
+	if err := write(zw); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// readRoots reads the list of root directories, one per line, from the file
+// at path, or from stdin if path is "-". Empty lines are ignored.
+func readRoots(path string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var roots []string
+	scan := bufio.NewScanner(r)
+	for scan.Scan() {
+		line := strings.TrimRight(scan.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		roots = append(roots, line)
+	}
+	if err := scan.Err(); err != nil {
+		return nil, fmt.Errorf("can't read roots: %v", err)
+	}
+	return roots, nil
+}
+
+// cleanRoots prints the files of dirs which names match one of the clean
+// patterns and none of the keep ones. If del is true, they are then deleted.
+func cleanRoots(w io.Writer, dirs, clean, keep []string, del bool) error {
+	opts := []dirtree.Option{dirtree.MatchBase(true)}
+	for _, pat := range clean {
+		opts = append(opts, dirtree.Match(pat))
+	}
+	for _, pat := range keep {
+		opts = append(opts, dirtree.Prune(pat))
+	}
+
+	var plans []*dirtree.CleanPlan
+	for _, dir := range dirs {
+		plan, err := dirtree.PlanClean(dir, opts...)
+		if err != nil {
+			return err
+		}
+		if _, err := plan.WriteTo(w); err != nil {
+			return err
+		}
+		plans = append(plans, plan)
+	}
+	if !del {
+		log.Printf("dry run, nothing deleted, run again with -yes to delete the listed files")
+		return nil
+	}
+	for _, plan := range plans {
+		if err := plan.Apply(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseSize parses a size in bytes, optionally followed by a K, M or G suffix
+// multiplying it by 1024, 1024² or 1024³.
+func parseSize(s string) (int64, error) {
+	mult := int64(1)
+	if n := len(s); n > 0 {
+		switch s[n-1] {
+		case 'K', 'k':
+			mult = 1 << 10
+		case 'M', 'm':
+			mult = 1 << 20
+		case 'G', 'g':
+			mult = 1 << 30
+		}
+		if mult != 1 {
+			s = s[:n-1]
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 || n > math.MaxInt64/mult {
+		return 0, fmt.Errorf("size out of range: %s", s)
+	}
+	return n * mult, nil
+}
+
+// stringsFlag is a flag.Value collecting the values of a repeated flag.
+type stringsFlag []string
+
+func (sf *stringsFlag) String() string { return strings.Join(*sf, ",") }
+
+func (sf *stringsFlag) Set(v string) error {
+	*sf = append(*sf, v)
+	return nil
+}
+
+// checkListing lists dir with the options recorded in the header of the
+// listing file at path, or with the default options if it has no header, and
+// compares it with the listing in that file. It reports whether they're the
+// same and otherwise writes the differences into w: lines only present in
+// the listing file are prefixed with '-', and lines only present in the
+// current listing with '+'.
+func checkListing(w io.Writer, path, dir string) (bool, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	if bytes.HasPrefix(buf, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(bytes.NewReader(buf))
+		if err != nil {
+			return false, err
+		}
+		if buf, err = io.ReadAll(zr); err != nil {
+			return false, err
+		}
+	}
+
+	want := strings.Split(strings.TrimSuffix(string(buf), "\n"), "\n")
+	opts := []dirtree.Option{dirtree.ModeAll}
+	if len(want) > 0 && strings.HasPrefix(want[0], "#dirtree/") {
+		if opts, err = dirtree.ParseHeader(want[0]); err != nil {
+			return false, fmt.Errorf("%s: invalid header: %v", path, err)
+		}
+		want = want[1:]
+	}
+
+	cur, err := dirtree.Sprint(dir, opts...)
+	if err != nil {
+		return false, err
+	}
+	got := strings.Split(strings.TrimSuffix(cur, "\n"), "\n")
+
+	wantSet := make(map[string]bool, len(want))
+	for _, l := range want {
+		wantSet[l] = true
+	}
+	gotSet := make(map[string]bool, len(got))
+	for _, l := range got {
+		gotSet[l] = true
+	}
+
+	same := true
+	for _, l := range want {
+		if !gotSet[l] {
+			same = false
+			fmt.Fprintf(w, "- %s\n", l)
+		}
+	}
+	for _, l := range got {
+		if !wantSet[l] {
+			same = false
+			fmt.Fprintf(w, "+ %s\n", l)
+		}
+	}
+	return same, nil
+}
+
+// writePaths writes the description of each file which path is read from r,
+// one per line or, if nul is true, separated by NUL characters. The output is
+// written by the Encoder returned by newEncoder. writePaths returns the number
+// of files written.
+func writePaths(w io.Writer, r io.Reader, nul bool, newEncoder dirtree.EncoderFactory, opts ...dirtree.Option) (n int, err error) {
+	bufw := bufio.NewWriter(w)
+	enc := newEncoder(bufw)
+	if err := enc.Begin(); err != nil {
+		return n, err
+	}
+	scan := bufio.NewScanner(r)
+	if nul {
+		scan.Split(scanNUL)
+	}
+	for scan.Scan() {
+		path := scan.Text()
+		if !nul {
+			path = strings.TrimRight(path, "\r")
+		}
+		if path == "" {
+			continue
+		}
+		ent, err := dirtree.Stat(path, opts...)
+		if err != nil {
+			return n, err
+		}
+		if err := enc.Entry(ent); err != nil {
+			return n, err
+		}
+		n++
+	}
+	if err := scan.Err(); err != nil {
+		return n, fmt.Errorf("can't read paths: %v", err)
+	}
+	if err := enc.End(); err != nil {
+		return n, err
+	}
+	return n, bufw.Flush()
+}
+
+// scanNUL is a bufio.SplitFunc splitting NUL-terminated tokens.
+func scanNUL(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// writeRoots writes the listing of each of the given directories into w. If
+// there are more than one directory, each listing is preceded by a header line
+// showing the root directory, and successive listings are separated by a blank
+// line.
+//
+// writeRoots returns the statistics of all walks, summed, and the number of
+// entries listed below the roots, roots which aren't directories being counted.
+// The symbolic link loops met, when following links, are logged.
+func writeRoots(w io.Writer, dirs []string, opts ...dirtree.Option) (total dirtree.Stats, below int, err error) {
+	var (
+		st    dirtree.Stats
+		loops dirtree.SymlinkLoops
+	)
+	opts = append(opts, &st, &loops)
+	for i, dir := range dirs {
+		if len(dirs) > 1 {
+			if i != 0 {
+				if _, err := fmt.Fprintln(w); err != nil {
+					return total, below, err
+				}
+			}
+			if _, err := fmt.Fprintf(w, "%s:\n", dir); err != nil {
+				return total, below, err
+			}
+		}
+		if err := dirtree.Write(w, dir, opts...); err != nil {
+			return total, below, err
+		}
+		addStats(&total, &st)
+		below += st.Listed
+		if st.Dirs != 0 {
+			// The root directory itself.
+			below--
+		}
+		for _, l := range loops {
+			log.Printf("%s: symbolic link loop, not followed", filepath.Join(dir, filepath.FromSlash(l)))
+		}
+	}
+	return total, below, nil
+}
+
+// addStats adds the counters of st to total.
+func addStats(total, st *dirtree.Stats) {
+	total.Visited += st.Visited
+	total.Dirs += st.Dirs
+	total.Listed += st.Listed
+	total.Skipped += st.Skipped
+	total.Files += st.Files
+	total.Bytes += st.Bytes
+	total.BytesHashed += st.BytesHashed
+	if st.MaxPathLen > total.MaxPathLen {
+		total.MaxPathLen = st.MaxPathLen
+	}
+	total.WalkTime += st.WalkTime
+	total.StatTime += st.StatTime
+	total.HashTime += st.HashTime
 }
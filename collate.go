@@ -0,0 +1,106 @@
+package dirtree
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The Collation option controls the order in which the files of a same
+// directory are listed. Whatever the collation, the listing order is
+// deterministic and directories are always listed before their content.
+type Collation uint8
+
+const (
+	// CollateBytes sorts file names by byte order, this is the default.
+	CollateBytes Collation = iota
+
+	// CollateFold sorts file names in a case-insensitive manner, names only
+	// differing by case are sorted by byte order.
+	CollateFold
+
+	// CollateNatural sorts file names in natural order, that is, sequences of
+	// digits are compared numerically: "file2" comes before "file10". Names
+	// considered equal, like "file1" and "file01", are sorted by byte order.
+	CollateNatural
+)
+
+func (c Collation) apply(cfg *config) error {
+	if c > CollateNatural {
+		return fmt.Errorf("%w: unknown Collation %d", ErrInvalidOption, c)
+	}
+	cfg.collation = c
+	return nil
+}
+
+// less returns the function comparing 2 file names according to c.
+func (c Collation) less() func(a, b string) bool {
+	switch c {
+	case CollateFold:
+		return func(a, b string) bool {
+			la, lb := strings.ToLower(a), strings.ToLower(b)
+			if la != lb {
+				return la < lb
+			}
+			return a < b
+		}
+	case CollateNatural:
+		return func(a, b string) bool {
+			if c := naturalCompare(a, b); c != 0 {
+				return c < 0
+			}
+			return a < b
+		}
+	}
+	return func(a, b string) bool { return a < b }
+}
+
+// naturalCompare compares a and b, considering sequences of digits as numbers.
+func naturalCompare(a, b string) int {
+	for a != "" && b != "" {
+		ca, cb := a[0], b[0]
+		if isDigit(ca) && isDigit(cb) {
+			var na, nb string
+			na, a = digitPrefix(a)
+			nb, b = digitPrefix(b)
+			na, nb = strings.TrimLeft(na, "0"), strings.TrimLeft(nb, "0")
+			if len(na) != len(nb) {
+				if len(na) < len(nb) {
+					return -1
+				}
+				return 1
+			}
+			if na != nb {
+				if na < nb {
+					return -1
+				}
+				return 1
+			}
+			continue
+		}
+		if ca != cb {
+			if ca < cb {
+				return -1
+			}
+			return 1
+		}
+		a, b = a[1:], b[1:]
+	}
+	switch {
+	case a == "" && b == "":
+		return 0
+	case a == "":
+		return -1
+	}
+	return 1
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
+// digitPrefix splits s in its leading sequence of digits and the rest.
+func digitPrefix(s string) (digits, rest string) {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	return s[:i], s[i:]
+}
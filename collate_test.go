@@ -0,0 +1,107 @@
+package dirtree
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCollation(t *testing.T) {
+	fsys := fstest.MapFS{
+		"file10":   &fstest.MapFile{},
+		"file2":    &fstest.MapFile{},
+		"File3":    &fstest.MapFile{},
+		"dir/b":    &fstest.MapFile{},
+		"dir/A":    &fstest.MapFile{},
+		"file02/x": &fstest.MapFile{},
+	}
+
+	tests := []struct {
+		collation Collation
+		want      []string
+	}{
+		{
+			collation: CollateBytes,
+			want:      []string{"File3", "dir", "dir/A", "dir/b", "file02", "file02/x", "file10", "file2"},
+		},
+		{
+			collation: CollateFold,
+			want:      []string{"dir", "dir/A", "dir/b", "file02", "file02/x", "file10", "file2", "File3"},
+		},
+		{
+			collation: CollateNatural,
+			want:      []string{"File3", "dir", "dir/A", "dir/b", "file02", "file02/x", "file2", "file10"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			got, err := SprintFS(fsys, ".", tt.collation, ExcludeRoot, PrintMode(0))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := strings.Join(tt.want, "\n")
+			if got = strings.TrimSpace(got); got != want {
+				t.Errorf("got:\n%s\n\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestCollationInvalid(t *testing.T) {
+	if _, err := List(".", Collation(255)); err == nil {
+		t.Errorf("List() with invalid collation should fail")
+	}
+}
+
+// Check that dirWalker, with lexical order, walks files like
+// filepath.WalkDir.
+func Test_dirWalker(t *testing.T) {
+	walk := func(walkdir func(fs.WalkDirFunc) error) []string {
+		var paths []string
+		err := walkdir(func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			paths = append(paths, path+" "+d.Type().String())
+			if d.Name() == "B" {
+				return fs.SkipDir
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return paths
+	}
+
+	root := filepath.Join("testdata", "dir")
+	want := walk(func(fn fs.WalkDirFunc) error { return filepath.WalkDir(root, fn) })
+	got := walk(func(fn fs.WalkDirFunc) error { return (&dirWalker{}).walk(root, fn) })
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\n\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func Test_naturalCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"a", "", 1},
+		{"", "a", -1},
+		{"file2", "file10", -1},
+		{"file10", "file2", 1},
+		{"file01", "file1", 0},
+		{"a1b2", "a1b10", -1},
+		{"x007", "x7y", -1},
+		{"abc", "abd", -1},
+	}
+	for _, tt := range tests {
+		if got := naturalCompare(tt.a, tt.b); got != tt.want {
+			t.Errorf("naturalCompare(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package dirtree
+
+import "strings"
+
+// CaseCollisions holds groups of paths differing only by case. Such paths
+// can't coexist on case-insensitive filesystems, like the default ones on
+// Windows and macOS.
+//
+// A *CaseCollisions is an Option: when provided, it gets reset at the
+// beginning of the walk and then filled with the groups of colliding paths
+// found in the listing, ordered by the first collision met, then by listing
+// order within each group.
+//
+//	var cc dirtree.CaseCollisions
+//	entries, err := dirtree.List("dir", &cc)
+//	for _, group := range cc {
+//		fmt.Println("colliding paths:", group)
+//	}
+type CaseCollisions [][]string
+
+func (cc *CaseCollisions) apply(cfg *config) error {
+	cfg.collisions = cc
+	return nil
+}
+
+// caseFolder detects paths differing only by case.
+type caseFolder struct {
+	groups map[string]int // index in *out of the group of a folded path
+	seen   map[string]string
+	out    *CaseCollisions
+}
+
+func newCaseFolder(out *CaseCollisions) *caseFolder {
+	*out = nil
+	return &caseFolder{
+		groups: make(map[string]int),
+		seen:   make(map[string]string),
+		out:    out,
+	}
+}
+
+func (cf *caseFolder) add(relpath string) {
+	folded := strings.ToLower(relpath)
+	first, ok := cf.seen[folded]
+	if !ok {
+		cf.seen[folded] = relpath
+		return
+	}
+
+	if i, ok := cf.groups[folded]; ok {
+		(*cf.out)[i] = append((*cf.out)[i], relpath)
+		return
+	}
+	cf.groups[folded] = len(*cf.out)
+	*cf.out = append(*cf.out, []string{first, relpath})
+}
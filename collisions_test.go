@@ -0,0 +1,35 @@
+package dirtree
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCaseCollisions(t *testing.T) {
+	fsys := fstest.MapFS{
+		"README":      &fstest.MapFile{},
+		"readme":      &fstest.MapFile{},
+		"ReadMe":      &fstest.MapFile{},
+		"dir/file":    &fstest.MapFile{},
+		"Dir/other":   &fstest.MapFile{},
+		"src/Main.go": &fstest.MapFile{},
+		"src/main.go": &fstest.MapFile{},
+		"src/util.go": &fstest.MapFile{},
+	}
+
+	cc := CaseCollisions{{"stale"}}
+	if _, err := ListFS(fsys, ".", &cc); err != nil {
+		t.Fatal(err)
+	}
+
+	// Groups are ordered by the first collision met.
+	want := CaseCollisions{
+		{"README", "ReadMe", "readme"},
+		{"Dir", "dir"},
+		{"src/Main.go", "src/main.go"},
+	}
+	if !reflect.DeepEqual(cc, want) {
+		t.Errorf("got %q, want %q", cc, want)
+	}
+}
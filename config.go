@@ -0,0 +1,280 @@
+package dirtree
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+// Config is an alternative to passing options to List, Write, Sprint, etc. It
+// holds the same settings as the options, in a form that can directly be
+// unmarshaled from a configuration file.
+//
+// The zero Config lists all files but doesn't show any information about them
+// (i.e Mode is 0), start from DefaultConfig to get the default settings:
+//
+//	cfg := dirtree.DefaultConfig()
+//	if err := json.Unmarshal(data, &cfg); err != nil {
+//		// handle error
+//	}
+//	entries, err := cfg.List("dir")
+type Config struct {
+	Mode        PrintMode `json:"mode,omitempty" yaml:"mode,omitempty"`
+	Type        string    `json:"type,omitempty" yaml:"type,omitempty"` // Empty means all types.
+	ExcludeRoot bool      `json:"exclude_root,omitempty" yaml:"exclude_root,omitempty"`
+	Ignore      []string  `json:"ignore,omitempty" yaml:"ignore,omitempty"`
+	Match       []string  `json:"match,omitempty" yaml:"match,omitempty"`
+	Prune       []string  `json:"prune,omitempty" yaml:"prune,omitempty"`
+	MatchBase   bool      `json:"match_base,omitempty" yaml:"match_base,omitempty"`
+	Follow      bool      `json:"follow,omitempty" yaml:"follow,omitempty"`
+	GitIgnore   bool      `json:"gitignore,omitempty" yaml:"gitignore,omitempty"`
+	Hash        []string  `json:"hash,omitempty" yaml:"hash,omitempty"`
+	Depth       int       `json:"depth,omitempty" yaml:"depth,omitempty"`
+	HashLimit   int64     `json:"hash_limit,omitempty" yaml:"hash_limit,omitempty"`
+}
+
+// DefaultConfig returns the Config equivalent to not providing any option.
+func DefaultConfig() Config {
+	return Config{Mode: ModeDefault}
+}
+
+// Options returns the options equivalent to c.
+func (c *Config) Options() []Option {
+	opts := []Option{c.Mode, IncludeRoot(!c.ExcludeRoot), Depth(c.Depth), HashLimit(c.HashLimit), MatchBase(c.MatchBase), FollowSymlinks(c.Follow), RespectGitIgnore(c.GitIgnore)}
+	if c.Type != "" {
+		opts = append(opts, Type(c.Type))
+	}
+	for _, pat := range c.Ignore {
+		opts = append(opts, Ignore(pat))
+	}
+	for _, pat := range c.Match {
+		opts = append(opts, Match(pat))
+	}
+	for _, pat := range c.Prune {
+		opts = append(opts, Prune(pat))
+	}
+	for _, algo := range c.Hash {
+		opts = append(opts, Hash(algo))
+	}
+	return opts
+}
+
+// Validate checks that c is a valid configuration.
+func (c *Config) Validate() error {
+	if _, err := newConfig(c.Options()); err != nil {
+		return fmt.Errorf("dirtree: %w", err)
+	}
+	return nil
+}
+
+// List is like List but uses the settings of c.
+func (c *Config) List(root string) ([]*Entry, error) {
+	return ListFS(nil, root, c.Options()...)
+}
+
+// ListFS is like ListFS but uses the settings of c.
+func (c *Config) ListFS(fsys fs.FS, root string) ([]*Entry, error) {
+	return ListFS(fsys, root, c.Options()...)
+}
+
+// Write is like Write but uses the settings of c.
+func (c *Config) Write(w io.Writer, root string) error {
+	return WriteFS(w, nil, root, c.Options()...)
+}
+
+// WriteFS is like WriteFS but uses the settings of c.
+func (c *Config) WriteFS(w io.Writer, fsys fs.FS, root string) error {
+	return WriteFS(w, fsys, root, c.Options()...)
+}
+
+// Sprint is like Sprint but uses the settings of c.
+func (c *Config) Sprint(root string) (string, error) {
+	return SprintFS(nil, root, c.Options()...)
+}
+
+// SprintFS is like SprintFS but uses the settings of c.
+func (c *Config) SprintFS(fsys fs.FS, root string) (string, error) {
+	return SprintFS(fsys, root, c.Options()...)
+}
+
+// ParseOptions parses options from a compact, comma-separated, list of
+// key=value pairs, for example:
+//
+//	"type=fd,depth=2,ignore=*/.git,mode=type|size"
+//
+// Recognized keys are the following, values cannot contain commas:
+//
+//	mode       PrintMode, as accepted by PrintMode.UnmarshalText
+//	type       Type option
+//	root       IncludeRoot option (true or false)
+//	depth      Depth option
+//	ignore     Ignore option, can be repeated
+//	match      Match option, can be repeated
+//	prune      Prune option, can be repeated
+//	matchbase  MatchBase option (true or false)
+//	follow     FollowSymlinks option (true or false)
+//	gitignore  RespectGitIgnore option (true or false)
+//	hash       Hash option, can be repeated
+//	hashlimit  HashLimit option
+//
+// The options are validated and returned in the order they appear in s.
+func ParseOptions(s string) ([]Option, error) {
+	var opts []Option
+	if strings.TrimSpace(s) == "" {
+		return opts, nil
+	}
+
+	var cfg config
+	for _, kv := range strings.Split(s, ",") {
+		k, v, ok := cut(strings.TrimSpace(kv), "=")
+		if !ok {
+			return nil, fmt.Errorf("dirtree: %w: %q, missing '='", ErrInvalidOption, kv)
+		}
+
+		var (
+			opt Option
+			err error
+		)
+		switch k {
+		case "mode":
+			opt, err = parseMode(v)
+		case "type":
+			opt = Type(v)
+		case "root":
+			var b bool
+			b, err = strconv.ParseBool(v)
+			opt = IncludeRoot(b)
+		case "depth":
+			var n int
+			n, err = strconv.Atoi(v)
+			opt = Depth(n)
+		case "ignore":
+			opt = Ignore(v)
+		case "matchbase":
+			var b bool
+			b, err = strconv.ParseBool(v)
+			opt = MatchBase(b)
+		case "hash":
+			opt = Hash(v)
+		case "follow":
+			var b bool
+			b, err = strconv.ParseBool(v)
+			opt = FollowSymlinks(b)
+		case "gitignore":
+			var b bool
+			b, err = strconv.ParseBool(v)
+			opt = RespectGitIgnore(b)
+		case "prune":
+			opt = Prune(v)
+		case "match":
+			opt = Match(v)
+		case "hashlimit":
+			var n int64
+			n, err = strconv.ParseInt(v, 10, 64)
+			opt = HashLimit(n)
+		default:
+			return nil, fmt.Errorf("dirtree: %w: unknown key %q", ErrInvalidOption, k)
+		}
+		if err == nil {
+			err = opt.apply(&cfg)
+		}
+		if err != nil {
+			if !errors.Is(err, ErrInvalidOption) && !errors.Is(err, ErrInvalidType) && !errors.Is(err, ErrInvalidPattern) {
+				err = fmt.Errorf("%w: %s: %v", ErrInvalidOption, k, err)
+			}
+			return nil, fmt.Errorf("dirtree: %w", err)
+		}
+		opts = append(opts, opt)
+	}
+	return opts, nil
+}
+
+// cut is strings.Cut, not available in Go 1.16.
+func cut(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+var modeNames = []struct {
+	mode PrintMode
+	name string
+}{
+	{ModeType, "type"},
+	{ModeSize, "size"},
+	{ModeCRC32, "crc32"},
+	{ModeAlloc, "alloc"},
+	{ModeLink, "link"},
+	{ModeHardlink, "hardlink"},
+	{ModeBirthTime, "btime"},
+	{ModeOwner, "owner"},
+	{ModeExec, "exec"},
+}
+
+// MarshalText implements encoding.TextMarshaler. The text form of a PrintMode
+// is the list of the names of its bits, separated by '|', for example
+// "type|size|crc32".
+func (m PrintMode) MarshalText() ([]byte, error) {
+	var names []string
+	rest := m
+	for _, mn := range modeNames {
+		if m&mn.mode != 0 {
+			names = append(names, mn.name)
+			rest &^= mn.mode
+		}
+	}
+	if rest != 0 {
+		return nil, fmt.Errorf("%w: unknown PrintMode bits %#x", ErrInvalidOption, uint32(rest))
+	}
+	return []byte(strings.Join(names, "|")), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. In addition to the form
+// produced by MarshalText, it accepts "default" and "all" for ModeDefault and
+// ModeAll, "diskusage" for ModeDiskUsage, and the numeric value of the bit set.
+func (m *PrintMode) UnmarshalText(text []byte) error {
+	mode, err := parseMode(string(text))
+	if err != nil {
+		return err
+	}
+	*m = mode
+	return nil
+}
+
+func parseMode(s string) (PrintMode, error) {
+	if n, err := strconv.ParseUint(s, 0, 32); err == nil {
+		return PrintMode(n), nil
+	}
+
+	var mode PrintMode
+	if s == "" {
+		return mode, nil
+	}
+next:
+	for _, name := range strings.Split(s, "|") {
+		name = strings.TrimSpace(name)
+		switch name {
+		case "default":
+			mode |= ModeDefault
+			continue
+		case "all":
+			mode |= ModeAll
+			continue
+		case "diskusage":
+			mode |= ModeDiskUsage
+			continue
+		}
+		for _, mn := range modeNames {
+			if mn.name == name {
+				mode |= mn.mode
+				continue next
+			}
+		}
+		return 0, fmt.Errorf("%w: unknown PrintMode %q", ErrInvalidOption, name)
+	}
+	return mode, nil
+}
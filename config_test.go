@@ -0,0 +1,133 @@
+package dirtree
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfig(t *testing.T) {
+	const data = `{
+		"mode": "type|crc32",
+		"type": "f?",
+		"ignore": ["*/symfile1"],
+		"depth": 2
+	}`
+
+	cfg := DefaultConfig()
+	if err := json.Unmarshal([]byte(data), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	got, err := cfg.Sprint(filepath.Join("testdata", "dir"))
+	if err != nil {
+		t.Fatalf("Sprint() error = %v", err)
+	}
+	want := "f crc=0451ac5e A/file1"
+	if got = strings.TrimSpace(got); got != want {
+		t.Errorf("Sprint() = %q, want %q", got, want)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	cfg := Config{Ignore: []string{"a/b["}}
+	if err := cfg.Validate(); !errors.Is(err, ErrInvalidPattern) {
+		t.Errorf("Validate() error = %v, want %v", err, ErrInvalidPattern)
+	}
+}
+
+func TestPrintModeText(t *testing.T) {
+	tests := []struct {
+		text    string
+		mode    PrintMode
+		wantErr bool
+	}{
+		{text: "", mode: 0},
+		{text: "type", mode: ModeType},
+		{text: "type|size", mode: ModeDefault},
+		{text: "type|size|crc32", mode: ModeAll},
+		{text: "default", mode: ModeDefault},
+		{text: "all", mode: ModeAll},
+		{text: "diskusage", mode: ModeSize | ModeAlloc},
+		{text: "crc32 | default", mode: ModeAll},
+		{text: "5", mode: ModeType | ModeCRC32},
+		{text: "color", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			var m PrintMode
+			err := m.UnmarshalText([]byte(tt.text))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UnmarshalText() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if m != tt.mode {
+				t.Errorf("UnmarshalText() = %d, want %d", m, tt.mode)
+			}
+
+			// Round-trip
+			text, err := m.MarshalText()
+			if err != nil {
+				t.Fatalf("MarshalText() error = %v", err)
+			}
+			var m2 PrintMode
+			if err := m2.UnmarshalText(text); err != nil || m2 != m {
+				t.Errorf("round-trip: got (%d, %v), want %d", m2, err, m)
+			}
+		})
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	root := filepath.Join("testdata", "dir")
+	tests := []struct {
+		s       string
+		want    string
+		wantErr error
+	}{
+		{s: "", want: "d            .\nd            A\nd            A/B\nl            A/B/symdirA\nf 13b        A/file1\nl            A/symfile1"},
+		{s: "type=f,mode=all", want: "f 13b        crc=0451ac5e A/file1"},
+		{s: "mode=type, depth=1, root=false", want: "d A"},
+		{s: "mode=type,ignore=A/B*,ignore=*/sym*,match=A,match=A/*", want: "d A\nf A/file1"},
+		{s: "mode=type,matchbase=true,match=file1", want: "f A/file1"},
+		{s: "type=fl,mode=type,follow=true", want: "l A/B/symdirA\nf A/file1\nf A/symfile1"},
+		{s: "mode=type,prune=A/B", want: "d .\nd A\nf A/file1\nl A/symfile1"},
+		{s: "type=f,mode=crc32,hashlimit=5", want: "crc~=4ff4f23f A/file1"},
+		{s: "type=f,mode=type,gitignore=true", want: "f A/file1"},
+		{s: "gitignore=maybe", wantErr: ErrInvalidOption},
+		{s: "depth", wantErr: ErrInvalidOption},
+		{s: "color=true", wantErr: ErrInvalidOption},
+		{s: "depth=two", wantErr: ErrInvalidOption},
+		{s: "depth=-2", wantErr: ErrInvalidOption},
+		{s: "mode=type|color", wantErr: ErrInvalidOption},
+		{s: "type=x", wantErr: ErrInvalidType},
+		{s: "ignore=a[", wantErr: ErrInvalidPattern},
+		{s: "prune=a[", wantErr: ErrInvalidPattern},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			opts, err := ParseOptions(tt.s)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseOptions() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			got, err := Sprint(root, opts...)
+			if err != nil {
+				t.Fatalf("Sprint() error = %v", err)
+			}
+			if got = strings.TrimSpace(got); got != tt.want {
+				t.Errorf("Sprint() =\n%s\n\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package dirtree
+
+import (
+	"fmt"
+	"hash"
+	"sort"
+)
+
+// The DirChecksums option, when true, gives directories checksums, for
+// ModeCRC32 and the Hash option, computed from those of their content: the
+// checksum of a directory is the hash, with the same algorithm, of the type,
+// checksum and name of each of its children, sorted by name. Symbolic links
+// contribute their target, other types than files and directories their type.
+// A single changed file then changes the checksums of all its parent
+// directories, up to the root.
+//
+// Only listed files are taken into account, so that the checksums of
+// directories only depend on the options filtering the listing. Since
+// directories come before their content, Write and WriteFS don't print
+// anything before the walk is over. DirChecksums has no effect on Walk and
+// WalkFS.
+type DirChecksums bool
+
+func (dc DirChecksums) apply(cfg *config) error {
+	cfg.dirSums = bool(dc)
+	return nil
+}
+
+// sumDirs sets the checksums of the directories of ents, listed with cfg.
+func sumDirs(ents []*Entry, cfg *config) {
+	algos := make([]string, 0, len(cfg.format.hashes)+1)
+	if cfg.mode&ModeCRC32 != 0 {
+		algos = append(algos, "crc32")
+	}
+	for _, hc := range cfg.format.hashes {
+		algos = append(algos, hc.algo)
+	}
+	if len(algos) == 0 {
+		return
+	}
+
+	Build(ents).WalkPost(func(n *Node) error {
+		if !n.IsDir() {
+			return nil
+		}
+		sums, partial := dirSums(n, algos)
+		if n.Entry == nil {
+			// Directory not listed, but its checksums are needed by its
+			// parents.
+			n.Entry = &Entry{Type: Dir}
+		}
+		e := n.Entry
+		e.summed = true
+		e.partial = partial
+		e.Digests = e.Digests[:0]
+		for i, algo := range algos {
+			if i == 0 && cfg.mode&ModeCRC32 != 0 {
+				e.Checksum = sums[i]
+				continue
+			}
+			e.Digests = append(e.Digests, Digest{Algo: algo, Sum: sums[i]})
+		}
+		return nil
+	})
+}
+
+// dirSums returns the checksums of the directory n, computed with each of
+// algos from the checksums of its children, and whether one of them is
+// partial.
+func dirSums(n *Node, algos []string) ([]string, bool) {
+	children := append([]*Node(nil), n.Children...)
+	sort.Slice(children, func(i, j int) bool { return children[i].Name() < children[j].Name() })
+
+	hs := make([]hash.Hash, len(algos))
+	for i, algo := range algos {
+		hs[i] = hashAlgos[algo]()
+	}
+	partial := false
+	var b []byte
+	for _, c := range children {
+		e := c.Entry
+		partial = partial || e.partial
+		for i, h := range hs {
+			b = append(b[:0], e.Type.char(), 0)
+			switch {
+			case e.Type == Symlink:
+				b = append(b, e.LinkTarget...)
+			case e.Type == File || e.Type == Dir:
+				b = append(b, e.sum(algos[i])...)
+			}
+			b = append(b, 0)
+			b = append(b, c.Name()...)
+			b = append(b, 0)
+			h.Write(b)
+		}
+	}
+	sums := make([]string, len(hs))
+	for i, h := range hs {
+		sums[i] = fmt.Sprintf("%x", h.Sum(nil))
+	}
+	return sums, partial
+}
+
+// sum returns the checksum of e computed with algo, "crc32" meaning the one of
+// ModeCRC32, or n/a.
+func (e *Entry) sum(algo string) string {
+	if algo == "crc32" && e.Checksum != "" {
+		return e.Checksum
+	}
+	for _, d := range e.Digests {
+		if d.Algo == algo {
+			return d.Sum
+		}
+	}
+	return na
+}
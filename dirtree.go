@@ -8,8 +8,11 @@ import (
 	"io"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 // List walks the directory rooted at root and returns entries.
@@ -26,25 +29,144 @@ func List(root string, opts ...Option) ([]*Entry, error) {
 // A variable number of options can be provided to control the limit the files
 // printed and/or the amount of information gathered for each of them.
 func ListFS(fsys fs.FS, root string, opts ...Option) ([]*Entry, error) {
-	entries, err := walkTree(root, fsys, opts...)
+	cfg, err := newConfig(opts)
 	if err != nil {
-		return nil, fmt.Errorf("dirtree: %v", err)
+		return nil, fmt.Errorf("dirtree: %w", err)
+	}
+
+	entries, err := listTree(make([]*Entry, 0, 128), root, fsys, &cfg)
+	if err != nil {
+		return nil, fmt.Errorf("dirtree: %w", err)
+	}
+	return entries, nil
+}
+
+// ListAppend is like List but appends the entries to dst and returns the
+// extended slice. Repeated listings can reuse the same slice, truncated to
+// dst[:0], to avoid reallocating it. dst is returned unmodified in case of
+// error.
+func ListAppend(dst []*Entry, root string, opts ...Option) ([]*Entry, error) {
+	return ListAppendFS(dst, nil, root, opts...)
+}
+
+// ListAppendFS is like ListFS but appends the entries to dst and returns the
+// extended slice. Repeated listings can reuse the same slice, truncated to
+// dst[:0], to avoid reallocating it. dst is returned unmodified in case of
+// error.
+func ListAppendFS(dst []*Entry, fsys fs.FS, root string, opts ...Option) ([]*Entry, error) {
+	cfg, err := newConfig(opts)
+	if err != nil {
+		return dst, fmt.Errorf("dirtree: %w", err)
+	}
+
+	entries, err := listTree(dst, root, fsys, &cfg)
+	if err != nil {
+		return dst, fmt.Errorf("dirtree: %w", err)
 	}
 	return entries, nil
 }
 
+// listTree walks the tree rooted at root, as walkTree, and appends the listed
+// entries to dst. In case of error, the entries listed so far are returned. If
+// cfg.limit is set and there are more entries to list, the walk stops and
+// errPageFull is returned with the first cfg.limit entries.
+func listTree(dst []*Entry, root string, fsys fs.FS, cfg *config) ([]*Entry, error) {
+	var slab entrySlab
+	entries := dst
+	err := walkTree(root, fsys, cfg, func(ent *Entry) error {
+		if cfg.limit != 0 && len(entries)-len(dst) == cfg.limit {
+			return errPageFull
+		}
+		var e *Entry
+		if cfg.pool {
+			e = entryPool.Get().(*Entry)
+		} else {
+			e = slab.new()
+		}
+		*e = *ent
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil && err != errPageFull {
+		return entries, err
+	}
+	if cfg.dirSums {
+		sumDirs(entries[len(dst):], cfg)
+	}
+	if cfg.markEmpty {
+		markEmptyDirs(entries[len(dst):])
+	}
+	if cfg.format.align {
+		cfg.format.alignColumns(entries[len(dst):])
+	}
+	return entries, err
+}
+
 // WriteFS walks the directory rooted at root in the given filesystem and prints
 // one file per line into w.
 //
+// Files are printed as the walk proceeds, so in case of error, w may have
+// received a partial listing.
+//
 // A variable number of options can be provided to control the limit the files
 // printed and/or the amount of information printed for each of them.
 func WriteFS(w io.Writer, fsys fs.FS, root string, opts ...Option) error {
-	entries, err := walkTree(root, fsys, opts...)
+	cfg, err := newConfig(opts)
 	if err != nil {
-		return fmt.Errorf("dirtree: %v", err)
+		return fmt.Errorf("dirtree: %w", err)
+	}
+	return writeTree(w, root, fsys, &cfg, nil)
+}
+
+// writeTree walks the tree rooted at root, as walkTree, and writes the listing
+// into w. If before is not nil, it's called with each entry before it's
+// written.
+func writeTree(w io.Writer, root string, fsys fs.FS, cfg *config, before func(ent *Entry) error) error {
+	bufw := bufio.NewWriterSize(w, cfg.bufSize)
+	newEncoder := cfg.encoding
+	if newEncoder == nil {
+		newEncoder = NewLineEncoder
+	}
+	enc := newEncoder(bufw)
+	if h, ok := enc.(headerEncoder); ok && cfg.header {
+		h.setHeader(cfg.format.appendHeader(nil, cfg.mode, cfg.follow, cfg.gitignore))
 	}
-	if err := writeEntries(w, entries); err != nil {
-		return fmt.Errorf("dirtree: %v", err)
+
+	if err := enc.Begin(); err != nil {
+		return fmt.Errorf("dirtree: can't write output: %w", err)
+	}
+	encode := func(ent *Entry) error {
+		if before != nil {
+			if err := before(ent); err != nil {
+				return err
+			}
+		}
+		if err := enc.Entry(ent); err != nil {
+			return fmt.Errorf("can't write output: %w", err)
+		}
+		return nil
+	}
+	if cfg.format.align || cfg.dirSums || cfg.markEmpty {
+		// Columns widths, checksums of directories and whether they're
+		// empty are only known once all entries have been listed.
+		entries, err := listTree(nil, root, fsys, cfg)
+		if err != nil {
+			return fmt.Errorf("dirtree: %w", err)
+		}
+		for _, ent := range entries {
+			if err := encode(ent); err != nil {
+				return fmt.Errorf("dirtree: %w", err)
+			}
+		}
+	} else if err := walkTree(root, fsys, cfg, encode); err != nil {
+		return fmt.Errorf("dirtree: %w", err)
+	}
+	if err := enc.End(); err != nil {
+		return fmt.Errorf("dirtree: can't write output: %w", err)
+	}
+
+	if err := bufw.Flush(); err != nil {
+		return fmt.Errorf("dirtree: can't write output: %w", err)
 	}
 	return nil
 }
@@ -77,78 +199,200 @@ func Sprint(root string, opts ...Option) (string, error) {
 	return SprintFS(nil, root, opts...)
 }
 
-func writeEntries(w io.Writer, entries []*Entry) error {
-	bufw := bufio.NewWriter(w)
+// WalkFS walks the directory rooted at root in the given filesystem, or in the
+// OS filesystem if fsys is nil, and calls fn for each listed file, in order.
+//
+// The Entry passed to fn is reused, it's only valid for the duration of the
+// call. Entry.DirEntry gives access to the directory entry met during the walk.
+// As with fs.WalkDir, if fn returns fs.SkipDir for a directory, its content is
+// skipped, and for another file, the remaining files of its directory are
+// skipped. Any other error returned by fn stops the walk and is returned as is.
+// The Jobs option has no effect.
+func WalkFS(fsys fs.FS, root string, fn func(e *Entry) error, opts ...Option) error {
+	cfg, err := newConfig(opts)
+	if err != nil {
+		return fmt.Errorf("dirtree: %w", err)
+	}
 
-	for _, ent := range entries {
-		if _, err := bufw.WriteString(ent.Format()); err != nil {
-			return err
+	// Checksums have to be computed before fn is called, since its result
+	// drives the walk.
+	cfg.jobs = 0
+	var fnErr error
+	err = walkTree(root, fsys, &cfg, func(ent *Entry) error {
+		err := fn(ent)
+		if err != nil && err != fs.SkipDir {
+			fnErr = err
 		}
-
-		// Write path
-		if _, err := bufw.WriteString(ent.RelPath); err != nil {
-			return err
-		}
-		bufw.WriteByte('\n')
+		return err
+	})
+	if err != nil && err == fnErr {
+		return err
 	}
-
-	if err := bufw.Flush(); err != nil {
-		return fmt.Errorf("can't write output: %s", err)
+	if err != nil {
+		return fmt.Errorf("dirtree: %w", err)
 	}
 	return nil
 }
 
-// walkTree walks through all files of fsys, starting at root, and returns the
-// files, in the order they're met, as entries. Use actual filesystem if fsys is
-// nil.
-func walkTree(root string, fsys fs.FS, opts ...Option) ([]*Entry, error) {
-	// Configure the walk
+// Walk walks the directory rooted at root and calls fn for each listed file, in
+// order. See WalkFS.
+func Walk(root string, fn func(e *Entry) error, opts ...Option) error {
+	return WalkFS(nil, root, fn, opts...)
+}
+
+// newConfig returns the walk configuration resulting of the application of
+// opts over the default configuration.
+func newConfig(opts []Option) (config, error) {
 	cfg := defaultCfg
 	for _, o := range opts {
 		if err := o.apply(&cfg); err != nil {
-			return nil, fmt.Errorf("configuration error: %v", err)
+			return cfg, fmt.Errorf("configuration error: %w", err)
+		}
+	}
+	if cfg.format.sep != "" {
+		cfg.format.compact = true
+	}
+	if cfg.format.compact {
+		cfg.format.align = false
+	}
+	if cfg.matchBase {
+		for i := range cfg.globs {
+			cfg.globs[i].base = true
 		}
 	}
+	return cfg, nil
+}
+
+// walkTree walks through all files of fsys, starting at root, and calls fn for
+// each file to list, in the order they're met. Use actual filesystem if fsys is
+// nil.
+//
+// The Entry passed to fn is reused, it's only valid for the duration of the
+// call. Errors returned by fn stop the walk and are returned as is, other errors
+// are reported as *WalkError.
+func walkTree(root string, fsys fs.FS, cfg *config, fn func(*Entry) error) error {
+	cfg.hardlinks = nil
+	cfg.throttler = newThrottler(cfg.throttle)
+
+	st := cfg.stats
+	if st != nil {
+		*st = Stats{}
+		start := time.Now()
+		defer func() { st.WalkTime = time.Since(start) }()
+	}
+
+	if cfg.jobs > 1 && cfg.hashing() {
+		p := newHashPipeline(cfg, fsys, fn)
+		cfg.deferHash = true
+		defer func() { cfg.deferHash = false }()
+		if err := walkTree1(root, fsys, cfg, p.add); err != nil {
+			p.wait()
+			return err
+		}
+		return p.flush()
+	}
+	return walkTree1(root, fsys, cfg, fn)
+}
+
+// walkTree1 is walkTree, without concurrent checksums.
+func walkTree1(root string, fsys fs.FS, cfg *config, fn func(*Entry) error) error {
+	st := cfg.stats
+
+	var folder *caseFolder
+	if cfg.collisions != nil {
+		folder = newCaseFolder(cfg.collisions)
+	}
 
 	walkdir := fs.WalkDir
 	seenRoot := false
+	start := root
+	if cfg.start != "" && cfg.start != root {
+		// Paths are relative to root, which is not walked itself.
+		start = cfg.start
+		seenRoot = true
+	}
 
-	if fsys == nil {
+	switch {
+	case cfg.collation != CollateBytes || cfg.follow:
+		w := &dirWalker{follow: cfg.follow, maxChain: cfg.maxChain}
+		if cfg.collation != CollateBytes {
+			w.less = cfg.collation.less()
+		}
+		if cfg.follow {
+			if cfg.loops != nil {
+				*cfg.loops = (*cfg.loops)[:0]
+			}
+			w.loop = func(path string) {
+				cfg.logf("%s: symbolic link loop, not followed", path)
+				if rel, err := filepath.Rel(root, path); err == nil && cfg.loops != nil {
+					*cfg.loops = append(*cfg.loops, filepath.ToSlash(rel))
+				}
+			}
+			w.tooLong = func(path string, n int) {
+				cfg.logf("%s: chain of %d symbolic links, not followed", path, n)
+			}
+		}
+		walkdir = func(fsys fs.FS, root string, fn fs.WalkDirFunc) error {
+			w.fsys = fsys
+			return w.walk(root, fn)
+		}
+	case fsys == nil:
 		walkdir = func(_ fs.FS, root string, fn fs.WalkDirFunc) error {
 			return filepath.WalkDir(root, fn)
 		}
 	}
 
-	entries := make([]*Entry, 0, 128)
+	var (
+		ent   Entry
+		total int64 // size of the listed files, with SizeBudget
+		less  = cfg.collation.less()
+	)
 	// Do walk
-	walk := func(fullpath string, dirent fs.DirEntry, err error) error {
+	visit := func(fullpath string, dirent fs.DirEntry, err error) error {
 		if err != nil {
-			return err
+			cfg.logf("%s: %v", fullpath, err)
+			return &WalkError{Path: fullpath, Err: err}
 		}
 
-		// Skip based on type
-		ft := filetypeFromDirEntry(dirent)
-		if cfg.types&ft == 0 {
-			return nil
+		if st != nil {
+			st.Visited++
+			if dirent.IsDir() {
+				st.Dirs++
+			}
 		}
 
 		// Exclude root
 		if !seenRoot {
 			seenRoot = true
 			if !cfg.showRoot {
+				cfg.logf("%s: skipped, root is excluded", fullpath)
+				st.skip()
 				return nil
 			}
 		}
 
+		// Skip based on type
+		ft := filetypeFromDirEntry(dirent)
+		if ft == Symlink && !cfg.follow {
+			cfg.logf("%s: symbolic link, not followed", fullpath)
+		}
+		if cfg.types&ft == 0 {
+			cfg.logf("%s: skipped, excluded by Type", fullpath)
+			st.skip()
+			return nil
+		}
+
 		// Path conversion: relative to root and slash based
 		rel, err := filepath.Rel(root, fullpath)
 		if err != nil {
-			return err
+			return &WalkError{Path: fullpath, Err: err}
 		}
 
 		// Depth check
 		if cfg.depth != 0 {
-			if len(strings.Split(rel, string(os.PathSeparator))) > cfg.depth {
+			if strings.Count(rel, string(os.PathSeparator))+1 > cfg.depth {
+				cfg.logf("%s: skipped, deeper than Depth", fullpath)
+				st.skip()
 				if dirent.IsDir() {
 					err = fs.SkipDir
 				}
@@ -157,23 +401,186 @@ func walkTree(root string, fsys fs.FS, opts ...Option) ([]*Entry, error) {
 		}
 
 		rel = filepath.ToSlash(rel)
-		if !shouldKeepPath(rel, cfg.globs) {
+		if cfg.normalize != nil {
+			rel = cfg.normalize(rel)
+		}
+		switch cursorPos(rel, cfg.cursor, less) {
+		case beforeCursor:
+			st.skip()
+			if dirent.IsDir() {
+				cfg.logf("%s: skipped, before cursor", fullpath)
+				return fs.SkipDir
+			}
+			return nil
+		case atCursor:
+			st.skip()
+			return nil
+		}
+		if !shouldKeepPath(rel, dirent.IsDir(), cfg.globs) {
+			st.skip()
+			if dirent.IsDir() && shouldPrune(rel, cfg.globs) {
+				cfg.logf("%s: skipped, pruned", fullpath)
+				return fs.SkipDir
+			}
+			cfg.logf("%s: skipped, excluded by patterns", fullpath)
 			return nil
 		}
 
-		ent, err := newEntry(cfg.mode, fsys, fullpath, ft)
-		if err != nil {
-			return fmt.Errorf("can't create Entry for %s: %s", fullpath, err)
+		ent = Entry{
+			RelPath: rel,
+			Path:    filepath.ToSlash(fullpath),
+		}
+		if err := fillEntry(&ent, cfg, fsys, root, fullpath, dirent); err != nil {
+			return &WalkError{Path: fullpath, Err: err}
 		}
-		ent.RelPath = rel
-		ent.Path = filepath.ToSlash(fullpath)
 
-		entries = append(entries, ent)
+		if cfg.pathLimit != 0 || st != nil {
+			n := utf8.RuneCountInString(rel)
+			ent.TooLong = cfg.pathLimit != 0 && n > cfg.pathLimit
+			if st != nil && n > st.MaxPathLen {
+				st.MaxPathLen = n
+			}
+		}
+		if st != nil {
+			st.Listed++
+			if ent.Type == File {
+				st.Files++
+				st.Bytes += ent.Size
+			}
+		}
+		if cfg.budget != 0 && ent.Type == File {
+			total += ent.Size
+			if total > cfg.budget {
+				err := fmt.Errorf("%w: %d bytes listed, budget is %d bytes", ErrOverBudget, total, cfg.budget)
+				return &WalkError{Path: fullpath, Err: err}
+			}
+		}
+		if folder != nil {
+			folder.add(rel)
+		}
+		return fn(&ent)
+	}
+
+	var (
+		rootDev  uint64
+		checkDev = cfg.oneFS
+		gi       *gitignores
+	)
+	if cfg.gitignore {
+		gi = &gitignores{}
+	}
+	if start != root {
+		if err := walkAncestors(root, start, fsys, checkDev, &rootDev, gi); err != nil {
+			return err
+		}
+	}
+	var deadline time.Time
+	if cfg.deadline != 0 {
+		deadline = time.Now().Add(cfg.deadline)
+	}
+	walk := func(fullpath string, dirent fs.DirEntry, err error) error {
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			err := fmt.Errorf("%w: walk lasted more than %v", ErrDeadlineExceeded, cfg.deadline)
+			return &WalkError{Path: fullpath, Err: err}
+		}
+		if gi != nil && err == nil {
+			rel, err := filepath.Rel(root, fullpath)
+			if err != nil {
+				return &WalkError{Path: fullpath, Err: err}
+			}
+			if gi.ignored(filepath.ToSlash(rel), dirent.IsDir()) {
+				if st != nil {
+					st.Visited++
+				}
+				st.skip()
+				if dirent.IsDir() {
+					cfg.logf("%s: skipped, pruned by .gitignore", fullpath)
+					return fs.SkipDir
+				}
+				cfg.logf("%s: skipped, ignored by .gitignore", fullpath)
+				return nil
+			}
+		}
+		if err := visit(fullpath, dirent, err); err != nil {
+			return err
+		}
+		if !dirent.IsDir() {
+			return nil
+		}
+		if filetypeFromDirEntry(dirent) == Symlink {
+			// Don't descend into symbolic links to directories, like
+			// Windows junctions.
+			return fs.SkipDir
+		}
+		if checkDev {
+			fi, err := dirent.Info()
+			if err != nil {
+				return &WalkError{Path: fullpath, Err: err}
+			}
+			id, _, ok := fileID(fi)
+			switch {
+			case !ok:
+				// Device numbers are not available, don't check further.
+				checkDev = false
+			case fullpath == root:
+				rootDev = id.dev
+			case id.dev != rootDev:
+				cfg.logf("%s: not descending, mount point", fullpath)
+				return fs.SkipDir
+			}
+		}
+		if gi != nil {
+			rel, _ := filepath.Rel(root, fullpath)
+			if err := gi.load(fsys, fullpath, filepath.ToSlash(rel)); err != nil {
+				return &WalkError{Path: fullpath, Err: err}
+			}
+		}
+		if cfg.descend != nil {
+			cfg.descend(fullpath)
+		}
 		return nil
 	}
 
-	if err := walkdir(fsys, root, walk); err != nil {
-		return nil, fmt.Errorf("error walking directory: %v", err)
+	return walkdir(fsys, start, walk)
+}
+
+// walkAncestors gathers what a walk starting at start, below root, would have
+// met on its way from root: the device of root, if checkDev is true, and the
+// .gitignore files of the directories above start, if gi is not nil.
+func walkAncestors(root, start string, fsys fs.FS, checkDev bool, rootDev *uint64, gi *gitignores) error {
+	if checkDev {
+		var (
+			fi  fs.FileInfo
+			err error
+		)
+		if fsys == nil {
+			fi, err = os.Stat(root)
+		} else {
+			fi, err = fs.Stat(fsys, root)
+		}
+		if err != nil {
+			return &WalkError{Path: root, Err: err}
+		}
+		id, _, _ := fileID(fi)
+		*rootDev = id.dev
 	}
-	return entries, nil
+	if gi == nil {
+		return nil
+	}
+	rel, err := filepath.Rel(root, start)
+	if err != nil {
+		return &WalkError{Path: start, Err: err}
+	}
+	elems := strings.Split(filepath.ToSlash(rel), "/")
+	dir, reldir := root, "."
+	for i, elem := range elems {
+		if err := gi.load(fsys, dir, reldir); err != nil {
+			return &WalkError{Path: dir, Err: err}
+		}
+		if i == len(elems)-1 {
+			break
+		}
+		dir, reldir = filepath.Join(dir, elem), path.Join(reldir, elem)
+	}
+	return nil
 }
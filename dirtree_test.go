@@ -1,12 +1,22 @@
 package dirtree
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"io/fs"
+	"math/rand"
+	"os"
+	"path"
 	"path/filepath"
+	"reflect"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"testing/fstest"
+	"time"
 )
 
 var tests = []struct {
@@ -14,6 +24,8 @@ var tests = []struct {
 	opts    []Option
 	want    []string
 	wantErr bool
+
+	annotated bool // want has annotations, only printed after paths by Entry.String
 }{
 	{
 		name: "default",
@@ -22,9 +34,9 @@ var tests = []struct {
 			"d            .",
 			"d            A",
 			"d            A/B",
-			"?            A/B/symdirA",
+			"l            A/B/symdirA",
 			"f 13b        A/file1",
-			"?            A/symfile1",
+			"l            A/symfile1",
 		},
 	},
 	{
@@ -41,8 +53,28 @@ var tests = []struct {
 			"d            .",
 			"d            A",
 			"d            A/B",
-			"?            A/B/symdirA",
-			"?            A/symfile1",
+			"l            A/B/symdirA",
+			"l            A/symfile1",
+		},
+	},
+	{
+		name: "only symlinks",
+		opts: []Option{Type("l")},
+		want: []string{
+			"l            A/B/symdirA",
+			"l            A/symfile1",
+		},
+	},
+	{
+		name: "type names",
+		opts: []Option{ModeType, TypeNames{Dir: "DIR", Symlink: "LNK"}},
+		want: []string{
+			"DIR .",
+			"DIR A",
+			"DIR A/B",
+			"LNK A/B/symdirA",
+			"f   A/file1",
+			"LNK A/symfile1",
 		},
 	},
 	{
@@ -52,9 +84,9 @@ var tests = []struct {
 			"d            crc=n/a      .",
 			"d            crc=n/a      A",
 			"d            crc=n/a      A/B",
-			"?            crc=n/a      A/B/symdirA",
+			"l            crc=n/a      A/B/symdirA",
 			"f 13b        crc=0451ac5e A/file1",
-			"?            crc=n/a      A/symfile1",
+			"l            crc=n/a      A/symfile1",
 		},
 	},
 	{
@@ -63,9 +95,16 @@ var tests = []struct {
 		want: []string{
 			"d            A",
 			"d            A/B",
-			"?            A/B/symdirA",
+			"l            A/B/symdirA",
+			"f 13b        A/file1",
+			"l            A/symfile1",
+		},
+	},
+	{
+		name: "exclude root, only files",
+		opts: []Option{Type("f"), ExcludeRoot},
+		want: []string{
 			"f 13b        A/file1",
-			"?            A/symfile1",
 		},
 	},
 	{
@@ -87,8 +126,8 @@ var tests = []struct {
 			"d            .",
 			"d            A",
 			"d            A/B",
-			"?            A/B/symdirA",
-			"?            A/symfile1",
+			"l            A/B/symdirA",
+			"l            A/symfile1",
 		},
 	},
 	{
@@ -97,8 +136,37 @@ var tests = []struct {
 		want: []string{
 			"d            .",
 			"d            A/B",
-			"?            A/B/symdirA",
-			"?            A/symfile1",
+			"l            A/B/symdirA",
+			"l            A/symfile1",
+		},
+	},
+	{
+		name: `dir-only ignore`,
+		opts: []Option{Ignore("A/*/"), Ignore("*/sym*/")},
+		want: []string{
+			"d            .",
+			"d            A",
+			"l            A/B/symdirA",
+			"f 13b        A/file1",
+			"l            A/symfile1",
+		},
+	},
+	{
+		name: `match base`,
+		opts: []Option{MatchBase(true), Ignore("sym*"), Match("[AB]"), Match("file?")},
+		want: []string{
+			"d            A",
+			"d            A/B",
+			"f 13b        A/file1",
+		},
+	},
+	{
+		name: `prune`,
+		opts: []Option{Prune("A/B"), Prune("*/file1")},
+		want: []string{
+			"d            .",
+			"d            A",
+			"l            A/symfile1",
 		},
 	},
 	{
@@ -107,7 +175,7 @@ var tests = []struct {
 		want: []string{
 			"d            A/B",
 			"f 13b        A/file1",
-			"?            A/symfile1",
+			"l            A/symfile1",
 		},
 	},
 	{
@@ -116,7 +184,7 @@ var tests = []struct {
 		want: []string{
 			"d            A/B",
 			"f 13b        A/file1",
-			"?            A/symfile1",
+			"l            A/symfile1",
 		},
 	},
 	{
@@ -124,7 +192,7 @@ var tests = []struct {
 		opts: []Option{Ignore("*/*B"), Match("*/*[1B]")},
 		want: []string{
 			"f 13b        A/file1",
-			"?            A/symfile1",
+			"l            A/symfile1",
 		},
 	},
 	{
@@ -132,7 +200,7 @@ var tests = []struct {
 		opts: []Option{Match("*/*[1B]"), Ignore("*/*B")},
 		want: []string{
 			"f 13b        A/file1",
-			"?            A/symfile1",
+			"l            A/symfile1",
 		},
 	},
 	{
@@ -150,7 +218,46 @@ var tests = []struct {
 			"d A",
 			"d A/B",
 			"f A/file1",
-			"? A/symfile1",
+			"l A/symfile1",
+		},
+	},
+	{
+		name: "buffer size",
+		opts: []Option{ModeType, BufferSize(1)},
+		want: []string{
+			"d .",
+			"d A",
+			"d A/B",
+			"l A/B/symdirA",
+			"f A/file1",
+			"l A/symfile1",
+		},
+	},
+	{
+		name:      "path limit",
+		annotated: true,
+		opts:      []Option{ModeType, PathLimit(3)},
+		want: []string{
+			"d .",
+			"d A",
+			"d A/B",
+			"l A/B/symdirA (path too long)",
+			"f A/file1 (path too long)",
+			"l A/symfile1 (path too long)",
+		},
+	},
+	{
+		name: "hash limit",
+		opts: []Option{Type("f"), ModeCRC32, HashLimit(5)},
+		want: []string{
+			"crc~=4ff4f23f A/file1",
+		},
+	},
+	{
+		name: "hash limit above size",
+		opts: []Option{Type("f"), ModeCRC32, HashLimit(13)},
+		want: []string{
+			"crc=0451ac5e A/file1",
 		},
 	},
 
@@ -175,6 +282,26 @@ var tests = []struct {
 		opts:    []Option{Depth(-1)},
 		wantErr: true,
 	},
+	{
+		name:    "empty type name",
+		opts:    []Option{TypeNames{Dir: ""}},
+		wantErr: true,
+	},
+	{
+		name:    "invalid type name key",
+		opts:    []Option{TypeNames{Dir | File: "x"}},
+		wantErr: true,
+	},
+	{
+		name:    "zero buffer size",
+		opts:    []Option{BufferSize(0)},
+		wantErr: true,
+	},
+	{
+		name:    "negative hash limit",
+		opts:    []Option{HashLimit(-1)},
+		wantErr: true,
+	},
 }
 
 func TestSprint(t *testing.T) {
@@ -246,6 +373,9 @@ func TestList(t *testing.T) {
 			}
 
 			for i, f := range list {
+				if tt.annotated {
+					break
+				}
 				if got := f.Format() + f.RelPath; got != tt.want[i] {
 					t.Errorf("format(%d) = %q, want %q", i, got, tt.want[i])
 				}
@@ -254,6 +384,142 @@ func TestList(t *testing.T) {
 	}
 }
 
+func TestEntryString(t *testing.T) {
+	fsys := fstest.MapFS{
+		"A/file1":     &fstest.MapFile{Data: []byte("dummy content")},
+		"A/symfile1":  &fstest.MapFile{Mode: fs.ModeSymlink},
+		"A/B/symdirA": &fstest.MapFile{Mode: fs.ModeSymlink | fs.ModeDir},
+	}
+
+	for _, tt := range tests {
+		if tt.wantErr {
+			continue
+		}
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := ListFS(fsys, ".", tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(list) != len(tt.want) {
+				t.Fatalf("got %d files, want %d", len(list), len(tt.want))
+			}
+			for i, f := range list {
+				if got := f.String(); got != tt.want[i] {
+					t.Errorf("String(%d) = %q, want %q", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestListAppend(t *testing.T) {
+	fsys := fstest.MapFS{
+		"A/file1": &fstest.MapFile{Data: []byte("dummy")},
+	}
+
+	dst := make([]*Entry, 1, 8)
+	got, err := ListAppendFS(dst, fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 4 || got[0] != nil || got[3].RelPath != "A/file1" {
+		t.Fatalf("ListAppendFS() = %v, want nil followed by 3 entries", got)
+	}
+	if &got[0] != &dst[0] {
+		t.Errorf("ListAppendFS() didn't reuse dst")
+	}
+
+	// Reuse the slice.
+	again, err := ListAppendFS(got[:0], fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again) != 3 || &again[0] != &dst[0] {
+		t.Errorf("ListAppendFS() = %v, want 3 entries in dst", again)
+	}
+
+	if got, err := ListAppendFS(dst, fsys, "missing"); err == nil || len(got) != len(dst) {
+		t.Errorf("ListAppendFS() = %v, %v, want dst and an error", got, err)
+	}
+}
+
+func TestPoolEntries(t *testing.T) {
+	fsys := fstest.MapFS{
+		"A/file1": &fstest.MapFile{Data: []byte("dummy")},
+	}
+
+	want, err := SprintFS(fsys, ".", ModeAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		ents, err := ListFS(fsys, ".", ModeAll, PoolEntries(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var sb strings.Builder
+		for _, e := range ents {
+			fmt.Fprintln(&sb, e)
+		}
+		if sb.String() != want {
+			t.Errorf("got:\n%s\nwant:\n%s", sb.String(), want)
+		}
+
+		Entries(ents).Release()
+		for _, e := range ents {
+			if e != nil {
+				t.Fatalf("Release() didn't clear the entries")
+			}
+		}
+	}
+}
+
+func TestWalk(t *testing.T) {
+	fsys := fstest.MapFS{
+		"A/file1":   &fstest.MapFile{Data: []byte("dummy")},
+		"A/B/file2": &fstest.MapFile{},
+		"C/file3":   &fstest.MapFile{},
+	}
+
+	var visited []string
+	err := WalkFS(fsys, ".", func(e *Entry) error {
+		if e.DirEntry() == nil || e.DirEntry().IsDir() != e.IsDir() {
+			t.Errorf("%s: DirEntry() = %v", e.RelPath, e.DirEntry())
+		}
+		visited = append(visited, e.RelPath)
+		if e.RelPath == "A/B" {
+			return fs.SkipDir
+		}
+		return nil
+	}, ModeAll, Jobs(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(visited, " "), ". A A/B A/file1 C C/file3"; got != want {
+		t.Errorf("WalkFS() visited %q, want %q", got, want)
+	}
+
+	errStop := errors.New("stop")
+	visited = nil
+	err = WalkFS(fsys, ".", func(e *Entry) error {
+		visited = append(visited, e.RelPath)
+		if e.RelPath == "A/file1" {
+			return errStop
+		}
+		return nil
+	})
+	if err != errStop {
+		t.Errorf("WalkFS() error = %v, want %v", err, errStop)
+	}
+	if got, want := strings.Join(visited, " "), ". A A/B A/B/file2 A/file1"; got != want {
+		t.Errorf("WalkFS() visited %q, want %q", got, want)
+	}
+
+	if err := WalkFS(fsys, "missing", func(*Entry) error { return nil }); err == nil {
+		t.Errorf("WalkFS() on missing root should fail")
+	}
+}
+
 func TestListEntry(t *testing.T) {
 	list, err := List(filepath.Join("testdata", "dir"), ModeAll)
 	if err != nil {
@@ -280,24 +546,702 @@ func TestListEntry(t *testing.T) {
 	}
 }
 
+func TestErrors(t *testing.T) {
+	root := filepath.Join("testdata", "dir")
+	tests := []struct {
+		name string
+		root string
+		opts []Option
+		want error
+	}{
+		{name: "empty type", root: root, opts: []Option{Type("")}, want: ErrInvalidType},
+		{name: "invalid type char", root: root, opts: []Option{Type("fx")}, want: ErrInvalidType},
+		{name: "invalid ignore", root: root, opts: []Option{Ignore("a/b[")}, want: ErrInvalidPattern},
+		{name: "invalid match", root: root, opts: []Option{Match("a/b[")}, want: ErrInvalidPattern},
+		{name: "negative depth", root: root, opts: []Option{Depth(-1)}, want: ErrInvalidOption},
+		{name: "not exist", root: "do-not-exist", want: fs.ErrNotExist},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := List(tt.root, tt.opts...)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("List() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	t.Run("walk error", func(t *testing.T) {
+		_, err := List("do-not-exist")
+		var werr *WalkError
+		if !errors.As(err, &werr) {
+			t.Fatalf("List() error = %v, want a *WalkError", err)
+		}
+		if werr.Path != "do-not-exist" {
+			t.Errorf("WalkError.Path = %q, want %q", werr.Path, "do-not-exist")
+		}
+	})
+}
+
+type errWriter struct{}
+
+func (errWriter) Write([]byte) (int, error) { return 0, io.ErrClosedPipe }
+
+func TestWriteError(t *testing.T) {
+	err := Write(errWriter{}, filepath.Join("testdata", "dir"))
+	if !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("Write() error = %v, want %v", err, io.ErrClosedPipe)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	dir := t.TempDir()
+	nfd := "cafe\u0301" // 'é' as 'e' followed by a combining acute accent
+	touch(t, filepath.Join(dir, nfd))
+
+	list, err := List(dir, Normalize(NFC), Match("caf\u00e9"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("got %d entries, want 1", len(list))
+	}
+	if want := "caf\u00e9"; list[0].RelPath != want {
+		t.Errorf("RelPath = %q, want %q", list[0].RelPath, want)
+	}
+	if want := filepath.ToSlash(filepath.Join(dir, nfd)); list[0].Path != want {
+		t.Errorf("Path = %q, want %q", list[0].Path, want)
+	}
+}
+
+func TestIgnorePresets(t *testing.T) {
+	fsys := fstest.MapFS{
+		".git/HEAD":                     &fstest.MapFile{},
+		"main.go":                       &fstest.MapFile{},
+		"web/node_modules/pkg/index.js": &fstest.MapFile{},
+		"web/index.js":                  &fstest.MapFile{},
+		"web/.svn/entries":              &fstest.MapFile{},
+		"targets/file":                  &fstest.MapFile{},
+		"targets/dist":                  &fstest.MapFile{},
+	}
+
+	got, err := SprintFS(fsys, ".", ModeType, ExcludeRoot, IgnoreVCS, IgnoreBuildArtifacts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "f main.go\nd targets\nf targets/dist\nf targets/file\nd web\nf web/index.js\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	fsys := fstest.MapFS{
+		"A/file1": &fstest.MapFile{Data: []byte("dummy")},
+	}
+
+	got, err := SprintFS(fsys, ".", ModeSize|ModeCRC32, Template("{{.Type}} {{.Checksum}} {{.Size}} {{.RelPath}}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "d n/a 0 .\nd n/a 0 A\nf 4ff4f23f 5 A/file1\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	if _, err := SprintFS(fsys, ".", Template("{{.Unknown}}")); err == nil {
+		t.Errorf("SprintFS() with invalid template field should fail")
+	}
+	if _, err := SprintFS(fsys, ".", Template("{{")); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("SprintFS() error = %v, want ErrInvalidOption", err)
+	}
+}
+
+// countEncoder writes the number of entries in the listing.
+type countEncoder struct {
+	w io.Writer
+	n int
+}
+
+func (enc *countEncoder) Begin() error { _, err := io.WriteString(enc.w, "begin\n"); return err }
+
+func (enc *countEncoder) Entry(e *Entry) error {
+	enc.n++
+	_, err := fmt.Fprintf(enc.w, "%s\n", e.RelPath)
+	return err
+}
+
+func (enc *countEncoder) End() error {
+	_, err := fmt.Fprintf(enc.w, "end %d\n", enc.n)
+	return err
+}
+
+func TestEncoding(t *testing.T) {
+	fsys := fstest.MapFS{
+		"A/file1": &fstest.MapFile{Data: []byte("dummy")},
+	}
+
+	enc := Encoding(func(w io.Writer) Encoder { return &countEncoder{w: w} })
+	got, err := SprintFS(fsys, ".", enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "begin\n.\nA\nA/file1\nend 3\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	// The last of Template and Encoding wins.
+	got, err = SprintFS(fsys, ".", Template("{{.RelPath}}"), Encoding(NewLineEncoder))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err = SprintFS(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFormatByName(t *testing.T) {
+	fsys := fstest.MapFS{
+		"A/file1": &fstest.MapFile{Data: []byte("dummy")},
+	}
+
+	enc, err := FormatByName("json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := SprintFS(fsys, ".", ModeAll, enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ents []Entry
+	if err := json.Unmarshal([]byte(out), &ents); err != nil {
+		t.Fatalf("invalid json output: %v\n%s", err, out)
+	}
+	if len(ents) != 3 || ents[2].RelPath != "A/file1" || ents[2].Checksum != "4ff4f23f" {
+		t.Errorf("unexpected json output:\n%s", out)
+	}
+
+	if _, err := FormatByName("unknown"); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("FormatByName(unknown) error = %v, want ErrInvalidOption", err)
+	}
+
+	if _, err := FormatByName("count"); err != nil { // not registered yet with -count > 1
+		RegisterFormat("count", func(w io.Writer) Encoder { return &countEncoder{w: w} })
+	}
+	if enc, err = FormatByName("count"); err != nil {
+		t.Fatal(err)
+	}
+	if out, err = SprintFS(fsys, ".", enc); err != nil {
+		t.Fatal(err)
+	}
+	if want := "begin\n.\nA\nA/file1\nend 3\n"; out != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out, want)
+	}
+	if got, want := strings.Join(Formats(), ","), "count,json,line,sqlite-script"; got != want {
+		t.Errorf("Formats() = %s, want %s", got, want)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("RegisterFormat() with a duplicate name should panic")
+		}
+	}()
+	RegisterFormat("json", NewLineEncoder)
+}
+
+func TestAlign(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a": &fstest.MapFile{Data: []byte("dummy")},
+		"b": &fstest.MapFile{Data: make([]byte, 123)},
+	}
+
+	want := "d      .\nf 5b   a\nf 123b b\n"
+	got, err := SprintFS(fsys, ".", Align(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	ents, err := ListFS(fsys, ".", Align(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sb strings.Builder
+	for _, e := range ents {
+		fmt.Fprintln(&sb, e)
+	}
+	if sb.String() != want {
+		t.Errorf("ListFS() entries:\n%s\nwant:\n%s", sb.String(), want)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	fsys := fstest.MapFS{
+		"A/file1": &fstest.MapFile{Data: []byte("dummy")},
+	}
+
+	got, err := SprintFS(fsys, ".", ModeAll|ModeAlloc, Hash("md5"), Compact(true), Align(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `d  alloc=n/a crc=n/a md5=n/a .
+d  alloc=n/a crc=n/a md5=n/a A
+f 5b alloc=n/a crc=4ff4f23f md5=275876e34cf609db118f3d84b799a790 A/file1
+`
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestSizeUnit(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a": &fstest.MapFile{},
+		"b": &fstest.MapFile{Data: make([]byte, 1)},
+		"c": &fstest.MapFile{Data: make([]byte, 1025)},
+		"d": &fstest.MapFile{Data: make([]byte, 1<<20+1)},
+	}
+
+	tests := []struct {
+		opts []Option
+		want string
+	}{
+		{[]Option{UnitBytes}, "0b 1b 1025b 1048577b"},
+		{[]Option{UnitKiB}, "0KiB 1KiB 2KiB 1025KiB"},
+		{[]Option{UnitMiB}, "0MiB 1MiB 1MiB 2MiB"},
+		{[]Option{UnitBlocks}, "0blk 1blk 3blk 2049blk"},
+		{[]Option{UnitKiB, NoSizeSuffix(true)}, "0 1 2 1025"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			opts := append([]Option{ModeSize, Type("f")}, tt.opts...)
+			ents, err := ListFS(fsys, ".", opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var sizes []string
+			for _, e := range ents {
+				sizes = append(sizes, strings.TrimSpace(e.Format()))
+			}
+			if got := strings.Join(sizes, " "); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := SprintFS(fsys, ".", SizeUnit(42)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("SprintFS() error = %v, want ErrInvalidOption", err)
+	}
+}
+
+func TestSeparator(t *testing.T) {
+	fsys := fstest.MapFS{
+		"A/file1": &fstest.MapFile{Data: []byte("dummy")},
+	}
+
+	got, err := SprintFS(fsys, ".", ModeAll, Separator("\t"), ExcludeRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "d\t\tcrc=n/a\tA\nf\t5b\tcrc=4ff4f23f\tA/file1\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Paths are escaped.
+	fsys = fstest.MapFS{
+		"a\tb":      &fstest.MapFile{Data: []byte("x")},
+		"c\\d\ne,f": &fstest.MapFile{Data: []byte("x")},
+	}
+	for sep, want := range map[string]string{
+		"\t": "f\t1b\ta\\tb\nf\t1b\tc\\\\d\\ne,f\n",
+		",":  "f,1b,a\\tb\nf,1b,c\\\\d\\ne\\,f\n",
+	} {
+		got, err := SprintFS(fsys, ".", ModeDefault, Separator(sep), ExcludeRoot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("Separator(%q): got %q, want %q", sep, got, want)
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(got, "\n"), "\n") {
+			if n := strings.Count(line, "\t"); sep == "\t" && n != 2 {
+				t.Errorf("Separator(%q): line %q has %d separators, want 2", sep, line, n)
+			}
+		}
+	}
+
+	if _, err := SprintFS(fsys, ".", Separator("\n")); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("SprintFS() error = %v, want ErrInvalidOption", err)
+	}
+}
+
+func TestHeader(t *testing.T) {
+	fsys := fstest.MapFS{
+		"A/file1": &fstest.MapFile{Data: []byte("dummy")},
+	}
+
+	tests := []struct {
+		opts []Option
+		want string
+	}{
+		{[]Option{ModeAll}, "#dirtree/1 type size crc32 path"},
+		{[]Option{ModeSize | ModeLink, Hash("md5"), Separator(",")}, "#dirtree/1,size,link,md5,path"},
+		{[]Option{PrintMode(0)}, "#dirtree/1 path"},
+		{[]Option{ModeType | ModeCRC32, Hash("sha1"), Hash("xxh64"), Separator(" | "), FollowSymlinks(true), RespectGitIgnore(true)}, "#dirtree/1 | type | crc32 | sha1 | xxh64 | path | follow=true | gitignore=true"},
+	}
+	for _, tt := range tests {
+		got, err := SprintFS(fsys, "A", append(tt.opts, Header(true), ExcludeRoot)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.SplitN(got, "\n", 2)
+		if lines[0] != tt.want {
+			t.Errorf("header = %q, want %q", lines[0], tt.want)
+		}
+
+		// Listing again with the options of the header gives the same lines.
+		opts, err := ParseHeader(lines[0])
+		if err != nil {
+			t.Fatalf("ParseHeader(%q): %v", lines[0], err)
+		}
+		again, err := SprintFS(fsys, "A", append(opts, ExcludeRoot)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again != lines[1] {
+			t.Errorf("listing with the options of %q:\n%s\nwant:\n%s", lines[0], again, lines[1])
+		}
+	}
+
+	for _, line := range []string{"", "# dirtree mode=type", "#dirtree/2 path", "#dirtree/1 type", "#dirtree/1 size type path", "#dirtree/1 path follow=false"} {
+		if _, err := ParseHeader(line); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("ParseHeader(%q) error = %v, want ErrInvalidOption", line, err)
+		}
+	}
+
+	got, err := SprintFS(fsys, "A", Header(true), Template("{{.RelPath}}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := ".\nfile1\n"; got != want {
+		t.Errorf("with Template, got %q, want %q", got, want)
+	}
+}
+
+func TestJobs(t *testing.T) {
+	fsys := newBenchFS(3, 3, 100)
+	opts := []Option{ModeAll, Hash("sha1"), HashLimit(64)}
+
+	var stSeq, stPar Stats
+	want, err := SprintFS(fsys, ".", append(opts, &stSeq)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := SprintFS(fsys, ".", append(opts, Jobs(4), &stPar)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("Jobs(4) output differs from sequential one, got:\n%s\nwant:\n%s", got, want)
+	}
+	if stPar.BytesHashed != stSeq.BytesHashed || stPar.Listed != stSeq.Listed {
+		t.Errorf("Jobs(4) stats = %+v, want %+v", stPar, stSeq)
+	}
+
+	// Errors returned by the callback stop the walk.
+	if err := WriteFS(errWriter{}, fsys, ".", Jobs(4), ModeAll, BufferSize(1)); err == nil {
+		t.Errorf("WriteFS() should fail")
+	}
+	if _, err := SprintFS(fsys, ".", Jobs(-1)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Jobs(-1) error = %v, want ErrInvalidOption", err)
+	}
+}
+
+func TestSizeBudget(t *testing.T) {
+	fsys := fstest.MapFS{
+		"A/file1":   &fstest.MapFile{Data: []byte("dummy")},
+		"A/B/file2": &fstest.MapFile{Data: []byte("content")},
+	}
+
+	if _, err := ListFS(fsys, ".", ModeType, SizeBudget(12)); err != nil {
+		t.Errorf("SizeBudget(12) error = %v, want nil", err)
+	}
+	_, err := ListFS(fsys, ".", ModeType, SizeBudget(11))
+	if !errors.Is(err, ErrOverBudget) {
+		t.Errorf("SizeBudget(11) error = %v, want ErrOverBudget", err)
+	}
+	if _, err := ListFS(fsys, ".", SizeBudget(11), Jobs(2), ModeCRC32); !errors.Is(err, ErrOverBudget) {
+		t.Errorf("SizeBudget(11) with Jobs(2) error = %v, want ErrOverBudget", err)
+	}
+	if _, err := ListFS(fsys, ".", SizeBudget(-1)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("SizeBudget(-1) error = %v, want ErrInvalidOption", err)
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	fsys := fstest.MapFS{
+		"A/file1":   &fstest.MapFile{Data: []byte("dummy")},
+		"A/B/file2": &fstest.MapFile{Data: []byte("content")},
+	}
+
+	if _, err := ListFS(fsys, ".", Deadline(time.Hour)); err != nil {
+		t.Errorf("Deadline(time.Hour) error = %v, want nil", err)
+	}
+
+	var listed []string
+	err := WalkFS(fsys, ".", func(e *Entry) error {
+		listed = append(listed, e.RelPath)
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	}, Deadline(10*time.Millisecond))
+	if !errors.Is(err, ErrDeadlineExceeded) {
+		t.Errorf("Deadline(10ms) error = %v, want ErrDeadlineExceeded", err)
+	}
+	if !reflect.DeepEqual(listed, []string{"."}) {
+		t.Errorf("Deadline(10ms) listed %q, want only the root", listed)
+	}
+
+	if _, err := ListFS(fsys, ".", Deadline(-1)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Deadline(-1) error = %v, want ErrInvalidOption", err)
+	}
+}
+
+func TestStats(t *testing.T) {
+	var st Stats
+	if _, err := List(filepath.Join("testdata", "dir"), Type("f"), ModeAll, &st); err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+
+	if st.Visited != 6 || st.Dirs != 3 || st.Listed != 1 || st.Skipped != 5 {
+		t.Errorf("got (visited=%d dirs=%d listed=%d skipped=%d), want (visited=6 dirs=3 listed=1 skipped=5)",
+			st.Visited, st.Dirs, st.Listed, st.Skipped)
+	}
+	if st.Files != 1 || st.Bytes != 13 {
+		t.Errorf("got (files=%d bytes=%d), want (files=1 bytes=13)", st.Files, st.Bytes)
+	}
+	if st.MaxPathLen != len("A/file1") {
+		t.Errorf("MaxPathLen = %d, want %d", st.MaxPathLen, len("A/file1"))
+	}
+	if st.BytesHashed != 13 {
+		t.Errorf("BytesHashed = %d, want 13", st.BytesHashed)
+	}
+	if st.WalkTime <= 0 {
+		t.Errorf("WalkTime = %v, want > 0", st.WalkTime)
+	}
+}
+
+func TestLogFunc(t *testing.T) {
+	var logs []string
+	logf := func(format string, args ...interface{}) {
+		logs = append(logs, fmt.Sprintf(format, args...))
+	}
+
+	root := filepath.Join("testdata", "dir")
+	if _, err := List(root, Type("d?"), Ignore("A/B"), LogFunc(logf)); err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+
+	want := []string{
+		filepath.Join(root, "A", "B") + ": skipped, excluded by patterns",
+		filepath.Join(root, "A", "B", "symdirA") + ": symbolic link, not followed",
+		filepath.Join(root, "A", "file1") + ": skipped, excluded by Type",
+		filepath.Join(root, "A", "symfile1") + ": symbolic link, not followed",
+	}
+	if got := strings.Join(logs, "\n"); got != strings.Join(want, "\n") {
+		t.Errorf("invalid logs:\ngot:\n%s\n\nwant:\n%s", got, strings.Join(want, "\n"))
+	}
+}
+
+// timeoutFS is a fstest.MapFS failing to open files with a timeout.
+type timeoutFS struct{ fstest.MapFS }
+
+func (fsys timeoutFS) Open(name string) (fs.File, error) {
+	if fi, err := fs.Stat(fsys.MapFS, name); err == nil && !fi.IsDir() {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: os.ErrDeadlineExceeded}
+	}
+	return fsys.MapFS.Open(name)
+}
+
+func TestLogFuncJobs(t *testing.T) {
+	fsys := timeoutFS{fstest.MapFS{}}
+	for i := 0; i < 32; i++ {
+		fsys.MapFS[fmt.Sprintf("file%02d", i)] = &fstest.MapFile{Data: []byte("dummy")}
+	}
+
+	// Files are retried, and logged, by several goroutines.
+	var (
+		calls      int
+		inflight   int32
+		concurrent int32
+	)
+	logf := func(format string, args ...interface{}) {
+		if atomic.AddInt32(&inflight, 1) > 1 {
+			atomic.StoreInt32(&concurrent, 1)
+		}
+		time.Sleep(time.Millisecond)
+		calls++
+		atomic.AddInt32(&inflight, -1)
+	}
+	if _, err := ListFS(fsys, ".", ModeCRC32, Jobs(8), Retry{Count: 1}, LogFunc(logf)); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&concurrent) != 0 {
+		t.Errorf("LogFunc called concurrently")
+	}
+	if want := 2 * 32; calls != want {
+		t.Errorf("LogFunc called %d times, want %d", calls, want)
+	}
+}
+
+// benchFS is an in-memory fs.ReadDirFS in which directory reads are a map
+// lookup, so that benchmarks don't measure the linear scan of
+// fstest.MapFS.ReadDir.
+type benchFS struct {
+	fstest.MapFS
+	dirs map[string][]fs.DirEntry
+}
+
+// newBenchFS returns a tree in which each directory holds a file of fileSize
+// random bytes, and fanout subdirectories, down to depth levels.
+func newBenchFS(fanout, depth, fileSize int) *benchFS {
+	fsys := &benchFS{MapFS: fstest.MapFS{}, dirs: make(map[string][]fs.DirEntry)}
+	rnd := rand.New(rand.NewSource(1))
+	add := func(dir, name string, f *fstest.MapFile) string {
+		p := path.Join(dir, name)
+		fsys.MapFS[p] = f
+		fsys.dirs[dir] = append(fsys.dirs[dir], infoDirEntry{memFileInfo{name, f}})
+		return p
+	}
+
+	var fill func(dir string, level int)
+	fill = func(dir string, level int) {
+		if level < depth {
+			for i := 0; i < fanout; i++ {
+				sub := add(dir, string(rune('A'+level))+strconv.Itoa(i), &fstest.MapFile{Mode: fs.ModeDir})
+				fill(sub, level+1)
+			}
+		}
+		// Added last since lower case letters sort after upper case ones.
+		data := make([]byte, fileSize)
+		rnd.Read(data)
+		add(dir, "file", &fstest.MapFile{Data: data})
+	}
+	fill(".", 0)
+	return fsys
+}
+
+func (fsys *benchFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if ents, ok := fsys.dirs[name]; ok {
+		return ents, nil
+	}
+	return fsys.MapFS.ReadDir(name)
+}
+
+// memFileInfo is the fs.FileInfo of a fstest.MapFile.
+type memFileInfo struct {
+	name string
+	f    *fstest.MapFile
+}
+
+func (i memFileInfo) Name() string       { return i.name }
+func (i memFileInfo) Size() int64        { return int64(len(i.f.Data)) }
+func (i memFileInfo) Mode() fs.FileMode  { return i.f.Mode }
+func (i memFileInfo) ModTime() time.Time { return i.f.ModTime }
+func (i memFileInfo) IsDir() bool        { return i.f.Mode.IsDir() }
+func (i memFileInfo) Sys() interface{}   { return i.f.Sys }
+
 func BenchmarkWrite(b *testing.B) {
-	/*
-		This benchmarks runs on a directory structure of 11110 directories and
-		11110 files, filled with 1024b of random data, created with:
-
-		ulimit -S -n 20000
-		cd $(mktemp -d)
-		mkdir -p A{0,1,2,3,4,5,6,7,8,9}/B{0,1,2,3,4,5,6,7,8,9}/C{0,1,2,3,4,5,6,7,8,9}/D{0,1,2,3,4,5,6,7,8,9}
-		head -c 1024 /dev/urandom | tee A{0,1,2,3,4,5,6,7,8,9}/B{0,1,2,3,4,5,6,7,8,9}/C{0,1,2,3,4,5,6,7,8,9}/D{0,1,2,3,4,5,6,7,8,9}/file > /dev/null
-		head -c 1024 /dev/urandom | tee A{0,1,2,3,4,5,6,7,8,9}/B{0,1,2,3,4,5,6,7,8,9}/C{0,1,2,3,4,5,6,7,8,9}/file > /dev/null
-		head -c 1024 /dev/urandom | tee A{0,1,2,3,4,5,6,7,8,9}/B{0,1,2,3,4,5,6,7,8,9}/file > /dev/null
-		head -c 1024 /dev/urandom | tee A{0,1,2,3,4,5,6,7,8,9}/file > /dev/null
-	*/
-
-	const dir = "/tmp/tmp.YGmxHbsmj1"
-	b.ReportAllocs()
-
-	for n := 0; n < b.N; n++ {
-		Write(io.Discard, dir, Depth(2))
+	sizes := []struct {
+		name          string
+		fanout, depth int
+	}{
+		{"small", 4, 3},
+		{"large", 10, 4},
+	}
+	modes := []struct {
+		name string
+		mode PrintMode
+	}{
+		{"default", ModeDefault},
+		{"all", ModeAll},
+	}
+
+	for _, sz := range sizes {
+		fsys := newBenchFS(sz.fanout, sz.depth, 1024)
+		for _, m := range modes {
+			b.Run(sz.name+"/"+m.name, func(b *testing.B) {
+				b.ReportAllocs()
+				for n := 0; n < b.N; n++ {
+					if err := WriteFS(io.Discard, fsys, ".", m.mode); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkListAppend(b *testing.B) {
+	fsys := newBenchFS(10, 4, 1024)
+	for _, pool := range []bool{false, true} {
+		b.Run(fmt.Sprintf("pool=%t", pool), func(b *testing.B) {
+			b.ReportAllocs()
+			var ents []*Entry
+			for n := 0; n < b.N; n++ {
+				var err error
+				if ents, err = ListAppendFS(ents[:0], fsys, ".", PoolEntries(pool)); err != nil {
+					b.Fatal(err)
+				}
+				if pool {
+					Entries(ents).Release()
+				}
+			}
+		})
+	}
+}
+
+func TestMarkEmpty(t *testing.T) {
+	fsys := fstest.MapFS{
+		"A/empty":       &fstest.MapFile{},
+		"A/file1":       &fstest.MapFile{Data: []byte("dummy")},
+		"B/C":           &fstest.MapFile{Mode: fs.ModeDir},
+		"D/ignored.tmp": &fstest.MapFile{Data: []byte("dummy")},
+	}
+
+	got, err := SprintFS(fsys, ".", ModeType, MarkEmpty(true), Ignore("*/*.tmp"))
+	if err != nil {
+		t.Fatalf("SprintFS() error = %v", err)
+	}
+	want := strings.Join([]string{
+		"d .",
+		"d A",
+		"f A/empty (empty)",
+		"f A/file1",
+		"d B",
+		"d B/C (empty)",
+		"d D (empty)",
+	}, "\n") + "\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	var flagged []string
+	err = WalkFS(fsys, ".", func(e *Entry) error {
+		if e.Empty {
+			flagged = append(flagged, e.RelPath)
+		}
+		return nil
+	}, MarkEmpty(true))
+	if err != nil {
+		t.Fatalf("WalkFS() error = %v", err)
+	}
+	if !reflect.DeepEqual(flagged, []string{"A/empty"}) {
+		t.Errorf("WalkFS flagged %q, want only files", flagged)
 	}
 }
@@ -0,0 +1,60 @@
+package dirtreetest
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/arl/dirtree"
+)
+
+// Check lists the root directory of fsys with the given options, and checks
+// that the listing has exactly the want lines, in order, as printed by
+// dirtree.Write. Check is meant to validate embedded filesystems, generated
+// artifacts and the like, in the spirit of fstest.TestFS:
+//
+//	if err := dirtreetest.Check(assets, []string{"d .", "f index.html"}, dirtree.ModeType); err != nil {
+//		t.Fatal(err)
+//	}
+//
+// The returned error describes every mismatch, one per line.
+func Check(fsys fs.FS, want []string, opts ...dirtree.Option) error {
+	ents, err := dirtree.ListFS(fsys, ".", opts...)
+	if err != nil {
+		return err
+	}
+	got := make([]string, len(ents))
+	for i, e := range ents {
+		got[i] = e.String()
+	}
+
+	gotSet := make(map[string]bool, len(got))
+	for _, l := range got {
+		gotSet[l] = true
+	}
+	wantSet := make(map[string]bool, len(want))
+	for _, l := range want {
+		wantSet[l] = true
+	}
+
+	var errs []string
+	for _, l := range want {
+		if !gotSet[l] {
+			errs = append(errs, fmt.Sprintf("missing: %s", l))
+		}
+	}
+	for _, l := range got {
+		if !wantSet[l] {
+			errs = append(errs, fmt.Sprintf("unexpected: %s", l))
+		}
+	}
+	if len(errs) == 0 && strings.Join(got, "\n") != strings.Join(want, "\n") {
+		errs = append(errs, "entries listed in a different order")
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errors.New("dirtreetest: listing mismatch:\n\t" + strings.Join(errs, "\n\t"))
+}
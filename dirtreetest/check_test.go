@@ -0,0 +1,31 @@
+package dirtreetest
+
+import (
+	"testing"
+
+	"github.com/arl/dirtree"
+)
+
+func TestCheck(t *testing.T) {
+	fsys := MapFS(t, spec)
+
+	want := []string{"d .", "d dir", "f dir/file", "l dir/link", "d other", "f other/nested"}
+	if err := Check(fsys, want, dirtree.ModeType); err != nil {
+		t.Errorf("Check() error = %v", err)
+	}
+
+	want = []string{"d .", "d dir", "f dir/file", "f dir/link", "d other", "f other/missing"}
+	wantErr := "dirtreetest: listing mismatch:\n" +
+		"\tmissing: f dir/link\n" +
+		"\tmissing: f other/missing\n" +
+		"\tunexpected: l dir/link\n" +
+		"\tunexpected: f other/nested"
+	if err := Check(fsys, want, dirtree.ModeType); err == nil || err.Error() != wantErr {
+		t.Errorf("Check() error = %v, want %q", err, wantErr)
+	}
+
+	want = []string{"d .", "d other", "f other/nested", "d dir", "f dir/file", "l dir/link"}
+	if err := Check(fsys, want, dirtree.ModeType); err == nil {
+		t.Errorf("Check() should fail on different order")
+	}
+}
@@ -0,0 +1,128 @@
+// Package dirtreetest provides helpers for tests dealing with directory trees,
+// based on the dirtree package.
+package dirtreetest
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// Parse parses a tree description into an fstest.MapFS. The description uses
+// the same format as the listings printed by dirtree with ModeType, that is one
+// file per line, made of a type char and a slash-separated path relative to the
+// root, for example:
+//
+//	d .
+//	d dir
+//	f dir/file
+//	l dir/link -> file
+//
+// Supported types are 'f' for empty regular files, 'd' for directories and 'l'
+// for symbolic links, which target follows " -> ". The root directory ('.')
+// and blank lines are ignored, parent directories are implicitly created.
+func Parse(spec string) (fstest.MapFS, error) {
+	fsys := fstest.MapFS{}
+	sc := bufio.NewScanner(strings.NewReader(spec))
+	for lineno := 1; sc.Scan(); lineno++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+
+		i := strings.Index(line, " ")
+		if i != 1 {
+			return nil, fmt.Errorf("line %d: invalid line %q, want \"<type> <path>\"", lineno, line)
+		}
+		typ, name := line[:i], line[i+1:]
+		var target string
+		if typ == "l" {
+			i := strings.Index(name, " -> ")
+			if i < 0 {
+				return nil, fmt.Errorf("line %d: symbolic link %q without target", lineno, name)
+			}
+			name, target = name[:i], name[i+len(" -> "):]
+		}
+		if name == "." {
+			continue
+		}
+		if !fs.ValidPath(name) {
+			return nil, fmt.Errorf("line %d: invalid path %q", lineno, name)
+		}
+		if _, ok := fsys[name]; ok {
+			return nil, fmt.Errorf("line %d: duplicate path %q", lineno, name)
+		}
+
+		switch typ {
+		case "f":
+			fsys[name] = &fstest.MapFile{Mode: 0o644}
+		case "d":
+			fsys[name] = &fstest.MapFile{Mode: fs.ModeDir | 0o755}
+		case "l":
+			fsys[name] = &fstest.MapFile{Mode: fs.ModeSymlink | 0o777, Data: []byte(target)}
+		default:
+			return nil, fmt.Errorf("line %d: unsupported type %q, must be one of fdl", lineno, typ)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return fsys, nil
+}
+
+// MapFS is like Parse but calls t.Fatal in case of error.
+func MapFS(t testing.TB, spec string) fstest.MapFS {
+	t.Helper()
+	fsys, err := Parse(spec)
+	if err != nil {
+		t.Fatalf("dirtreetest: %v", err)
+	}
+	return fsys
+}
+
+// Create creates the tree described by spec, in the format accepted by Parse,
+// in a temporary directory which is removed at the end of the test, and
+// returns the path to that directory.
+func Create(t testing.TB, spec string) string {
+	t.Helper()
+	fsys := MapFS(t, spec)
+	root := t.TempDir()
+	for _, name := range sortedPaths(fsys) {
+		f := fsys[name]
+		dst := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			t.Fatalf("dirtreetest: %v", err)
+		}
+
+		var err error
+		switch {
+		case f.Mode.IsDir():
+			err = os.Mkdir(dst, f.Mode.Perm())
+		case f.Mode&fs.ModeSymlink != 0:
+			err = os.Symlink(filepath.FromSlash(string(f.Data)), dst)
+		default:
+			err = os.WriteFile(dst, f.Data, f.Mode.Perm())
+		}
+		if err != nil {
+			t.Fatalf("dirtreetest: %v", err)
+		}
+	}
+	return root
+}
+
+// sortedPaths returns the paths of fsys, sorted so that parents come before
+// their children.
+func sortedPaths(fsys fstest.MapFS) []string {
+	paths := make([]string, 0, len(fsys))
+	for name := range fsys {
+		paths = append(paths, name)
+	}
+	sort.Strings(paths)
+	return paths
+}
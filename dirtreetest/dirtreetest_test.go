@@ -0,0 +1,62 @@
+package dirtreetest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/arl/dirtree"
+)
+
+const spec = `
+d .
+d dir
+f dir/file
+l dir/link -> file
+f other/nested
+`
+
+const listing = `d .
+d dir
+f dir/file
+l dir/link
+d other
+f other/nested
+`
+
+func TestMapFS(t *testing.T) {
+	got, err := dirtree.SprintFS(MapFS(t, spec), ".", dirtree.ModeType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != listing {
+		t.Errorf("got:\n%s\nwant:\n%s", got, listing)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	got, err := dirtree.Sprint(Create(t, spec), dirtree.ModeType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != listing {
+		t.Errorf("got:\n%s\nwant:\n%s", got, listing)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		spec, err string
+	}{
+		{spec: "dir", err: "invalid line"},
+		{spec: "x dir", err: "unsupported type"},
+		{spec: "l link", err: "without target"},
+		{spec: "f ../file", err: "invalid path"},
+		{spec: "f file\nd file", err: "duplicate path"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.spec)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Parse(%q) error = %v, want %q", tt.spec, err, tt.err)
+		}
+	}
+}
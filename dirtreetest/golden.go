@@ -0,0 +1,95 @@
+package dirtreetest
+
+import (
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/arl/dirtree"
+)
+
+var update = flag.Bool("dirtree.update", false, "update dirtree golden files")
+
+// Golden compares the listing of the directory rooted at root in fsys, or in
+// the OS filesystem if fsys is nil, with the content of the golden file. The
+// test fails, with a diff of both listings, if they differ.
+//
+// When the test binary is run with the -dirtree.update flag, the golden file is
+// instead overwritten with the current listing:
+//
+//	go test -run TestSomething -dirtree.update
+func Golden(t testing.TB, fsys fs.FS, root, golden string, opts ...dirtree.Option) {
+	t.Helper()
+
+	got, err := dirtree.SprintFS(fsys, root, opts...)
+	if err != nil {
+		t.Fatalf("dirtreetest: %v", err)
+	}
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(golden), 0o755); err != nil {
+			t.Fatalf("dirtreetest: %v", err)
+		}
+		if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+			t.Fatalf("dirtreetest: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("dirtreetest: %v (run with -dirtree.update to create it)", err)
+	}
+	if got != string(want) {
+		t.Errorf("listing of %s differs from %s (-want +got):\n%s\nrun with -dirtree.update to update it",
+			root, golden, diff(string(want), got))
+	}
+}
+
+// diff returns a line-based diff of a and b, in which lines only present in a
+// are prefixed with '-', lines only present in b with '+', and common lines
+// with a space.
+func diff(a, b string) string {
+	al := strings.Split(strings.TrimSuffix(a, "\n"), "\n")
+	bl := strings.Split(strings.TrimSuffix(b, "\n"), "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of al[i:]
+	// and bl[j:].
+	lcs := make([][]int, len(al)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bl)+1)
+	}
+	for i := len(al) - 1; i >= 0; i-- {
+		for j := len(bl) - 1; j >= 0; j-- {
+			switch {
+			case al[i] == bl[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var sb strings.Builder
+	i, j := 0, 0
+	for i < len(al) || j < len(bl) {
+		switch {
+		case i < len(al) && j < len(bl) && al[i] == bl[j]:
+			sb.WriteString("  " + al[i] + "\n")
+			i++
+			j++
+		case j == len(bl) || (i < len(al) && lcs[i+1][j] >= lcs[i][j+1]):
+			sb.WriteString("- " + al[i] + "\n")
+			i++
+		default:
+			sb.WriteString("+ " + bl[j] + "\n")
+			j++
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,31 @@
+package dirtreetest
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/arl/dirtree"
+)
+
+func TestGolden(t *testing.T) {
+	Golden(t, MapFS(t, spec), ".", filepath.Join("testdata", "spec.golden"), dirtree.ModeType)
+}
+
+func TestGoldenUpdate(t *testing.T) {
+	golden := filepath.Join(t.TempDir(), "sub", "file.golden")
+
+	*update = true
+	Golden(t, MapFS(t, spec), ".", golden, dirtree.ModeType)
+	*update = false
+
+	Golden(t, MapFS(t, spec), ".", golden, dirtree.ModeType)
+}
+
+func Test_diff(t *testing.T) {
+	a := "d .\nf a\nf b\nf c\n"
+	b := "d .\nf b\nf c\nf d\n"
+	want := "  d .\n- f a\n  f b\n  f c\n+ f d\n"
+	if got := diff(a, b); got != want {
+		t.Errorf("diff() =\n%s\nwant:\n%s", got, want)
+	}
+}
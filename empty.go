@@ -0,0 +1,27 @@
+package dirtree
+
+// The MarkEmpty option, when true, flags empty regular files and directories
+// having no listed content with an "(empty)" annotation printed after the path,
+// so that placeholders and truncated outputs stand out in trees of artifacts.
+// Sizes are gathered even without ModeSize.
+//
+// A directory is empty when none of its content survived the options filtering
+// the listing, such as Ignore, Type or Depth. Since directories come before
+// their content, Write and WriteFS don't print anything before the walk is
+// over. With Walk and WalkFS, only files are flagged.
+type MarkEmpty bool
+
+func (me MarkEmpty) apply(cfg *config) error {
+	cfg.markEmpty = bool(me)
+	return nil
+}
+
+// markEmptyDirs flags the directories of ents which have no listed content.
+func markEmptyDirs(ents []*Entry) {
+	Build(ents).Walk(func(n *Node) error {
+		if n.Entry != nil && n.Entry.IsDir() {
+			n.Entry.Empty = len(n.Children) == 0
+		}
+		return nil
+	})
+}
@@ -0,0 +1,190 @@
+package dirtree
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/template"
+)
+
+// An Encoder writes the listing printed by Write, WriteFS, Sprint and SprintFS.
+// Begin is called once before the walk starts, Entry for each listed file, in
+// order, and End once the walk is over, unless it failed.
+//
+// The Entry passed to Entry is reused by the walk, it's only valid for the
+// duration of the call.
+type Encoder interface {
+	Begin() error
+	Entry(e *Entry) error
+	End() error
+}
+
+// The Encoding option sets the function creating the Encoder used to write a
+// listing into w. The writer passed is buffered, Encoder doesn't have to.
+//
+//	dirtree.Write(os.Stdout, "dir", dirtree.Encoding(newMyEncoder))
+//
+// Encoding and Template override each other, the last one provided wins.
+type Encoding func(w io.Writer) Encoder
+
+func (fn Encoding) apply(cfg *config) error {
+	cfg.encoding = fn
+	return nil
+}
+
+// NewLineEncoder returns the default Encoder, which writes into w one line per
+// file, as returned by Entry.String.
+func NewLineEncoder(w io.Writer) Encoder {
+	return &lineEncoder{w: w}
+}
+
+type lineEncoder struct {
+	w      io.Writer
+	buf    []byte // reused to format each line
+	header []byte // written by Begin, see the Header option
+}
+
+// A headerEncoder is an Encoder writing a header line, with the Header
+// option.
+type headerEncoder interface {
+	setHeader(header []byte)
+}
+
+func (enc *lineEncoder) setHeader(header []byte) { enc.header = header }
+
+func (enc *lineEncoder) Begin() error {
+	if enc.header == nil {
+		return nil
+	}
+	_, err := enc.w.Write(append(enc.header, '\n'))
+	return err
+}
+
+func (enc *lineEncoder) Entry(e *Entry) error {
+	enc.buf = e.appendLine(enc.buf[:0])
+	enc.buf = append(enc.buf, '\n')
+	_, err := enc.w.Write(enc.buf)
+	return err
+}
+
+func (enc *lineEncoder) End() error { return nil }
+
+// templateEncoder writes the result of a template execution per file, see the
+// Template option.
+type templateEncoder struct {
+	w    io.Writer
+	tmpl *template.Template
+}
+
+// NewTemplateEncoder returns an Encoder writing into w the result of the
+// execution of tmpl on each Entry, followed by a newline. It's the Encoder set
+// by the Template option, for applications parsing the template themselves.
+func NewTemplateEncoder(w io.Writer, tmpl *template.Template) Encoder {
+	return &templateEncoder{w: w, tmpl: tmpl}
+}
+
+func (enc *templateEncoder) Begin() error { return nil }
+
+func (enc *templateEncoder) Entry(e *Entry) error {
+	if err := enc.tmpl.Execute(enc.w, e); err != nil {
+		return err
+	}
+	_, err := io.WriteString(enc.w, "\n")
+	return err
+}
+
+func (enc *templateEncoder) End() error { return nil }
+
+// jsonEncoder writes a JSON array of entries.
+type jsonEncoder struct {
+	w io.Writer
+	n int // number of entries written
+}
+
+func (enc *jsonEncoder) Begin() error {
+	_, err := io.WriteString(enc.w, "[")
+	return err
+}
+
+func (enc *jsonEncoder) Entry(e *Entry) error {
+	b, err := json.Marshal(snapshotEntry{Entry: e, Partial: e.partial})
+	if err != nil {
+		return err
+	}
+	sep := ",\n"
+	if enc.n == 0 {
+		sep = "\n"
+	}
+	enc.n++
+	if _, err := io.WriteString(enc.w, sep); err != nil {
+		return err
+	}
+	_, err = enc.w.Write(b)
+	return err
+}
+
+func (enc *jsonEncoder) End() error {
+	_, err := io.WriteString(enc.w, "\n]\n")
+	return err
+}
+
+// An EncoderFactory creates an Encoder writing into w.
+type EncoderFactory func(w io.Writer) Encoder
+
+var (
+	formatsMu sync.RWMutex
+	formats   = map[string]EncoderFactory{
+		"line":          NewLineEncoder,
+		"json":          func(w io.Writer) Encoder { return &jsonEncoder{w: w} },
+		"sqlite-script": NewSQLiteScriptEncoder,
+	}
+)
+
+// RegisterFormat makes an output format available by the provided name, so
+// that it can be retrieved with FormatByName. The "line" (the default), "json"
+// and "sqlite-script" formats are always available. If RegisterFormat is called twice
+// with the same name or if enc is nil, it panics.
+func RegisterFormat(name string, enc EncoderFactory) {
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+	if enc == nil {
+		panic("dirtree: RegisterFormat: nil EncoderFactory for format " + name)
+	}
+	if _, dup := formats[name]; dup {
+		panic("dirtree: RegisterFormat called twice for format " + name)
+	}
+	formats[name] = enc
+}
+
+// FormatByName returns the Encoding option selecting the output format
+// registered with the given name, or an error wrapping ErrInvalidOption if
+// there's none.
+//
+//	enc, err := dirtree.FormatByName(*format)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	dirtree.Write(os.Stdout, "dir", enc)
+func FormatByName(name string) (Encoding, error) {
+	formatsMu.RLock()
+	enc, ok := formats[name]
+	formatsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown format %q", ErrInvalidOption, name)
+	}
+	return Encoding(enc), nil
+}
+
+// Formats returns the sorted names of the registered output formats.
+func Formats() []string {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,91 @@
+package dirtree
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// entryPool holds the entries released by Entries.Release, reused by listings
+// with the PoolEntries option.
+var entryPool = sync.Pool{
+	New: func() interface{} { return new(Entry) },
+}
+
+// Entries is a listing, as returned by List. It can be written repeatedly, in
+// various formats, without walking the tree again.
+type Entries []*Entry
+
+// Release returns the entries of es to the pool used by the listings made with
+// the PoolEntries option, and sets them to nil in es. The entries must not be
+// used after Release.
+func (es Entries) Release() {
+	for i, e := range es {
+		if e == nil {
+			continue
+		}
+		*e = Entry{}
+		entryPool.Put(e)
+		es[i] = nil
+	}
+}
+
+// WriteTo writes the lines describing the entries of es into w, as Write does,
+// and returns the number of bytes written. It implements io.WriterTo.
+func (es Entries) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	err := es.Encode(cw, nil)
+	return cw.n, err
+}
+
+// String returns the lines describing the entries of es, as printed by Write.
+func (es Entries) String() string {
+	var sb strings.Builder
+	es.WriteTo(&sb)
+	return sb.String()
+}
+
+// Encode writes es into w with the Encoder created by enc, or with the default
+// line format if enc is nil. It allows to write the same listing in multiple
+// formats without walking the tree again:
+//
+//	entries, err := dirtree.List("dir", dirtree.ModeAll)
+//	// ...
+//	json, _ := dirtree.FormatByName("json")
+//	dirtree.Entries(entries).Encode(os.Stdout, json)
+func (es Entries) Encode(w io.Writer, enc Encoding) error {
+	if enc == nil {
+		enc = NewLineEncoder
+	}
+	bufw := bufio.NewWriterSize(w, defaultBufSize)
+	e := enc(bufw)
+	if err := e.Begin(); err != nil {
+		return fmt.Errorf("dirtree: can't write output: %w", err)
+	}
+	for _, ent := range es {
+		if err := e.Entry(ent); err != nil {
+			return fmt.Errorf("dirtree: can't write output: %w", err)
+		}
+	}
+	if err := e.End(); err != nil {
+		return fmt.Errorf("dirtree: can't write output: %w", err)
+	}
+	if err := bufw.Flush(); err != nil {
+		return fmt.Errorf("dirtree: can't write output: %w", err)
+	}
+	return nil
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
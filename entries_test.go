@@ -0,0 +1,55 @@
+package dirtree
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestEntriesWriteTo(t *testing.T) {
+	fsys := fstest.MapFS{
+		"A/file1": &fstest.MapFile{Data: []byte("dummy")},
+		"B":       &fstest.MapFile{Data: []byte("other")},
+	}
+
+	want, err := SprintFS(fsys, ".", ModeAll, Align(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ents, err := ListFS(fsys, ".", ModeAll, Align(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Write twice, to check nothing is consumed.
+	for i := 0; i < 2; i++ {
+		if got := Entries(ents).String(); got != want {
+			t.Errorf("String() got:\n%s\nwant:\n%s", got, want)
+		}
+	}
+	var sb strings.Builder
+	n, err := Entries(ents).WriteTo(&sb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(want)) || sb.String() != want {
+		t.Errorf("WriteTo() = %d, wrote:\n%s\nwant %d:\n%s", n, sb.String(), len(want), want)
+	}
+
+	enc, err := FormatByName("json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sb.Reset()
+	if err := Entries(ents).Encode(&sb, enc); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []Entry
+	if err := json.Unmarshal([]byte(sb.String()), &decoded); err != nil {
+		t.Fatalf("invalid json: %v\n%s", err, sb.String())
+	}
+	if len(decoded) != len(ents) {
+		t.Errorf("got %d json entries, want %d", len(decoded), len(ents))
+	}
+}
@@ -0,0 +1,63 @@
+package dirtree
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+)
+
+// errDiffer stops the walk as soon as a difference is found.
+var errDiffer = errors.New("trees differ")
+
+// Equal reports whether the directory rooted at rootA in fsysA and the one
+// rooted at rootB in fsysB have the same listing, given the same options. As
+// with ListFS, a nil filesystem means the OS filesystem.
+//
+// The second tree is only walked until the first difference is found, which
+// makes Equal faster and lighter than comparing the output of Sprint.
+func Equal(fsysA fs.FS, rootA string, fsysB fs.FS, rootB string, opts ...Option) (bool, error) {
+	cfg, err := newConfig(opts)
+	if err != nil {
+		return false, fmt.Errorf("dirtree: %w", err)
+	}
+
+	// Format the lines of the first tree into a single buffer.
+	var (
+		buf  []byte
+		ends []int
+	)
+	err = walkTree(rootA, fsysA, &cfg, func(ent *Entry) error {
+		buf = ent.appendLine(buf)
+		ends = append(ends, len(buf))
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("dirtree: %w", err)
+	}
+
+	var (
+		line  []byte
+		i     int
+		start int
+	)
+	err = walkTree(rootB, fsysB, &cfg, func(ent *Entry) error {
+		if i == len(ends) {
+			return errDiffer
+		}
+		line = ent.appendLine(line[:0])
+		if !bytes.Equal(line, buf[start:ends[i]]) {
+			return errDiffer
+		}
+		start = ends[i]
+		i++
+		return nil
+	})
+	if err == errDiffer {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("dirtree: %w", err)
+	}
+	return i == len(ends), nil
+}
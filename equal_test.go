@@ -0,0 +1,89 @@
+package dirtree
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestEqual(t *testing.T) {
+	a := fstest.MapFS{
+		"dir/file": &fstest.MapFile{Data: []byte("hello")},
+		"other":    &fstest.MapFile{Data: []byte("world")},
+	}
+
+	tests := []struct {
+		name string
+		b    fstest.MapFS
+		opts []Option
+		want bool
+	}{
+		{
+			name: "same",
+			b: fstest.MapFS{
+				"dir/file": &fstest.MapFile{Data: []byte("hello")},
+				"other":    &fstest.MapFile{Data: []byte("world")},
+			},
+			opts: []Option{ModeAll},
+			want: true,
+		},
+		{
+			name: "different content",
+			b: fstest.MapFS{
+				"dir/file": &fstest.MapFile{Data: []byte("hallo")},
+				"other":    &fstest.MapFile{Data: []byte("world")},
+			},
+			opts: []Option{ModeAll},
+			want: false,
+		},
+		{
+			name: "different content, not compared",
+			b: fstest.MapFS{
+				"dir/file": &fstest.MapFile{Data: []byte("hallo")},
+				"other":    &fstest.MapFile{Data: []byte("world")},
+			},
+			opts: []Option{ModeType | ModeSize},
+			want: true,
+		},
+		{
+			name: "missing file",
+			b: fstest.MapFS{
+				"dir/file": &fstest.MapFile{Data: []byte("hello")},
+			},
+			want: false,
+		},
+		{
+			name: "extra file",
+			b: fstest.MapFS{
+				"dir/file":  &fstest.MapFile{Data: []byte("hello")},
+				"other":     &fstest.MapFile{Data: []byte("world")},
+				"other.bak": &fstest.MapFile{Data: []byte("world")},
+			},
+			want: false,
+		},
+		{
+			name: "extra file, ignored",
+			b: fstest.MapFS{
+				"dir/file":  &fstest.MapFile{Data: []byte("hello")},
+				"other":     &fstest.MapFile{Data: []byte("world")},
+				"other.bak": &fstest.MapFile{Data: []byte("world")},
+			},
+			opts: []Option{Ignore("*.bak")},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Equal(a, ".", tt.b, ".", tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Equal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := Equal(a, ".", a, "missing"); err == nil {
+		t.Errorf("Equal() with missing root should return an error")
+	}
+}
@@ -0,0 +1,41 @@
+package dirtree
+
+import "errors"
+
+var (
+	// ErrInvalidOption is returned when an option has an invalid value.
+	ErrInvalidOption = errors.New("invalid option")
+
+	// ErrInvalidType is returned when the Type option contains an invalid
+	// type character.
+	ErrInvalidType = errors.New("invalid Type")
+
+	// ErrInvalidPattern is returned when a pattern provided to Ignore or Match
+	// is malformed.
+	ErrInvalidPattern = errors.New("invalid pattern")
+
+	// ErrNoContent is returned when reading a file of a ManifestFS, which
+	// only records file metadata.
+	ErrNoContent = errors.New("file content not available")
+
+	// ErrOverBudget is returned when the total size of the listed files
+	// exceeds the SizeBudget option.
+	ErrOverBudget = errors.New("size budget exceeded")
+
+	// ErrDeadlineExceeded is returned when a walk lasts longer than the
+	// Deadline option.
+	ErrDeadlineExceeded = errors.New("walk deadline exceeded")
+)
+
+// A WalkError records an error that occurred while walking the directory tree,
+// and the path of the file that caused it.
+type WalkError struct {
+	Path string
+	Err  error
+}
+
+func (e *WalkError) Error() string {
+	return "error walking directory at " + e.Path + ": " + e.Err.Error()
+}
+
+func (e *WalkError) Unwrap() error { return e.Err }
@@ -0,0 +1,62 @@
+package dirtree
+
+import (
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// FindChecksums walks the directory rooted at root, as List, and returns the
+// regular files which content matches any of the given digests, to locate
+// where a known blob ended up in a tree for example. Digests are computed
+// with the algorithms they name, as with the Hash option, in a single read of
+// each file, and concurrently with the Jobs option. Their case doesn't matter.
+//
+//	ents, err := dirtree.FindChecksums("dist", []dirtree.Digest{{Algo: "sha256", Sum: sum}})
+//
+// Files which checksums only cover their first bytes, with HashLimit, never
+// match.
+func FindChecksums(root string, sums []Digest, opts ...Option) ([]*Entry, error) {
+	return FindChecksumsFS(nil, root, sums, opts...)
+}
+
+// FindChecksumsFS is like FindChecksums, for the directory rooted at root in
+// the given filesystem.
+func FindChecksumsFS(fsys fs.FS, root string, sums []Digest, opts ...Option) ([]*Entry, error) {
+	want := make(map[Digest]bool, len(sums))
+	opts = append(opts[:len(opts):len(opts)], Type("f"))
+	for _, d := range sums {
+		opts = append(opts, Hash(d.Algo))
+		want[Digest{Algo: d.Algo, Sum: strings.ToLower(d.Sum)}] = true
+	}
+	cfg, err := newConfig(opts)
+	if err != nil {
+		return nil, fmt.Errorf("dirtree: %w", err)
+	}
+	if len(sums) == 0 {
+		return nil, nil
+	}
+
+	var (
+		slab  entrySlab
+		found []*Entry
+	)
+	err = walkTree(root, fsys, &cfg, func(ent *Entry) error {
+		if ent.partial {
+			return nil
+		}
+		for _, d := range ent.Digests {
+			if want[d] {
+				e := slab.new()
+				*e = *ent
+				found = append(found, e)
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("dirtree: %w", err)
+	}
+	return found, nil
+}
@@ -0,0 +1,43 @@
+package dirtree
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFindChecksums(t *testing.T) {
+	fsys := fstest.MapFS{
+		"A/file1":   &fstest.MapFile{Data: []byte("dummy")},
+		"A/B/file2": &fstest.MapFile{Data: []byte("content")},
+		"C/copy1":   &fstest.MapFile{Data: []byte("dummy")},
+		"C/other":   &fstest.MapFile{Data: []byte("other")},
+	}
+	sums := []Digest{
+		{Algo: "sha256", Sum: "B5A2C96250612366EA272FFAC6D9744AAF4B45AACD96AA7CFCB931EE3B558259"},
+		{Algo: "crc32", Sum: "fec530a9"},
+	}
+
+	for _, jobs := range []int{1, 4} {
+		ents, err := FindChecksumsFS(fsys, ".", sums, Jobs(jobs))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := relPaths(ents), []string{"A/B/file2", "A/file1", "C/copy1"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Jobs(%d): got %q, want %q", jobs, got, want)
+		}
+	}
+
+	ents, err := FindChecksumsFS(fsys, ".", sums, HashLimit(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ents) != 0 {
+		t.Errorf("HashLimit(2): got %q, want no match", relPaths(ents))
+	}
+
+	if _, err := FindChecksumsFS(fsys, ".", []Digest{{Algo: "md4", Sum: "00"}}); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("unknown algorithm: error = %v, want ErrInvalidOption", err)
+	}
+}
@@ -0,0 +1,290 @@
+package dirtree
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// formatting holds the settings controlling how entries are formatted, shared
+// by all the entries of a listing.
+type formatting struct {
+	typeNames map[FileType]string // overrides the default type chars
+	typeWidth int                 // width of the type column
+
+	markBroken bool // print brokenLinkChar as type of broken symlinks
+
+	unit       SizeUnit // unit of the size and alloc columns
+	noSuffix   bool     // don't print the unit after sizes
+	compact    bool     // don't pad fields
+	sep        string   // separator between fields, a space if empty
+	pathSep    byte     // separator of printed paths, '/' if 0
+	align      bool     // compute sizeWidth and allocWidth from the listing
+	sizeWidth  int      // width of the size column, 0 means sizeDigits+1
+	allocWidth int      // width of the alloc column after "alloc=", 0 means sizeDigits+1
+
+	hashes    []hashColumn      // columns added by the Hash option
+	sumFormat ChecksumFormat    // encoding of checksums
+	sumLabels map[string]string // overrides the labels of checksums
+	blankNA   bool              // blank the checksums of other types than files
+}
+
+// brokenLinkChar is the type char printed for broken symbolic links, with the
+// MarkBrokenLinks option.
+const brokenLinkChar = 'x'
+
+// defaultFormatting is used to format entries that don't reference any
+// formatting, like those created by the user.
+var defaultFormatting = formatting{typeWidth: 1}
+
+// appendTypeName appends the string printed for the type of e to b.
+func (f *formatting) appendTypeName(b []byte, e *Entry) []byte {
+	ft := e.Type
+	if f.markBroken && ft == Symlink && e.Link == LinkBroken {
+		return append(b, brokenLinkChar)
+	}
+	if name, ok := f.typeNames[ft]; ok {
+		return append(b, name...)
+	}
+	return append(b, ft.char())
+}
+
+// alignColumns sets the widths of the size and alloc columns to the minimum
+// required to align all the entries of ents, listed with f.
+func (f *formatting) alignColumns(ents []*Entry) {
+	f.sizeWidth, f.allocWidth = 1, len(na)
+	for _, e := range ents {
+		if e.Type != File {
+			continue
+		}
+		if n := len(f.appendSize(nil, File, e.Size, 0)); n > f.sizeWidth {
+			f.sizeWidth = n
+		}
+		if e.Alloc < 0 {
+			continue
+		}
+		if n := len(f.appendSize(nil, File, e.Alloc, 0)); n > f.allocWidth {
+			f.allocWidth = n
+		}
+	}
+}
+
+// sizeColumn returns the width of the size column.
+func (f *formatting) sizeColumn() int {
+	if f.compact {
+		return 0
+	}
+	if f.sizeWidth == 0 {
+		return sizeDigits + 1
+	}
+	return f.sizeWidth
+}
+
+// allocColumn returns the width of the alloc column, after "alloc=".
+func (f *formatting) allocColumn() int {
+	if f.compact {
+		return 0
+	}
+	if f.allocWidth == 0 {
+		return sizeDigits + 1
+	}
+	return f.allocWidth
+}
+
+// blank replaces b[start:], a field of b, with spaces or, in compact mode,
+// removes it.
+func (f *formatting) blank(b []byte, start int) []byte {
+	if f.compact {
+		return b[:start]
+	}
+	for i := start; i < len(b); i++ {
+		b[i] = ' '
+	}
+	return b
+}
+
+// appendSep appends the separator between fields to b.
+func (f *formatting) appendSep(b []byte) []byte {
+	if f.sep == "" {
+		return append(b, ' ')
+	}
+	return append(b, f.sep...)
+}
+
+// headerVersion is the version of the line format, recorded in headers, see
+// the Header option. It's increased when the meaning of existing columns
+// changes.
+const headerVersion = 1
+
+// headerMarker starts header lines, followed by headerVersion.
+const headerMarker = "#dirtree/"
+
+// headerColumns are the names of the columns printed for each PrintMode, in
+// the order they're printed, the Hash columns following.
+var headerColumns = [...]struct {
+	mode PrintMode
+	name string
+}{
+	{ModeType, "type"},
+	{ModeExec, "exec"},
+	{ModeSize, "size"},
+	{ModeAlloc, "alloc"},
+	{ModeLink, "link"},
+	{ModeBirthTime, "btime"},
+	{ModeOwner, "owner"},
+	{ModeCRC32, "crc32"},
+}
+
+// appendHeader appends to b the header describing the columns of the lines of
+// entries listed with mode and f, followed by the options changing the walk
+// which are set, and returns the extended buffer.
+func (f *formatting) appendHeader(b []byte, mode PrintMode, follow, gitignore bool) []byte {
+	b = append(b, headerMarker...)
+	b = strconv.AppendInt(b, headerVersion, 10)
+	for _, col := range headerColumns {
+		if mode&col.mode != 0 {
+			b = append(f.appendSep(b), col.name...)
+		}
+	}
+	for _, hc := range f.hashes {
+		b = append(f.appendSep(b), hc.algo...)
+	}
+	b = append(f.appendSep(b), "path"...)
+	if follow {
+		b = append(f.appendSep(b), "follow=true"...)
+	}
+	if gitignore {
+		b = append(f.appendSep(b), "gitignore=true"...)
+	}
+	return b
+}
+
+// ParseHeader parses a header line, as printed with the Header option, and
+// returns the options listing files with the same columns and separator, that
+// is the PrintMode, Hash and Separator options, and the FollowSymlinks and
+// RespectGitIgnore options if recorded. It allows to list a tree again to
+// compare it with a saved listing:
+//
+//	opts, err := dirtree.ParseHeader(firstLine)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	current, err := dirtree.Sprint("dir", opts...)
+//
+// ParseHeader returns an error wrapping ErrInvalidOption if line isn't a
+// header, or if its version is more recent than the one of this package.
+// Headers printed with a Separator containing lowercase letters can't be
+// parsed.
+func ParseHeader(line string) ([]Option, error) {
+	line = strings.TrimRight(line, "\r\n")
+	if !strings.HasPrefix(line, headerMarker) {
+		return nil, fmt.Errorf("dirtree: %w: %q is not a header", ErrInvalidOption, line)
+	}
+	rest := line[len(headerMarker):]
+	i := 0
+	for i < len(rest) && '0' <= rest[i] && rest[i] <= '9' {
+		i++
+	}
+	if v, err := strconv.Atoi(rest[:i]); err != nil || v < 1 || v > headerVersion {
+		return nil, fmt.Errorf("dirtree: %w: header %q: unsupported version", ErrInvalidOption, line)
+	}
+	rest = rest[i:]
+
+	// The separator precedes the first column, which name is lowercase.
+	n := strings.IndexFunc(rest, func(r rune) bool { return 'a' <= r && r <= 'z' })
+	if n <= 0 {
+		return nil, fmt.Errorf("dirtree: %w: header %q: invalid columns", ErrInvalidOption, line)
+	}
+	sep := rest[:n]
+	opts, ok := parseHeaderFields(strings.Split(rest[n:], sep))
+	if !ok {
+		return nil, fmt.Errorf("dirtree: %w: header %q: invalid columns", ErrInvalidOption, line)
+	}
+	if sep != " " {
+		opts = append(opts, Separator(sep))
+	}
+	return opts, nil
+}
+
+// parseHeaderFields returns the options corresponding to the fields of a
+// header, that is the columns, up to "path", then the walk options. ok is
+// false if fields are invalid.
+func parseHeaderFields(fields []string) (opts []Option, ok bool) {
+	var mode PrintMode
+	k, col := 0, 0
+	for ; k < len(fields) && col < len(headerColumns); col++ {
+		if fields[k] == headerColumns[col].name {
+			mode |= headerColumns[col].mode
+			k++
+		}
+	}
+	opts = append(opts, mode)
+	for ; k < len(fields) && fields[k] != "path"; k++ {
+		if _, ok := hashAlgos[fields[k]]; !ok {
+			return nil, false
+		}
+		opts = append(opts, Hash(fields[k]))
+	}
+	if k == len(fields) {
+		return nil, false
+	}
+	for _, f := range fields[k+1:] {
+		switch f {
+		case "follow=true":
+			opts = append(opts, FollowSymlinks(true))
+		case "gitignore=true":
+			opts = append(opts, RespectGitIgnore(true))
+		default:
+			return nil, false
+		}
+	}
+	return opts, true
+}
+
+// appendPath appends the slash-separated path p to b, with the path separator
+// of f, escaped as by appendField.
+func (f *formatting) appendPath(b []byte, p string) []byte {
+	if f.pathSep == 0 || f.pathSep == '/' {
+		return f.appendField(b, p)
+	}
+	if f.sep != "" {
+		// Escape the native separators, if they need to be.
+		return f.appendField(b, strings.ReplaceAll(p, "/", string(f.pathSep)))
+	}
+	start := len(b)
+	b = append(b, p...)
+	for i := start; i < len(b); i++ {
+		if b[i] == '/' {
+			b[i] = f.pathSep
+		}
+	}
+	return b
+}
+
+// appendField appends s, a path or a link target, to b. With a Separator,
+// backslashes, newlines, carriage returns and tabs are escaped as in Go
+// strings, and occurrences of the separator are preceded by a backslash, so
+// that lines always have the same number of fields.
+func (f *formatting) appendField(b []byte, s string) []byte {
+	if f.sep == "" || !strings.ContainsAny(s, "\\\n\r\t") && !strings.Contains(s, f.sep) {
+		return append(b, s...)
+	}
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\':
+			b = append(b, `\\`...)
+		case c == '\n':
+			b = append(b, `\n`...)
+		case c == '\r':
+			b = append(b, `\r`...)
+		case c == '\t':
+			b = append(b, `\t`...)
+		case strings.HasPrefix(s[i:], f.sep):
+			b = append(append(b, '\\'), f.sep...)
+			i += len(f.sep) - 1
+		default:
+			b = append(b, c)
+		}
+	}
+	return b
+}
@@ -0,0 +1,220 @@
+//go:build ignore
+// +build ignore
+
+// gen_norm generates norm_tables.go, the tables used by NFC and NFD, from the
+// files UnicodeData.txt and CompositionExclusions.txt of the Unicode Character
+// Database. They're downloaded from unicode.org, for the Unicode version of the
+// unicode package, or read from the directory given with -ucd.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var (
+	ucdDir  = flag.String("ucd", "", "read the UCD files from `DIR` instead of downloading them")
+	version = flag.String("version", unicode.Version, "Unicode `VERSION` of the UCD files")
+	output  = flag.String("o", "norm_tables.go", "output `FILE`")
+)
+
+func main() {
+	log.SetFlags(0)
+	flag.Parse()
+
+	ccc := make(map[rune]uint8)
+	decomp := make(map[rune][]rune)
+	err := readUCD("UnicodeData.txt", func(fields []string) error {
+		if len(fields) < 6 {
+			return fmt.Errorf("invalid line: %q", strings.Join(fields, ";"))
+		}
+		r, err := parseRune(fields[0])
+		if err != nil {
+			return err
+		}
+		c, err := strconv.ParseUint(fields[3], 10, 8)
+		if err != nil {
+			return err
+		}
+		if c != 0 {
+			ccc[r] = uint8(c)
+		}
+		if fields[5] == "" || fields[5][0] == '<' {
+			// No decomposition, or a compatibility one.
+			return nil
+		}
+		for _, f := range strings.Fields(fields[5]) {
+			d, err := parseRune(f)
+			if err != nil {
+				return err
+			}
+			decomp[r] = append(decomp[r], d)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	excluded := make(map[rune]bool)
+	err = readUCD("CompositionExclusions.txt", func(fields []string) error {
+		r, err := parseRune(fields[0])
+		if err != nil {
+			return err
+		}
+		excluded[r] = true
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by gen_norm.go from the Unicode Character Database %s. DO NOT EDIT.\n\n", *version)
+	fmt.Fprintf(&b, "package dirtree\n\n")
+	fmt.Fprintf(&b, "// normVersion is the Unicode version of the normalization tables.\n")
+	fmt.Fprintf(&b, "const normVersion = %q\n\n", *version)
+
+	fmt.Fprintf(&b, "// cccRanges holds the canonical combining classes other than 0, by ranges of\n")
+	fmt.Fprintf(&b, "// code points.\n")
+	fmt.Fprintf(&b, "var cccRanges = [...]cccRange{\n")
+	var runes []rune
+	for r := range ccc {
+		runes = append(runes, r)
+	}
+	sortRunes(runes)
+	for i := 0; i < len(runes); {
+		j := i + 1
+		for j < len(runes) && runes[j] == runes[j-1]+1 && ccc[runes[j]] == ccc[runes[i]] {
+			j++
+		}
+		fmt.Fprintf(&b, "{0x%04X, 0x%04X, %d},\n", runes[i], runes[j-1], ccc[runes[i]])
+		i = j
+	}
+	fmt.Fprintf(&b, "}\n\n")
+
+	fmt.Fprintf(&b, "// decompositions holds the full canonical decompositions, Hangul syllables\n")
+	fmt.Fprintf(&b, "// excepted, by code point.\n")
+	fmt.Fprintf(&b, "var decompositions = [...]decomposition{\n")
+	var full func(r rune) []rune
+	full = func(r rune) []rune {
+		d, ok := decomp[r]
+		if !ok {
+			return []rune{r}
+		}
+		var f []rune
+		for _, x := range d {
+			f = append(f, full(x)...)
+		}
+		return f
+	}
+	runes = runes[:0]
+	for r := range decomp {
+		runes = append(runes, r)
+	}
+	for _, r := range sortRunes(runes) {
+		fmt.Fprintf(&b, "{0x%04X, %+q},\n", r, string(full(r)))
+	}
+	fmt.Fprintf(&b, "}\n\n")
+
+	// Primary composites, that is those which decomposition isn't excluded,
+	// a singleton, or starting with a non-starter.
+	var comps []composition
+	for r, d := range decomp {
+		if len(d) == 2 && !excluded[r] && ccc[r] == 0 && ccc[d[0]] == 0 {
+			comps = append(comps, composition{d[0], d[1], r})
+		}
+	}
+	sort.Slice(comps, func(i, j int) bool {
+		if comps[i].a != comps[j].a {
+			return comps[i].a < comps[j].a
+		}
+		return comps[i].b < comps[j].b
+	})
+	fmt.Fprintf(&b, "// compositions holds the primary composites, Hangul syllables excepted, by\n")
+	fmt.Fprintf(&b, "// pairs of code points.\n")
+	fmt.Fprintf(&b, "var compositions = [...]composition{\n")
+	for _, c := range comps {
+		fmt.Fprintf(&b, "{0x%04X, 0x%04X, 0x%04X},\n", c.a, c.b, c.c)
+	}
+	fmt.Fprintf(&b, "}\n")
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*output, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+type composition struct{ a, b, c rune }
+
+// readUCD calls fn with the fields of each line of the UCD file name, comments
+// and blank lines excepted.
+func readUCD(name string, fn func(fields []string) error) error {
+	var r io.Reader
+	if *ucdDir != "" {
+		f, err := os.Open(filepath.Join(*ucdDir, name))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	} else {
+		url := fmt.Sprintf("https://www.unicode.org/Public/%s/ucd/%s", *version, name)
+		resp, err := http.Get(url)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("%s: %s", url, resp.Status)
+		}
+		r = resp.Body
+	}
+
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		fields := strings.Split(line, ";")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		if err := fn(fields); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return sc.Err()
+}
+
+func parseRune(s string) (rune, error) {
+	r, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return 0, err
+	}
+	return rune(r), nil
+}
+
+func sortRunes(rs []rune) []rune {
+	sort.Slice(rs, func(i, j int) bool { return rs[i] < rs[j] })
+	return rs
+}
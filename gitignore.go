@@ -0,0 +1,172 @@
+package dirtree
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// The RespectGitIgnore option, when true, applies the .gitignore files met
+// during the walk, at each directory level, so that listings match the files
+// git considers, like git ls-files --cached --others --exclude-standard does.
+// The rules of a .gitignore file apply to the content of its directory, the
+// deeper files taking precedence, and .git directories are pruned.
+//
+// Ignored directories are pruned, so, as with git, files inside them can't be
+// re-included by a negated pattern. Only .gitignore files at or below the root
+// are considered, not those of parent directories, .git/info/exclude or the
+// global excludes file.
+type RespectGitIgnore bool
+
+func (rg RespectGitIgnore) apply(cfg *config) error {
+	cfg.gitignore = bool(rg)
+	return nil
+}
+
+// A gitignoreRule is a pattern of a .gitignore file.
+type gitignoreRule struct {
+	segs     []string // slash-separated elements of the pattern, "**" included
+	negate   bool     // pattern starting with '!', re-including files
+	dirOnly  bool     // pattern ending with a slash
+	anchored bool     // pattern containing a slash, matched from the .gitignore directory
+}
+
+// gitignores holds the rules of the .gitignore files met during a walk.
+type gitignores struct {
+	rules map[string][]gitignoreRule // by relative path of their directory
+}
+
+// load reads the .gitignore file of the directory at fullpath, if any, which
+// relative path is dir.
+func (gi *gitignores) load(fsys fs.FS, fullpath, dir string) error {
+	name := path.Join(fullpath, ".gitignore")
+	if fsys == nil {
+		name = filepath.Join(fullpath, ".gitignore")
+	}
+	f, err := openFile(fsys, name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	rules, err := parseGitignore(f)
+	if err != nil {
+		return err
+	}
+	if len(rules) != 0 {
+		if gi.rules == nil {
+			gi.rules = make(map[string][]gitignoreRule)
+		}
+		gi.rules[dir] = rules
+	}
+	return nil
+}
+
+// parseGitignore parses the rules of a .gitignore file.
+func parseGitignore(r io.Reader) ([]gitignoreRule, error) {
+	var rules []gitignoreRule
+	scan := bufio.NewScanner(r)
+	for scan.Scan() {
+		line := strings.TrimSuffix(scan.Text(), "\r")
+		// Trailing spaces are ignored, unless escaped.
+		for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+			line = line[:len(line)-1]
+		}
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		var rule gitignoreRule
+		if line[0] == '!' {
+			rule.negate = true
+			line = line[1:]
+		} else if line[0] == '\\' && len(line) > 1 && (line[1] == '!' || line[1] == '#') {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		rule.anchored = strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+		if line == "" {
+			continue
+		}
+		rule.segs = strings.Split(line, "/")
+		if !rule.anchored {
+			rule.segs = append([]string{"**"}, rule.segs...)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, scan.Err()
+}
+
+// ignored reports whether the file at rel, relative to the root of the walk,
+// is ignored by the rules of the .gitignore files of its parent directories.
+func (gi *gitignores) ignored(rel string, isDir bool) bool {
+	if rel == "." {
+		return false
+	}
+	if isDir && path.Base(rel) == ".git" {
+		return true
+	}
+	if gi.rules == nil {
+		return false
+	}
+
+	// The rules of the deepest .gitignore file, and the last rules of a file,
+	// take precedence.
+	segs := strings.Split(rel, "/")
+	for depth := len(segs) - 1; depth >= 0; depth-- {
+		dir := "."
+		if depth > 0 {
+			dir = strings.Join(segs[:depth], "/")
+		}
+		rules := gi.rules[dir]
+		for i := len(rules) - 1; i >= 0; i-- {
+			r := rules[i]
+			if r.dirOnly && !isDir {
+				continue
+			}
+			if matchSegments(r.segs, segs[depth:]) {
+				return !r.negate
+			}
+		}
+	}
+	return false
+}
+
+// matchSegments reports whether the path elements name match the pattern
+// elements pat, in which "**" matches any number of elements.
+func matchSegments(pat, name []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			// A trailing "**" matches everything inside, but not the
+			// directory itself.
+			min := 0
+			if len(pat) == 1 {
+				min = 1
+			}
+			for i := len(name); i >= min; i-- {
+				if matchSegments(pat[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pat[0], name[0]); !ok {
+			return false
+		}
+		pat, name = pat[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,113 @@
+package dirtree
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// gitignoreTree is a tree with nested .gitignore files.
+var gitignoreTree = map[string]string{
+	".gitignore":         "# comment\n*.o\n/build/\n!keep.o\nlogs/**\ndoc/*.html\n\\#hash\ntrailing   \n",
+	"main.c":             "",
+	"main.o":             "",
+	"keep.o":             "",
+	"#hash":              "",
+	"trailing":           "",
+	"build/out":          "",
+	"sub/build/out":      "",
+	"sub/.gitignore":     "*.txt\n!important.txt\n",
+	"sub/notes.txt":      "",
+	"sub/important.txt":  "",
+	"sub/deep/a.txt":     "",
+	"sub/deep/keep.o":    "",
+	"sub/deep/x.o":       "",
+	"logs/today":         "",
+	"logs/old/yesterday": "",
+	"doc/index.html":     "",
+	"doc/api/index.html": "",
+	"other/.gitignore":   "/*\n!/kept\n",
+	"other/kept":         "",
+	"other/dropped":      "",
+	"other/sub/kept":     "",
+}
+
+func TestRespectGitIgnore(t *testing.T) {
+	fsys := fstest.MapFS{}
+	for name, data := range gitignoreTree {
+		fsys[name] = &fstest.MapFile{Data: []byte(data)}
+	}
+	fsys[".git/config"] = &fstest.MapFile{}
+
+	got, err := SprintFS(fsys, ".", ModeType, RespectGitIgnore(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `d .
+f .gitignore
+d doc
+d doc/api
+f doc/api/index.html
+f keep.o
+d logs
+f main.c
+d other
+f other/kept
+d sub
+f sub/.gitignore
+d sub/build
+f sub/build/out
+d sub/deep
+f sub/deep/keep.o
+f sub/important.txt
+`
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// TestRespectGitIgnoreGit compares the files listed with RespectGitIgnore with
+// those git reports.
+func TestRespectGitIgnoreGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	for name, data := range gitignoreTree {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command("git", "init", "-q", dir)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	cmd = exec.Command("git", "-C", dir, "-c", "core.excludesFile=", "ls-files", "-z", "--others", "--exclude-standard")
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("git ls-files: %v", err)
+	}
+	want := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+	sort.Strings(want)
+
+	ents, err := List(dir, Type("f"), RespectGitIgnore(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range ents {
+		got = append(got, e.RelPath)
+	}
+	sort.Strings(got)
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant (git):\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,211 @@
+// Package gitinfo annotates dirtree listings with the git status of files.
+//
+// The status of all the files below a root directory is gathered once, by
+// running the git command, and then looked up for each listed entry:
+//
+//	repo, err := gitinfo.Open("dir")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	dirtree.Write(os.Stdout, "dir", dirtree.ModeType, repo.Encoding())
+package gitinfo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path"
+	"strings"
+
+	"github.com/arl/dirtree"
+)
+
+// ErrNotRepository is returned by Open when the root directory isn't inside a
+// git working tree.
+var ErrNotRepository = errors.New("not inside a git working tree")
+
+// A Status is the git status of a file.
+type Status uint8
+
+const (
+	Unknown   Status = iota // Unknown is for files git doesn't report, like those not found
+	Tracked                 // Tracked is for tracked files without changes
+	Modified                // Modified is for tracked files with staged or unstaged changes
+	Untracked               // Untracked is for files not tracked and not ignored
+	Ignored                 // Ignored is for files ignored by .gitignore and the like
+)
+
+func (s Status) String() string {
+	switch s {
+	case Tracked:
+		return "tracked"
+	case Modified:
+		return "modified"
+	case Untracked:
+		return "untracked"
+	case Ignored:
+		return "ignored"
+	}
+	return "unknown"
+}
+
+// Char returns the char printed by Repo.Encoding for s: ' ' for Tracked, 'M'
+// for Modified, '?' for Untracked, '!' for Ignored and '-' for Unknown.
+func (s Status) Char() byte {
+	switch s {
+	case Tracked:
+		return ' '
+	case Modified:
+		return 'M'
+	case Untracked:
+		return '?'
+	case Ignored:
+		return '!'
+	}
+	return '-'
+}
+
+// A Repo holds the git status of the files below a root directory, inside a
+// git working tree.
+type Repo struct {
+	files map[string]Status // by slash-separated path relative to the root
+	dirs  map[string]Status // status of directories, derived from their content
+}
+
+// Open gathers the git status of the files below root, by running the git
+// command. It returns an error wrapping ErrNotRepository if root isn't inside
+// a git working tree.
+func Open(root string) (*Repo, error) {
+	out, err := git(root, "rev-parse", "--is-inside-work-tree", "--show-prefix")
+	if err != nil || !bytes.HasPrefix(out, []byte("true\n")) {
+		return nil, fmt.Errorf("gitinfo: %s: %w", root, ErrNotRepository)
+	}
+	prefix := strings.TrimSuffix(string(out[len("true\n"):]), "\n")
+
+	r := &Repo{files: make(map[string]Status), dirs: make(map[string]Status)}
+
+	// ls-files paths are relative to the current directory.
+	out, err = git(root, "ls-files", "-z", "--", ".")
+	if err != nil {
+		return nil, fmt.Errorf("gitinfo: %v", err)
+	}
+	for _, p := range split(out) {
+		r.files[p] = Tracked
+		r.addDirs(p, Tracked)
+	}
+
+	// status paths are relative to the top-level directory.
+	out, err = git(root, "status", "--porcelain", "-z", "--ignored", "--untracked-files=all", "--", ".")
+	if err != nil {
+		return nil, fmt.Errorf("gitinfo: %v", err)
+	}
+	recs := split(out)
+	for i := 0; i < len(recs); i++ {
+		rec := recs[i]
+		if len(rec) < 4 {
+			continue
+		}
+		xy, p := rec[:2], strings.TrimSuffix(rec[3:], "/")
+		if xy[0] == 'R' || xy[0] == 'C' {
+			// Renames and copies are followed by the original path.
+			i++
+		}
+		if !strings.HasPrefix(p, prefix) {
+			continue
+		}
+		p = strings.TrimPrefix(p, prefix)
+		switch xy {
+		case "??":
+			r.files[p] = Untracked
+			r.addDirs(p, Untracked)
+		case "!!":
+			r.files[p] = Ignored
+			r.addDirs(p, Ignored)
+		default:
+			r.files[p] = Modified
+		}
+	}
+	return r, nil
+}
+
+// addDirs records that the parent directories of the file at p contain files
+// having the status s, Tracked taking precedence over Untracked, and Untracked
+// over Ignored.
+func (r *Repo) addDirs(p string, s Status) {
+	for d := path.Dir(p); d != "."; d = path.Dir(d) {
+		if cur, ok := r.dirs[d]; ok && cur <= s {
+			break
+		}
+		r.dirs[d] = s
+	}
+}
+
+// git runs git with args in dir and returns its output.
+func git(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %v: %s", args[0], err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return out, nil
+}
+
+// split splits the NUL-terminated records of out.
+func split(out []byte) []string {
+	var recs []string
+	for _, rec := range bytes.Split(out, []byte{0}) {
+		if len(rec) != 0 {
+			recs = append(recs, string(rec))
+		}
+	}
+	return recs
+}
+
+// Status returns the git status of the file at relpath, relative to the root
+// and slash-separated, as Entry.RelPath. Directories are Tracked if they
+// contain tracked files, or else Untracked if they contain untracked files, or
+// else Ignored if they contain ignored files. Files inside an ignored
+// directory are Ignored.
+func (r *Repo) Status(relpath string) Status {
+	if s, ok := r.files[relpath]; ok {
+		return s
+	}
+	for d := path.Dir(relpath); d != "."; d = path.Dir(d) {
+		if r.files[d] == Ignored {
+			return Ignored
+		}
+	}
+	if relpath == "." {
+		return Tracked
+	}
+	if s, ok := r.dirs[relpath]; ok {
+		return s
+	}
+	return Unknown
+}
+
+// Encoding returns the dirtree.Encoding option printing listings of the root
+// directory of r, as the default "line" format does, with each line prefixed
+// by the Status.Char of the file and a space.
+func (r *Repo) Encoding() dirtree.Encoding {
+	return func(w io.Writer) dirtree.Encoder {
+		return &encoder{Encoder: dirtree.NewLineEncoder(w), w: w, r: r}
+	}
+}
+
+type encoder struct {
+	dirtree.Encoder
+	w io.Writer
+	r *Repo
+}
+
+func (enc *encoder) Entry(e *dirtree.Entry) error {
+	if _, err := enc.w.Write([]byte{enc.r.Status(e.RelPath).Char(), ' '}); err != nil {
+		return err
+	}
+	return enc.Encoder.Entry(e)
+}
@@ -0,0 +1,102 @@
+package gitinfo
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/arl/dirtree"
+)
+
+// newRepo creates a git repository holding the files, committed or not.
+func newRepo(t *testing.T, committed, others map[string]string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	write := func(files map[string]string) {
+		for name, data := range files {
+			path := filepath.Join(dir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	run := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+	write(committed)
+	run("init", "-q")
+	run("add", ".")
+	run("commit", "-q", "-m", "initial")
+	write(others)
+	return dir
+}
+
+func TestRepo(t *testing.T) {
+	dir := newRepo(t, map[string]string{
+		".gitignore":       "*.o\nbuild/\n",
+		"src/main.go":      "package main",
+		"src/util.go":      "package main",
+		"sub/tracked/file": "tracked",
+	}, map[string]string{
+		"src/util.go":     "package main // changed",
+		"src/main.o":      "object",
+		"new/file":        "untracked",
+		"build/out/x":     "artifact",
+		"sub/tracked/new": "untracked",
+	})
+
+	repo, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sb strings.Builder
+	if err := dirtree.Write(&sb, dir, dirtree.ModeType, dirtree.Prune(".git/"), repo.Encoding()); err != nil {
+		t.Fatal(err)
+	}
+	want := `  d .
+  f .gitignore
+! d build
+! d build/out
+! f build/out/x
+? d new
+? f new/file
+  d src
+  f src/main.go
+! f src/main.o
+M f src/util.go
+  d sub
+  d sub/tracked
+  f sub/tracked/file
+? f sub/tracked/new
+`
+	if sb.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", sb.String(), want)
+	}
+
+	// Statuses are relative to the root, even below the top-level directory.
+	repo, err = Open(filepath.Join(dir, "sub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rel, want := range map[string]Status{"tracked/file": Tracked, "tracked/new": Untracked, "tracked": Tracked, "missing": Unknown} {
+		if got := repo.Status(rel); got != want {
+			t.Errorf("Status(%q) = %v, want %v", rel, got, want)
+		}
+	}
+
+	if _, err := Open(t.TempDir()); !errors.Is(err, ErrNotRepository) {
+		t.Errorf("Open() outside a repository: error = %v, want ErrNotRepository", err)
+	}
+}
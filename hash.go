@@ -0,0 +1,181 @@
+package dirtree
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"sort"
+	"strings"
+)
+
+// hashAlgos maps the algorithm names accepted by the Hash option to their
+// constructor.
+var hashAlgos = map[string]func() hash.Hash{
+	"crc32":  func() hash.Hash { return crc32.NewIEEE() },
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+	"xxh64":  func() hash.Hash { return newXXH64() },
+}
+
+// hashNames returns the sorted list of algorithm names accepted by the Hash
+// option.
+func hashNames() string {
+	names := make([]string, 0, len(hashAlgos))
+	for name := range hashAlgos {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// The Hash option adds a column showing the checksum of regular files computed
+// with the named algorithm, one of crc32, md5, sha1, sha256, sha512 and xxh64.
+// For example Hash("sha256") shows "sha256=" followed by the hexadecimal
+// SHA-256 of the file. As with ModeCRC32, other file types show n/a, and the
+// HashLimit option applies.
+//
+// Hash can be provided multiple times, the columns are shown in the order
+// the options are given, after the other ones. All checksums of a file are
+// computed in a single read.
+type Hash string
+
+func (h Hash) apply(cfg *config) error {
+	newHash, ok := hashAlgos[string(h)]
+	if !ok {
+		return fmt.Errorf("%w: Hash: unknown algorithm %q, must be one of %s", ErrInvalidOption, string(h), hashNames())
+	}
+	for _, col := range cfg.format.hashes {
+		if col.algo == string(h) {
+			return nil
+		}
+	}
+	// Don't modify the slice of the default configuration.
+	hashes := append([]hashColumn(nil), cfg.format.hashes...)
+	cfg.format.hashes = append(hashes, hashColumn{algo: string(h), width: newHash().Size() * 2})
+	return nil
+}
+
+// hashColumn describes a column added by the Hash option.
+type hashColumn struct {
+	algo  string // algorithm name
+	width int    // number of hexadecimal chars of a checksum
+}
+
+// A Digest is a checksum computed with the Hash option.
+type Digest struct {
+	Algo string // Algo is the algorithm name, as given to the Hash option
+	Sum  string // Sum is the hexadecimal checksum, or n/a
+}
+
+// The ChecksumFormat option sets how checksums, of ModeCRC32 and of the Hash
+// option, are printed.
+type ChecksumFormat uint8
+
+const (
+	// ChecksumHex prints checksums in lowercase hexadecimal, this is the
+	// default.
+	ChecksumHex ChecksumFormat = iota
+
+	// ChecksumHexUpper prints checksums in uppercase hexadecimal.
+	ChecksumHexUpper
+
+	// ChecksumBase64 prints checksums in standard base64 encoding, with
+	// padding, as in Subresource Integrity or HTTP Digest headers.
+	ChecksumBase64
+)
+
+func (cf ChecksumFormat) apply(cfg *config) error {
+	if cf > ChecksumBase64 {
+		return fmt.Errorf("%w: unknown ChecksumFormat %d", ErrInvalidOption, cf)
+	}
+	cfg.format.sumFormat = cf
+	return nil
+}
+
+// The ChecksumLabels option overrides the labels printed before checksums,
+// keyed by algorithm name: "crc32" for ModeCRC32, which label is "crc" by
+// default, or any name accepted by the Hash option, which label is the
+// algorithm name by default. An empty label omits the label and the following
+// '=' altogether, along with the '~' marking checksums limited by HashLimit.
+// For example:
+//
+//	dirtree.ChecksumLabels{"crc32": "", "sha256": "SHA256"}
+type ChecksumLabels map[string]string
+
+func (cl ChecksumLabels) apply(cfg *config) error {
+	labels := make(map[string]string, len(cl))
+	for algo, label := range cl {
+		if _, ok := hashAlgos[algo]; !ok {
+			return fmt.Errorf("%w: ChecksumLabels: unknown algorithm %q, must be one of %s", ErrInvalidOption, algo, hashNames())
+		}
+		if strings.ContainsAny(label, " \t\n\r") {
+			return fmt.Errorf("%w: ChecksumLabels: invalid label %q", ErrInvalidOption, label)
+		}
+		labels[algo] = label
+	}
+	cfg.format.sumLabels = labels
+	return nil
+}
+
+// The BlankNA option, when true, prints blank fields instead of the n/a
+// checksums, "crc=n/a" for example, of directories and other types than regular
+// files. Columns stay aligned. Files which checksum can't be computed still show
+// n/a.
+type BlankNA bool
+
+func (bn BlankNA) apply(cfg *config) error {
+	cfg.format.blankNA = bool(bn)
+	return nil
+}
+
+// appendLabel appends to b the label of the checksum computed with algo, which
+// is def unless overridden, and the following "=" or, if partial is true, "~=".
+func (f *formatting) appendLabel(b []byte, algo, def string, partial bool) []byte {
+	label, ok := f.sumLabels[algo]
+	if !ok {
+		label = def
+	}
+	if label == "" {
+		return b
+	}
+	b = append(b, label...)
+	if partial {
+		b = append(b, '~')
+	}
+	return append(b, '=')
+}
+
+// appendSum appends the hexadecimal checksum sum to b, in the ChecksumFormat.
+func (f *formatting) appendSum(b []byte, sum string) []byte {
+	if sum = strings.TrimSpace(sum); sum == na {
+		return append(b, na...)
+	}
+	switch f.sumFormat {
+	case ChecksumHexUpper:
+		return append(b, strings.ToUpper(sum)...)
+	case ChecksumBase64:
+		raw, err := hex.DecodeString(sum)
+		if err != nil {
+			break
+		}
+		return append(b, base64.StdEncoding.EncodeToString(raw)...)
+	}
+	return append(b, sum...)
+}
+
+// sumWidth returns the width of a checksum which is hexWidth chars long in
+// hexadecimal, in the ChecksumFormat.
+func (f *formatting) sumWidth(hexWidth int) int {
+	if f.sumFormat == ChecksumBase64 {
+		return base64.StdEncoding.EncodedLen(hexWidth / 2)
+	}
+	return hexWidth
+}
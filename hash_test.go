@@ -0,0 +1,227 @@
+package dirtree
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestHash(t *testing.T) {
+	fsys := fstest.MapFS{
+		"A/file1": &fstest.MapFile{Data: []byte("dummy")},
+	}
+
+	got, err := SprintFS(fsys, ".", ModeType|ModeCRC32, Hash("sha256"), Hash("md5"), Hash("sha256"), Match("A*"), Match("A/*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"d crc=n/a      sha256=n/a                                                              md5=n/a                              A",
+		"f crc=4ff4f23f sha256=b5a2c96250612366ea272ffac6d9744aaf4b45aacd96aa7cfcb931ee3b558259 md5=275876e34cf609db118f3d84b799a790 A/file1",
+	}
+	if got = strings.TrimSpace(got); got != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\n\nwant:\n%s", got, strings.Join(want, "\n"))
+	}
+
+	// With HashLimit and without ModeCRC32.
+	ents, err := ListFS(fsys, "A", Hash("md5"), HashLimit(4), ExcludeRoot, ModeType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "f md5~=180d267b4708a408a32f28cc9a81f4ec file1"; ents[0].String() != want {
+		t.Errorf("got %q, want %q", ents[0].String(), want)
+	}
+	if want := []Digest{{Algo: "md5", Sum: "180d267b4708a408a32f28cc9a81f4ec"}}; fmt.Sprint(ents[0].Digests) != fmt.Sprint(want) {
+		t.Errorf("Digests = %v, want %v", ents[0].Digests, want)
+	}
+
+	if _, err := SprintFS(fsys, ".", Hash("sha3")); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("SprintFS() error = %v, want ErrInvalidOption", err)
+	}
+}
+
+func TestChecksumPresentation(t *testing.T) {
+	fsys := fstest.MapFS{
+		"A/file1": &fstest.MapFile{Data: []byte("dummy")},
+	}
+
+	tests := []struct {
+		opts []Option
+		want []string
+	}{
+		{
+			opts: []Option{ChecksumHexUpper},
+			want: []string{
+				"crc=n/a      md5=n/a                              A",
+				"crc=4FF4F23F md5=275876E34CF609DB118F3D84B799A790 A/file1",
+			},
+		},
+		{
+			opts: []Option{ChecksumBase64},
+			want: []string{
+				"crc=n/a      md5=n/a                      A",
+				"crc=T/TyPw== md5=J1h240z2CdsRjz2Et5mnkA== A/file1",
+			},
+		},
+		{
+			opts: []Option{ChecksumLabels{"crc32": "", "md5": "MD5"}},
+			want: []string{
+				"n/a      MD5=n/a                              A",
+				"4ff4f23f MD5=275876e34cf609db118f3d84b799a790 A/file1",
+			},
+		},
+		{
+			opts: []Option{ChecksumLabels{"md5": ""}, HashLimit(4)},
+			want: []string{
+				"crc=n/a      n/a                              A",
+				"crc~=246a1dfc 180d267b4708a408a32f28cc9a81f4ec A/file1",
+			},
+		},
+		{
+			opts: []Option{BlankNA(true)},
+			want: []string{
+				"                                                  A",
+				"crc=4ff4f23f md5=275876e34cf609db118f3d84b799a790 A/file1",
+			},
+		},
+		{
+			opts: []Option{BlankNA(true), Compact(true)},
+			want: []string{
+				"  A",
+				"crc=4ff4f23f md5=275876e34cf609db118f3d84b799a790 A/file1",
+			},
+		},
+	}
+	for _, tt := range tests {
+		opts := append([]Option{ModeCRC32, Hash("md5"), ExcludeRoot}, tt.opts...)
+		got, err := SprintFS(fsys, ".", opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := strings.Join(tt.want, "\n") + "\n"; got != want {
+			t.Errorf("got:\n%s\nwant:\n%s", got, want)
+		}
+	}
+
+	for _, opt := range []Option{ChecksumFormat(42), ChecksumLabels{"sha3": "x"}, ChecksumLabels{"md5": "a b"}} {
+		if _, err := SprintFS(fsys, ".", opt); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("SprintFS(%v) error = %v, want ErrInvalidOption", opt, err)
+		}
+	}
+}
+
+func Test_xxh64(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", "ef46db3751d8e999"},
+		{"a", "d24ec4f1a98c6e5b"},
+		{"abc", "44bc2cf5ad770999"},
+		{"Nobody inspects the spammish repetition", "fbcea83c8a378bf1"},
+	}
+	for _, tt := range tests {
+		// Write byte per byte to exercise buffering.
+		h := newXXH64()
+		for i := range tt.in {
+			h.Write([]byte{tt.in[i]})
+		}
+		if got := fmt.Sprintf("%x", h.Sum(nil)); got != tt.want {
+			t.Errorf("xxh64(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+
+		h.Reset()
+		h.Write([]byte(tt.in))
+		if got := fmt.Sprintf("%016x", h.Sum64()); got != tt.want {
+			t.Errorf("xxh64(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDirChecksums(t *testing.T) {
+	fsys := fstest.MapFS{
+		"A/file1":   &fstest.MapFile{Data: []byte("dummy")},
+		"A/B/file2": &fstest.MapFile{Data: []byte("content")},
+		"C/file3":   &fstest.MapFile{Data: []byte("other")},
+	}
+	list := func(opts ...Option) map[string]string {
+		t.Helper()
+		ents, err := ListFS(fsys, ".", append(opts, ModeCRC32, Hash("sha1"), DirChecksums(true))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sums := make(map[string]string)
+		for _, e := range ents {
+			if e.Type == Dir && (len(e.Digests) != 1 || len(e.Checksum) != crcChars) {
+				t.Fatalf("%s: missing checksums: %q %v", e.RelPath, e.Checksum, e.Digests)
+			}
+			sums[e.RelPath] = e.Checksum + " " + e.Digests[0].Sum
+		}
+		return sums
+	}
+
+	before := list()
+	if again := list(Jobs(4)); !reflect.DeepEqual(before, again) {
+		t.Errorf("checksums differ between walks:\n%v\n%v", before, again)
+	}
+
+	fsys["A/B/file2"].Data = []byte("changed")
+	after := list()
+	for _, rel := range []string{".", "A", "A/B"} {
+		if before[rel] == after[rel] {
+			t.Errorf("%s: checksums unchanged after a change of A/B/file2", rel)
+		}
+	}
+	if before["C"] != after["C"] {
+		t.Errorf("C: checksums changed after a change of A/B/file2")
+	}
+
+	// Directories print their checksums.
+	got, err := SprintFS(fsys, "C", ModeType|ModeCRC32, DirChecksums(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(got, na) {
+		t.Errorf("directories should have checksums:\n%s", got)
+	}
+
+	// Filtered files don't contribute.
+	filtered := list(Ignore("A/B/file2"))
+	fsys["A/B/file2"].Data = []byte("content")
+	if again := list(Ignore("A/B/file2")); !reflect.DeepEqual(filtered, again) {
+		t.Errorf("ignored file changed checksums:\n%v\n%v", filtered, again)
+	}
+}
+
+// changingFS is a fstest.MapFS which files named "live" change as soon as
+// they're opened.
+type changingFS struct{ fstest.MapFS }
+
+func (fsys changingFS) Open(name string) (fs.File, error) {
+	f, err := fsys.MapFS.Open(name)
+	if err == nil && path.Base(name) == "live" {
+		fsys.MapFS[name] = &fstest.MapFile{Data: []byte("new content")}
+	}
+	return f, err
+}
+
+func TestDetectChanges(t *testing.T) {
+	fsys := changingFS{fstest.MapFS{
+		"A/live":   &fstest.MapFile{Data: []byte("old")},
+		"A/stable": &fstest.MapFile{Data: []byte("dummy")},
+	}}
+
+	got, err := SprintFS(fsys, ".", Type("f"), ModeCRC32, DetectChanges(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "crc=3f5dd4e5 A/live (changed during scan)\ncrc=4ff4f23f A/stable\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,124 @@
+package dirtree
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// An Index indexes the entries of a listing, as returned by List or loaded
+// from a Snapshot, so that applications querying the same listing repeatedly
+// don't have to scan it each time. Lookups return entries in listing order.
+// An Index must not be modified once built, but it's safe for concurrent use.
+type Index struct {
+	root   *Node
+	nodes  map[string]*Node      // by RelPath
+	byType map[FileType][]*Entry // by type
+	bySum  map[Digest][]*Entry   // by checksum, of ModeCRC32 and the Hash option
+}
+
+// NewIndex builds the index of the entries of a listing.
+func NewIndex(entries []*Entry) *Index {
+	ix := &Index{
+		root:   Build(entries),
+		nodes:  make(map[string]*Node, len(entries)),
+		byType: make(map[FileType][]*Entry),
+		bySum:  make(map[Digest][]*Entry),
+	}
+	ix.root.Walk(func(n *Node) error {
+		ix.nodes[n.RelPath] = n
+		return nil
+	})
+	for _, e := range entries {
+		ix.byType[e.Type] = append(ix.byType[e.Type], e)
+		if e.Type != File || e.partial {
+			continue
+		}
+		if sum := strings.TrimSpace(e.Checksum); sum != "" && sum != na {
+			d := Digest{Algo: "crc32", Sum: sum}
+			ix.bySum[d] = append(ix.bySum[d], e)
+		}
+		for _, d := range e.Digests {
+			if d.Sum != na && (d.Algo != "crc32" || e.Checksum == "") {
+				ix.bySum[d] = append(ix.bySum[d], e)
+			}
+		}
+	}
+	return ix
+}
+
+// ByPath returns the entry which RelPath is relpath, or nil if there's none.
+func (ix *Index) ByPath(relpath string) *Entry {
+	if n := ix.nodes[path.Clean(relpath)]; n != nil {
+		return n.Entry
+	}
+	return nil
+}
+
+// ByType returns the entries which type is one of ft, Dir|Symlink for example.
+func (ix *Index) ByType(ft FileType) []*Entry {
+	if ft&(ft-1) == 0 {
+		// Single type.
+		return ix.byType[ft]
+	}
+	var ents []*Entry
+	ix.root.Walk(func(n *Node) error {
+		if n.Entry != nil && n.Entry.Type&ft != 0 {
+			ents = append(ents, n.Entry)
+		}
+		return nil
+	})
+	return ents
+}
+
+// ByChecksum returns the regular files which checksum, computed with the
+// algorithm d names, is d. The case of d.Sum doesn't matter. Checksums of
+// ModeCRC32 are indexed as those of the crc32 algorithm, files which checksums
+// only cover their first bytes, with HashLimit, aren't indexed.
+func (ix *Index) ByChecksum(d Digest) []*Entry {
+	d.Sum = strings.ToLower(d.Sum)
+	return ix.bySum[d]
+}
+
+// Glob returns the entries which RelPath matches pattern. Patterns have the
+// syntax of the Match option, a trailing slash only matching directories.
+// Only the directories which can contain matching entries are searched. Glob
+// returns an error wrapping ErrInvalidPattern if pattern is malformed.
+func (ix *Index) Glob(pattern string) ([]*Entry, error) {
+	p, err := newPattern(pattern, match)
+	if err != nil {
+		return nil, fmt.Errorf("dirtree: %w: %q: %v", ErrInvalidPattern, pattern, err)
+	}
+
+	// Wildcards don't match slashes, so that pattern elements match the
+	// elements of the paths at the same depth.
+	nodes := []*Node{ix.root}
+	if p.pat != "." {
+		for _, elem := range strings.Split(p.pat, "/") {
+			var next []*Node
+			for _, n := range nodes {
+				if !strings.ContainsAny(elem, `*?[\`) {
+					if c := ix.nodes[path.Join(n.RelPath, elem)]; c != nil {
+						next = append(next, c)
+					}
+					continue
+				}
+				for _, c := range n.Children {
+					if m, _ := filepath.Match(elem, c.Name()); m {
+						next = append(next, c)
+					}
+				}
+			}
+			nodes = next
+		}
+	}
+
+	var found []*Entry
+	for _, n := range nodes {
+		if n.Entry != nil && (!p.dirOnly || n.IsDir()) {
+			found = append(found, n.Entry)
+		}
+	}
+	return found, nil
+}
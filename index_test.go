@@ -0,0 +1,65 @@
+package dirtree
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestIndex(t *testing.T) {
+	fsys := fstest.MapFS{
+		"A/file1":     &fstest.MapFile{Data: []byte("dummy")},
+		"A/B/file2":   &fstest.MapFile{Data: []byte("content")},
+		"A/B/file3":   &fstest.MapFile{Data: []byte("dummy")},
+		"C/file4.txt": &fstest.MapFile{Data: []byte("other")},
+		"C/D/E/file5": &fstest.MapFile{Data: []byte("other")},
+	}
+	ents, err := ListFS(fsys, ".", ModeType|ModeCRC32, Hash("md5"), Ignore("C/D"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ix := NewIndex(ents)
+
+	if e := ix.ByPath("A/B/file2"); e == nil || e.RelPath != "A/B/file2" {
+		t.Errorf("ByPath(A/B/file2) = %v", e)
+	}
+	if e := ix.ByPath("C/D"); e != nil {
+		t.Errorf("ByPath(C/D) = %v, want nil for a directory not listed", e)
+	}
+
+	tests := []struct {
+		name string
+		got  func() ([]*Entry, error)
+		want []string
+	}{
+		{"ByType(Dir)", func() ([]*Entry, error) { return ix.ByType(Dir), nil }, []string{".", "A", "A/B", "C", "C/D/E"}},
+		{"ByType(File|Dir)", func() ([]*Entry, error) { return ix.ByType(File | Dir), nil }, relPaths(ents)},
+		{"ByType(Symlink)", func() ([]*Entry, error) { return ix.ByType(Symlink), nil }, []string{}},
+		{"ByChecksum(crc32)", func() ([]*Entry, error) {
+			return ix.ByChecksum(Digest{Algo: "crc32", Sum: "4FF4F23F"}), nil
+		}, []string{"A/B/file3", "A/file1"}},
+		{"ByChecksum(md5)", func() ([]*Entry, error) {
+			return ix.ByChecksum(Digest{Algo: "md5", Sum: "795f3202b17cb6bc3d4b771d8c6c9eaf"}), nil
+		}, []string{"C/D/E/file5", "C/file4.txt"}},
+		{"Glob(A/*/*)", func() ([]*Entry, error) { return ix.Glob("A/*/*") }, []string{"A/B/file2", "A/B/file3"}},
+		{"Glob(*/)", func() ([]*Entry, error) { return ix.Glob("*/") }, []string{"A", "C"}},
+		{"Glob(C/*.txt)", func() ([]*Entry, error) { return ix.Glob("C/*.txt") }, []string{"C/file4.txt"}},
+		{"Glob(C/D/*/*)", func() ([]*Entry, error) { return ix.Glob("C/D/*/*") }, []string{"C/D/E/file5"}},
+		{"Glob(.)", func() ([]*Entry, error) { return ix.Glob(".") }, []string{"."}},
+	}
+	for _, tt := range tests {
+		got, err := tt.got()
+		if err != nil {
+			t.Errorf("%s: error = %v", tt.name, err)
+			continue
+		}
+		if paths := relPaths(got); !reflect.DeepEqual(paths, tt.want) {
+			t.Errorf("%s = %q, want %q", tt.name, paths, tt.want)
+		}
+	}
+
+	if _, err := ix.Glob("[a"); !errors.Is(err, ErrInvalidPattern) {
+		t.Errorf("Glob([a) error = %v, want ErrInvalidPattern", err)
+	}
+}
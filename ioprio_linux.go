@@ -0,0 +1,31 @@
+package dirtree
+
+import (
+	"runtime"
+	"syscall"
+)
+
+// See ioprio_set(2).
+const (
+	ioprioWhoProcess = 1
+	ioprioClassShift = 13
+	ioprioClassIdle  = 3
+)
+
+// withIdleIO calls fn with the idle I/O priority, then restores the previous
+// priority. fn runs on the calling thread, on which the priority is set.
+func withIdleIO(fn func() error) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	prev, _, errno := syscall.RawSyscall(syscall.SYS_IOPRIO_GET, ioprioWhoProcess, 0, 0)
+	if errno != 0 {
+		return fn()
+	}
+	_, _, errno = syscall.RawSyscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, 0, ioprioClassIdle<<ioprioClassShift)
+	if errno != 0 {
+		return fn()
+	}
+	defer syscall.RawSyscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, 0, prev)
+	return fn()
+}
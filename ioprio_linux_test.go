@@ -0,0 +1,36 @@
+package dirtree
+
+import (
+	"syscall"
+	"testing"
+	"testing/fstest"
+)
+
+func ioprio(t *testing.T) uintptr {
+	t.Helper()
+	prio, _, errno := syscall.RawSyscall(syscall.SYS_IOPRIO_GET, ioprioWhoProcess, 0, 0)
+	if errno != 0 {
+		t.Skipf("ioprio_get: %v", errno)
+	}
+	return prio
+}
+
+func TestIdleIO(t *testing.T) {
+	var prio uintptr
+	withIdleIO(func() error {
+		prio = ioprio(t)
+		return nil
+	})
+	if class := prio >> ioprioClassShift; class != ioprioClassIdle {
+		t.Errorf("I/O priority class = %d, want %d (idle)", class, ioprioClassIdle)
+	}
+
+	fsys := fstest.MapFS{"file": &fstest.MapFile{Data: []byte("dummy")}}
+	got, err := SprintFS(fsys, ".", Type("f"), ModeCRC32, IdleIO(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "crc=4ff4f23f file\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package dirtree
+
+// withIdleIO calls fn. I/O priorities are only supported on Linux.
+func withIdleIO(fn func() error) error {
+	return fn()
+}
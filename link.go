@@ -0,0 +1,178 @@
+package dirtree
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// A LinkStatus describes the target of a symbolic link.
+type LinkStatus uint8
+
+const (
+	LinkUnresolved LinkStatus = iota // LinkUnresolved is for non-links, or links which can't be resolved
+	LinkValid                        // LinkValid is for links which target exists and is inside root
+	LinkBroken                       // LinkBroken is for links which target doesn't exist
+	LinkEscaping                     // LinkEscaping is for links which target is outside root
+)
+
+func (ls LinkStatus) String() string {
+	switch ls {
+	case LinkValid:
+		return "valid"
+	case LinkBroken:
+		return "broken"
+	case LinkEscaping:
+		return "escape"
+	}
+	return na
+}
+
+// number of chars of the longest LinkStatus string.
+const linkStatusChars = 6
+
+// maxLinks is the maximum number of symbolic links followed when resolving a
+// path, after which the link is considered broken.
+const maxLinks = 255
+
+// readLinkFS is the interface implemented by filesystems supporting symbolic
+// links. It's the same as fs.ReadLinkFS, introduced in Go 1.25.
+type readLinkFS interface {
+	fs.FS
+	ReadLink(name string) (string, error)
+	Lstat(name string) (fs.FileInfo, error)
+}
+
+var errEscapes = errors.New("path escapes from root")
+
+// resolveLink reads the symbolic link at fullpath and reports its target and
+// status. root is the root of the walk, used to check whether the link target
+// stays inside it.
+func resolveLink(fsys fs.FS, root, fullpath string) (string, LinkStatus, error) {
+	if fsys == nil {
+		return resolveOSLink(root, fullpath)
+	}
+
+	rl, ok := fsys.(readLinkFS)
+	if !ok {
+		return "", LinkUnresolved, errors.New("filesystem doesn't support symbolic links")
+	}
+	target, err := rl.ReadLink(fullpath)
+	if err != nil {
+		return "", LinkUnresolved, err
+	}
+
+	resolved, err := evalSymlinksFS(rl, fullpath)
+	switch {
+	case errors.Is(err, errEscapes):
+		// fs.FS paths can't go above the filesystem root
+		return target, LinkEscaping, nil
+	case err != nil:
+		return target, LinkBroken, nil
+	}
+	if !within(path.Clean(root), resolved, "/") {
+		return target, LinkEscaping, nil
+	}
+	return target, LinkValid, nil
+}
+
+func resolveOSLink(root, fullpath string) (string, LinkStatus, error) {
+	target, err := readOSLink(fullpath)
+	if err != nil {
+		return "", LinkUnresolved, err
+	}
+
+	resolved, err := evalOSLink(fullpath, target)
+	if err != nil {
+		return target, LinkBroken, nil
+	}
+	rroot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return target, LinkUnresolved, err
+	}
+	if resolved, err = filepath.Abs(resolved); err != nil {
+		return target, LinkUnresolved, err
+	}
+	if rroot, err = filepath.Abs(rroot); err != nil {
+		return target, LinkUnresolved, err
+	}
+	if !within(rroot, resolved, string(filepath.Separator)) {
+		return target, LinkEscaping, nil
+	}
+	return target, LinkValid, nil
+}
+
+// within reports whether the clean path p is dir or is located under dir.
+func within(dir, p, sep string) bool {
+	if dir == "." {
+		return !strings.HasPrefix(p, "/")
+	}
+	return p == dir || strings.HasPrefix(p, strings.TrimSuffix(dir, sep)+sep)
+}
+
+// evalSymlinksFS is the fs.FS equivalent of filepath.EvalSymlinks. It returns
+// the path name after the evaluation of all symbolic links, and errEscapes if
+// the path goes outside of the filesystem.
+func evalSymlinksFS(fsys readLinkFS, name string) (string, error) {
+	var (
+		resolved string // resolved part of the path
+		todo     = name // yet to resolve part of the path
+		links    int
+	)
+
+	for todo != "" {
+		var comp string
+		if i := strings.IndexByte(todo, '/'); i >= 0 {
+			comp, todo = todo[:i], todo[i+1:]
+		} else {
+			comp, todo = todo, ""
+		}
+
+		switch comp {
+		case "", ".":
+			continue
+		case "..":
+			if resolved == "" {
+				return "", errEscapes
+			}
+			resolved = path.Dir(resolved)
+			if resolved == "." {
+				resolved = ""
+			}
+			continue
+		}
+
+		next := path.Join(resolved, comp)
+		fi, err := fsys.Lstat(next)
+		if err != nil {
+			return "", err
+		}
+		if fi.Mode()&fs.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		links++
+		if links > maxLinks {
+			return "", errors.New("too many links")
+		}
+		target, err := fsys.ReadLink(next)
+		if err != nil {
+			return "", err
+		}
+		if target == "" {
+			return "", fs.ErrNotExist
+		}
+		if strings.HasPrefix(target, "/") {
+			return "", errEscapes
+		}
+		todo = path.Join(target, todo)
+	}
+
+	if resolved == "" {
+		return ".", nil
+	}
+	return resolved, nil
+}
@@ -0,0 +1,236 @@
+package dirtree
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestModeLink(t *testing.T) {
+	want := []string{
+		"l link=valid  A/B/symdirA -> ..",
+		"l link=valid  A/file -> ../B/file",
+		"l link=broken A/nowhere -> missing",
+		"l link=escape A/outside -> ../../outside",
+	}
+
+	t.Run("MapFS", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"root/B/file":      &fstest.MapFile{},
+			"root/A/B/symdirA": &fstest.MapFile{Mode: fs.ModeSymlink, Data: []byte("..")},
+			"root/A/file":      &fstest.MapFile{Mode: fs.ModeSymlink, Data: []byte("../B/file")},
+			"root/A/nowhere":   &fstest.MapFile{Mode: fs.ModeSymlink, Data: []byte("missing")},
+			"root/A/outside":   &fstest.MapFile{Mode: fs.ModeSymlink, Data: []byte("../../outside")},
+			"outside":          &fstest.MapFile{},
+		}
+		if _, ok := fs.FS(fsys).(readLinkFS); !ok {
+			t.Skip("fstest.MapFS doesn't support symbolic links before Go 1.25")
+		}
+		got, err := SprintFS(fsys, "root", ModeType|ModeLink, Type("l"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got = strings.TrimSpace(got); got != strings.Join(want, "\n") {
+			t.Errorf("got:\n%s\n\nwant:\n%s", got, strings.Join(want, "\n"))
+		}
+	})
+
+	t.Run("OS", func(t *testing.T) {
+		dir := t.TempDir()
+		root := filepath.Join(dir, "root")
+		mkdirs(t, filepath.Join(root, "A", "B"), filepath.Join(root, "B"))
+		touch(t, filepath.Join(root, "B", "file"), filepath.Join(dir, "outside"))
+		symlink(t, "..", filepath.Join(root, "A", "B", "symdirA"))
+		symlink(t, "../B/file", filepath.Join(root, "A", "file"))
+		symlink(t, "missing", filepath.Join(root, "A", "nowhere"))
+		symlink(t, "../../outside", filepath.Join(root, "A", "outside"))
+
+		got, err := Sprint(root, ModeType|ModeLink, Type("l"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got = strings.TrimSpace(got); got != strings.Join(want, "\n") {
+			t.Errorf("got:\n%s\n\nwant:\n%s", got, strings.Join(want, "\n"))
+		}
+	})
+}
+
+func TestFollowSymlinks(t *testing.T) {
+	want := []string{
+		"d .",
+		"d A",
+		"d A/B",
+		"l A/B/symdirA",
+		"d A/dir",
+		"f A/dir/file",
+		"f A/file",
+		"l A/nowhere",
+		"d B",
+		"f B/file",
+	}
+	wantLoops := SymlinkLoops{"A/B/symdirA"}
+
+	check := func(t *testing.T, got string, loops SymlinkLoops) {
+		t.Helper()
+		if got = strings.TrimSpace(got); got != strings.Join(want, "\n") {
+			t.Errorf("got:\n%s\n\nwant:\n%s", got, strings.Join(want, "\n"))
+		}
+		if !reflect.DeepEqual(loops, wantLoops) {
+			t.Errorf("loops = %q, want %q", loops, wantLoops)
+		}
+	}
+
+	t.Run("MapFS", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"root/B/file":      &fstest.MapFile{},
+			"root/A/B/symdirA": &fstest.MapFile{Mode: fs.ModeSymlink, Data: []byte("..")},
+			"root/A/dir":       &fstest.MapFile{Mode: fs.ModeSymlink, Data: []byte("../B")},
+			"root/A/file":      &fstest.MapFile{Mode: fs.ModeSymlink, Data: []byte("../B/file")},
+			"root/A/nowhere":   &fstest.MapFile{Mode: fs.ModeSymlink, Data: []byte("missing")},
+		}
+		if _, ok := fs.FS(fsys).(readLinkFS); !ok {
+			t.Skip("fstest.MapFS doesn't support symbolic links before Go 1.25")
+		}
+		loops := SymlinkLoops{"stale"}
+		got, err := SprintFS(fsys, "root", ModeType, FollowSymlinks(true), &loops)
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(t, got, loops)
+	})
+
+	t.Run("OS", func(t *testing.T) {
+		root := filepath.Join(t.TempDir(), "root")
+		mkdirs(t, filepath.Join(root, "A", "B"), filepath.Join(root, "B"))
+		touch(t, filepath.Join(root, "B", "file"))
+		symlink(t, "..", filepath.Join(root, "A", "B", "symdirA"))
+		symlink(t, "../B", filepath.Join(root, "A", "dir"))
+		symlink(t, "../B/file", filepath.Join(root, "A", "file"))
+		symlink(t, "missing", filepath.Join(root, "A", "nowhere"))
+
+		var loops SymlinkLoops
+		got, err := Sprint(root, ModeType, FollowSymlinks(true), &loops)
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(t, got, loops)
+	})
+}
+
+func TestMaxLinkChain(t *testing.T) {
+	fsys := fstest.MapFS{
+		"root/file": &fstest.MapFile{},
+		"root/l1":   &fstest.MapFile{Mode: fs.ModeSymlink, Data: []byte("file")},
+		"root/l2":   &fstest.MapFile{Mode: fs.ModeSymlink, Data: []byte("l1")},
+		"root/l3":   &fstest.MapFile{Mode: fs.ModeSymlink, Data: []byte("l2")},
+	}
+	if _, ok := fs.FS(fsys).(readLinkFS); !ok {
+		t.Skip("fstest.MapFS doesn't support symbolic links before Go 1.25")
+	}
+
+	got, err := SprintFS(fsys, "root", ModeType, FollowSymlinks(true), MaxLinkChain(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "d .\nf file\nf l1\nf l2\nl l3 (link chain too long)"
+	if got = strings.TrimSpace(got); got != want {
+		t.Errorf("got:\n%s\n\nwant:\n%s", got, want)
+	}
+
+	ents, err := ListFS(fsys, "root", FollowSymlinks(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []struct {
+		chain    int
+		resolved string
+	}{{0, ""}, {0, ""}, {1, "root/file"}, {2, "root/file"}, {3, "root/file"}} {
+		if e := ents[i]; e.LinkChain != want.chain || e.Resolved != want.resolved || e.LinkTooLong {
+			t.Errorf("%s: got (chain=%d resolved=%q too long=%t), want (chain=%d resolved=%q too long=false)",
+				e.RelPath, e.LinkChain, e.Resolved, e.LinkTooLong, want.chain, want.resolved)
+		}
+	}
+
+	if _, err := ListFS(fsys, "root", MaxLinkChain(-1)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("MaxLinkChain(-1) error = %v, want ErrInvalidOption", err)
+	}
+}
+
+func TestModeLinkOtherTypes(t *testing.T) {
+	got, err := Sprint(filepath.Join("testdata", "dir"), ModeType|ModeLink, Type("fd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "d link=n/a    .\nd link=n/a    A\nd link=n/a    A/B\nf link=n/a    A/file1"
+	if got = strings.TrimSpace(got); got != want {
+		t.Errorf("got:\n%s\n\nwant:\n%s", got, want)
+	}
+}
+
+func TestMarkBrokenLinks(t *testing.T) {
+	dir := t.TempDir()
+	touch(t, filepath.Join(dir, "file"))
+	symlink(t, "file", filepath.Join(dir, "valid"))
+	symlink(t, "missing", filepath.Join(dir, "broken"))
+
+	got, err := Sprint(dir, ModeType, MarkBrokenLinks(true), ExcludeRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "x broken\nf file\nl valid"
+	if got = strings.TrimSpace(got); got != want {
+		t.Errorf("got:\n%s\n\nwant:\n%s", got, want)
+	}
+}
+
+func TestModeHardlink(t *testing.T) {
+	dir := t.TempDir()
+	touch(t, filepath.Join(dir, "a"), filepath.Join(dir, "single"))
+	for _, name := range []string{"b", "c"} {
+		if err := os.Link(filepath.Join(dir, "a"), filepath.Join(dir, name)); err != nil {
+			t.Skipf("can't create hard link: %v", err)
+		}
+	}
+	if _, _, ok := fileID(lstatInfo(t, filepath.Join(dir, "a"))); !ok {
+		t.Skip("hard links detection is not supported on this platform")
+	}
+
+	got, err := Sprint(dir, ModeType|ModeHardlink, Type("f"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "f a\nf b => a\nf c => a\nf single"
+	if got = strings.TrimSpace(got); got != want {
+		t.Errorf("got:\n%s\n\nwant:\n%s", got, want)
+	}
+}
+
+func mkdirs(t *testing.T, dirs ...string) {
+	t.Helper()
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func touch(t *testing.T, paths ...string) {
+	t.Helper()
+	for _, path := range paths {
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func symlink(t *testing.T, oldname, newname string) {
+	t.Helper()
+	if err := os.Symlink(oldname, newname); err != nil {
+		t.Skipf("can't create symlink: %v", err)
+	}
+}
@@ -0,0 +1,259 @@
+package dirtree
+
+import (
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A ManifestFS is a read-only fs.FS presenting the files of a listing, such as
+// a loaded Snapshot, without their content. It allows to run structural checks,
+// or dirtree itself, against a recorded tree without the original data.
+//
+// Files report the type and size recorded in the listing, but reading them
+// fails with ErrNoContent. Symbolic links resolve to their LinkTarget, when
+// recorded with ModeLink. Directories not part of the listing, but containing
+// listed files, are created.
+type ManifestFS struct {
+	nodes map[string]*manifestNode // indexed by slash-separated relative path
+}
+
+type manifestNode struct {
+	ent      *Entry   // nil for directories not part of the listing
+	children []string // names of the files of a directory, sorted
+}
+
+// maxLinkHops is the maximum number of symbolic links resolved to open a file.
+const maxLinkHops = 40
+
+// NewManifestFS returns the ManifestFS presenting entries, indexed by their
+// RelPath.
+func NewManifestFS(entries []*Entry) *ManifestFS {
+	m := &ManifestFS{nodes: map[string]*manifestNode{".": {}}}
+
+	var node func(rel string) *manifestNode
+	node = func(rel string) *manifestNode {
+		if n, ok := m.nodes[rel]; ok {
+			return n
+		}
+		parent := node(path.Dir(rel))
+		parent.children = append(parent.children, path.Base(rel))
+		n := &manifestNode{}
+		m.nodes[rel] = n
+		return n
+	}
+	for _, e := range entries {
+		if fs.ValidPath(e.RelPath) {
+			node(e.RelPath).ent = e
+		}
+	}
+	for _, n := range m.nodes {
+		sort.Strings(n.children)
+	}
+	return m
+}
+
+// FS returns the ManifestFS presenting the entries of s.
+func (s *Snapshot) FS() *ManifestFS {
+	return NewManifestFS(s.Entries)
+}
+
+// lookup returns the node at name, and its path once the symbolic links met
+// resolved. Links are resolved in all the components of name but the last one,
+// which is only resolved if follow is true.
+func (m *ManifestFS) lookup(op, name string, follow bool) (*manifestNode, string, error) {
+	if !fs.ValidPath(name) {
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	notExist := &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	n, resolved, rest := m.nodes["."], ".", name
+	for hops := 0; rest != "."; {
+		elem := rest
+		rest = "."
+		if i := strings.IndexByte(elem, '/'); i >= 0 {
+			elem, rest = elem[:i], elem[i+1:]
+		}
+		next := path.Join(resolved, elem)
+		c, ok := m.nodes[next]
+		if !ok {
+			return nil, "", notExist
+		}
+		if c.ent != nil && c.ent.Type == Symlink && (rest != "." || follow) {
+			target := c.ent.LinkTarget
+			if hops++; hops > maxLinkHops || target == "" || path.IsAbs(target) {
+				return nil, "", notExist
+			}
+			// Start again from the root, with the target in place of the link.
+			rest = path.Join(resolved, target, rest)
+			if !fs.ValidPath(rest) {
+				return nil, "", notExist
+			}
+			n, resolved = m.nodes["."], "."
+			continue
+		}
+		if rest != "." && !c.info(".").IsDir() {
+			return nil, "", notExist
+		}
+		n, resolved = c, next
+	}
+	return n, resolved, nil
+}
+
+// Open opens the named file, following symbolic links.
+func (m *ManifestFS) Open(name string) (fs.File, error) {
+	n, resolved, err := m.lookup("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	fi := n.info(path.Base(name))
+	if fi.IsDir() {
+		return &manifestDir{m: m, name: name, resolved: resolved, fi: fi, n: n}, nil
+	}
+	return &manifestFile{name: name, fi: fi}, nil
+}
+
+// Stat returns the fs.FileInfo describing the named file, following symbolic
+// links.
+func (m *ManifestFS) Stat(name string) (fs.FileInfo, error) {
+	n, _, err := m.lookup("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return n.info(path.Base(name)), nil
+}
+
+// Lstat returns the fs.FileInfo describing the named file, without following
+// symbolic links.
+func (m *ManifestFS) Lstat(name string) (fs.FileInfo, error) {
+	n, _, err := m.lookup("lstat", name, false)
+	if err != nil {
+		return nil, err
+	}
+	return n.info(path.Base(name)), nil
+}
+
+// ReadLink returns the target of the named symbolic link, if recorded.
+func (m *ManifestFS) ReadLink(name string) (string, error) {
+	n, _, err := m.lookup("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	if n.ent == nil || n.ent.Type != Symlink || n.ent.LinkTarget == "" {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return n.ent.LinkTarget, nil
+}
+
+// ReadDir reads the named directory and returns its entries, sorted by name.
+func (m *ManifestFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	n, resolved, err := m.lookup("readdir", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if !n.info(".").IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	return m.readDir(resolved, n), nil
+}
+
+// readDir returns the entries of the directory n, which path, symbolic links
+// resolved, is dir.
+func (m *ManifestFS) readDir(dir string, n *manifestNode) []fs.DirEntry {
+	dirents := make([]fs.DirEntry, len(n.children))
+	for i, child := range n.children {
+		dirents[i] = infoDirEntry{m.nodes[path.Join(dir, child)].info(child)}
+	}
+	return dirents
+}
+
+// info returns the fs.FileInfo of n, with the given name.
+func (n *manifestNode) info(name string) fs.FileInfo {
+	fi := manifestInfo{name: name, mode: fs.ModeDir | 0o755}
+	if n.ent != nil {
+		fi.mode = manifestMode(n.ent.Type)
+		if n.ent.Type == File {
+			fi.size = n.ent.Size
+		}
+	}
+	return fi
+}
+
+// manifestMode returns the fs.FileMode of files of type ft.
+func manifestMode(ft FileType) fs.FileMode {
+	switch ft {
+	case File:
+		return 0o644
+	case Dir:
+		return fs.ModeDir | 0o755
+	case Symlink:
+		return fs.ModeSymlink | 0o777
+	case NamedPipe:
+		return fs.ModeNamedPipe | 0o644
+	case Socket:
+		return fs.ModeSocket | 0o755
+	case CharDevice:
+		return fs.ModeDevice | fs.ModeCharDevice | 0o644
+	case Device:
+		return fs.ModeDevice | 0o644
+	}
+	return fs.ModeIrregular | 0o644
+}
+
+// manifestInfo is the fs.FileInfo of a file of a ManifestFS.
+type manifestInfo struct {
+	name string
+	size int64
+	mode fs.FileMode
+}
+
+func (fi manifestInfo) Name() string       { return fi.name }
+func (fi manifestInfo) Size() int64        { return fi.size }
+func (fi manifestInfo) Mode() fs.FileMode  { return fi.mode }
+func (fi manifestInfo) ModTime() time.Time { return time.Time{} }
+func (fi manifestInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi manifestInfo) Sys() interface{}   { return nil }
+
+// manifestFile is an opened file, other than a directory, of a ManifestFS.
+type manifestFile struct {
+	name string
+	fi   fs.FileInfo
+}
+
+func (f *manifestFile) Stat() (fs.FileInfo, error) { return f.fi, nil }
+func (f *manifestFile) Close() error               { return nil }
+
+func (f *manifestFile) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: f.name, Err: ErrNoContent}
+}
+
+// manifestDir is an opened directory of a ManifestFS.
+type manifestDir struct {
+	m        *ManifestFS
+	name     string
+	resolved string // name, symbolic links resolved
+	fi       fs.FileInfo
+	n        *manifestNode
+	off      int // number of entries already returned by ReadDir
+}
+
+func (d *manifestDir) Stat() (fs.FileInfo, error) { return d.fi, nil }
+func (d *manifestDir) Close() error               { return nil }
+
+func (d *manifestDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: fs.ErrInvalid}
+}
+
+func (d *manifestDir) ReadDir(count int) ([]fs.DirEntry, error) {
+	dirents := d.m.readDir(d.resolved, d.n)[d.off:]
+	if count > 0 && len(dirents) > count {
+		dirents = dirents[:count]
+	}
+	d.off += len(dirents)
+	if count > 0 && len(dirents) == 0 {
+		return nil, io.EOF
+	}
+	return dirents, nil
+}
@@ -0,0 +1,135 @@
+package dirtree
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestManifestFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"A/file1":   &fstest.MapFile{Data: []byte("dummy")},
+		"A/B/file2": &fstest.MapFile{Data: []byte("content")},
+		"C":         &fstest.MapFile{Data: []byte("c")},
+		"link":      &fstest.MapFile{Data: []byte("A/file1"), Mode: fs.ModeSymlink},
+	}
+
+	opts := []Option{ModeType | ModeSize | ModeLink}
+	ents, err := ListFS(fsys, ".", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mfs := NewManifestFS(ents)
+
+	// Listing the manifest gives the same listing.
+	want := Entries(ents).String()
+	got, err := SprintFS(mfs, ".", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("listing of ManifestFS:\n%s\nwant:\n%s", got, want)
+	}
+
+	fi, err := fs.Stat(mfs, "link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Name() != "link" || fi.Size() != 5 || !fi.Mode().IsRegular() {
+		t.Errorf("Stat(link) = %v %d %v, want the info of A/file1", fi.Name(), fi.Size(), fi.Mode())
+	}
+
+	f, err := mfs.Open("A/file1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(f); !errors.Is(err, ErrNoContent) {
+		t.Errorf("Read() error = %v, want ErrNoContent", err)
+	}
+	f.Close()
+
+	// Directories not listed are created.
+	mfs = NewManifestFS(ents[3:4]) // A/B/file2
+	if ents[3].RelPath != "A/B/file2" {
+		t.Fatalf("unexpected entry %s", ents[3].RelPath)
+	}
+	for _, dir := range []string{".", "A", "A/B"} {
+		dirents, err := fs.ReadDir(mfs, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(dirents) != 1 {
+			t.Errorf("ReadDir(%s) = %v, want 1 entry", dir, dirents)
+		}
+	}
+
+	d, err := mfs.Open("A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dirents, err := d.(fs.ReadDirFile).ReadDir(1); err != nil || len(dirents) != 1 || dirents[0].Name() != "B" {
+		t.Errorf("ReadDir(1) = %v, %v, want B", dirents, err)
+	}
+	if _, err := d.(fs.ReadDirFile).ReadDir(1); err != io.EOF {
+		t.Errorf("ReadDir(1) error = %v, want io.EOF", err)
+	}
+
+	for _, name := range []string{"missing", "/A", "A/../C"} {
+		if _, err := mfs.Open(name); err == nil {
+			t.Errorf("Open(%q) should fail", name)
+		}
+	}
+}
+
+func TestManifestFSLinkedDir(t *testing.T) {
+	fsys := fstest.MapFS{
+		"A/B/file2": &fstest.MapFile{Data: []byte("content")},
+		"dirlink":   &fstest.MapFile{Data: []byte("A/B"), Mode: fs.ModeSymlink},
+		"sub/up":    &fstest.MapFile{Data: []byte("../A"), Mode: fs.ModeSymlink},
+		"loop":      &fstest.MapFile{Data: []byte("loop/x"), Mode: fs.ModeSymlink},
+		"escape":    &fstest.MapFile{Data: []byte("../A"), Mode: fs.ModeSymlink},
+	}
+	ents, err := ListFS(fsys, ".", ModeType|ModeSize|ModeLink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mfs := NewManifestFS(ents)
+
+	for _, dir := range []string{"dirlink", "sub/up/B"} {
+		dirents, err := fs.ReadDir(mfs, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(dirents) != 1 || dirents[0].Name() != "file2" || dirents[0].IsDir() {
+			t.Errorf("ReadDir(%s) = %v, want file2", dir, dirents)
+		}
+	}
+
+	d, err := mfs.Open("sub/up")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dirents, err := d.(fs.ReadDirFile).ReadDir(-1); err != nil || len(dirents) != 1 || dirents[0].Name() != "B" {
+		t.Errorf("ReadDir(-1) of sub/up = %v, %v, want B", dirents, err)
+	}
+	d.Close()
+
+	fi, err := fs.Stat(mfs, "dirlink/file2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Name() != "file2" || fi.Size() != 7 {
+		t.Errorf("Stat(dirlink/file2) = %v %d, want file2 7", fi.Name(), fi.Size())
+	}
+	if fi, err := mfs.Lstat("dirlink"); err != nil || fi.Mode()&fs.ModeSymlink == 0 {
+		t.Errorf("Lstat(dirlink) = %v, %v, want a symbolic link", fi, err)
+	}
+
+	for _, name := range []string{"loop", "escape", "dirlink/file2/x", "sub/up/missing"} {
+		if _, err := fs.Stat(mfs, name); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Stat(%q) error = %v, want fs.ErrNotExist", name, err)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package dirtree
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// The DryRun option, when true, makes the functions modifying the filesystem,
+// like Mirror, only return the operations they would perform, without
+// performing them.
+type DryRun bool
+
+func (dr DryRun) apply(cfg *config) error {
+	cfg.dryRun = bool(dr)
+	return nil
+}
+
+// The DeleteExtras option, when true, makes Mirror delete the files of the
+// destination which aren't in the source listing. Files of the destination
+// which are filtered out, by Ignore for example, are never deleted, unless
+// they're in a deleted directory.
+type DeleteExtras bool
+
+func (de DeleteExtras) apply(cfg *config) error {
+	cfg.deleteExtras = bool(de)
+	return nil
+}
+
+// mirrorMode is the PrintMode used to compare the source and destination
+// listings of Mirror.
+const mirrorMode = ModeType | ModeSize | ModeCRC32 | ModeLink
+
+// MirrorFS makes the directory dst, in the OS filesystem, match the listing of
+// the directory rooted at src in fsys, or in the OS filesystem if fsys is nil.
+// The same options, filters for example, apply to both listings, so files of
+// dst filtered out are left alone. dst is created if it doesn't exist.
+//
+// Files are compared by type, size and CRC-32, and symbolic links by target.
+// New and changed files, directories and symbolic links are copied, other file
+// types are skipped. With DeleteExtras, the files of dst not listed in src are
+// deleted. The PrintMode, Hash and other options annotating entries, like
+// DirChecksums, have no effect.
+//
+// MirrorFS returns the operations performed, as events relative to dst, in
+// order: Removed for deleted files, with their entry in dst, Added and Modified
+// for copied ones, with their entry in src. With DryRun, they're only
+// planned. In case of error, the operations performed so far are returned.
+func MirrorFS(fsys fs.FS, src, dst string, opts ...Option) ([]Event, error) {
+	cfg, err := newConfig(opts)
+	if err != nil {
+		return nil, fmt.Errorf("dirtree: %w", err)
+	}
+	// Listings are compared with a fixed formatting, without any of the
+	// options annotating entries, like DirChecksums.
+	cfg.mode = mirrorMode
+	cfg.format = defaultFormatting
+	cfg.dirSums = false
+	cfg.markEmpty = false
+	cfg.pathLimit = 0
+
+	srcEnts, err := listTree(nil, src, fsys, &cfg)
+	if err != nil {
+		return nil, fmt.Errorf("dirtree: %w", err)
+	}
+	dstEnts, err := listTree(nil, dst, nil, &cfg)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("dirtree: %w", err)
+	}
+
+	plan := planMirror(srcEnts, dstEnts, cfg.deleteExtras)
+	if cfg.dryRun {
+		return plan, nil
+	}
+	for i, ev := range plan {
+		target := filepath.Join(dst, filepath.FromSlash(ev.Entry.RelPath))
+		if err := applyMirror(&cfg, ev, target); err != nil {
+			return plan[:i], fmt.Errorf("dirtree: %w", &WalkError{Path: target, Err: err})
+		}
+	}
+	return plan, nil
+}
+
+// Mirror makes the directory dst match the listing of the directory rooted at
+// src. See MirrorFS.
+func Mirror(src, dst string, opts ...Option) ([]Event, error) {
+	return MirrorFS(nil, src, dst, opts...)
+}
+
+// planMirror returns the operations making the dst listing match the src one,
+// removals first. Directories of dst are never modified, only their content.
+func planMirror(src, dst []*Entry, deleteExtras bool) []Event {
+	var plan []Event
+	ops := make(map[string]Op)
+	for _, ev := range diffEntries(dst, src) {
+		if ev.Op != Removed {
+			ops[ev.Entry.RelPath] = ev.Op
+		} else if deleteExtras {
+			plan = append(plan, ev)
+		}
+	}
+
+	old := make(map[string]*Entry, len(dst))
+	for _, e := range dst {
+		old[e.RelPath] = e
+	}
+	for _, e := range src {
+		op, ok := ops[e.RelPath]
+		o := old[e.RelPath]
+		switch {
+		case ok && op == Modified && e.Type == Dir && o.Type == Dir:
+			// Recreating it would delete its content.
+			ok = false
+		case !ok && o != nil && o.Type == Symlink && e.Type == Symlink && o.LinkTarget != e.LinkTarget:
+			// Entries only show the status of symbolic links, so their
+			// targets are compared here.
+			op, ok = Modified, true
+		}
+		if ok {
+			plan = append(plan, Event{Op: op, Entry: e})
+		}
+	}
+	return plan
+}
+
+// applyMirror performs the operation ev of Mirror on target, the path in the
+// destination directory.
+func applyMirror(cfg *config, ev Event, target string) error {
+	e := ev.Entry
+	if ev.Op == Removed {
+		return os.RemoveAll(target)
+	}
+	if ev.Op == Modified && e.Type != File {
+		// Regular files are replaced atomically, other files are recreated.
+		if err := os.RemoveAll(target); err != nil {
+			return err
+		}
+	}
+
+	switch e.Type {
+	case Dir:
+		return os.MkdirAll(target, 0o755)
+	case File:
+		return copyToFile(target, e)
+	case Symlink:
+		if e.LinkTarget != "" {
+			return os.Symlink(e.LinkTarget, target)
+		}
+		cfg.logf("%s: not mirrored, can't read symbolic link", e.Path)
+	default:
+		cfg.logf("%s: not mirrored, unsupported file type", e.Path)
+	}
+	return nil
+}
+
+// copyToFile copies the file of e to a new file at target, with the same
+// permissions, replacing any existing file.
+func copyToFile(target string, e *Entry) error {
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".dirtree-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	err = copyFile(tmp, e)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	// Files without any permission, like those of a fstest.MapFS, get the
+	// default ones.
+	perm := fs.FileMode(0o644)
+	if fi := e.Info(); fi != nil && fi.Mode().Perm() != 0 {
+		perm = fi.Mode().Perm()
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	if fi, err := os.Lstat(target); err == nil && fi.IsDir() {
+		// A directory can't be replaced by renaming.
+		if err := os.RemoveAll(target); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmp.Name(), target)
+}
@@ -0,0 +1,132 @@
+package dirtree
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMirror(t *testing.T) {
+	src := fstest.MapFS{
+		"A/file1":   &fstest.MapFile{Data: []byte("dummy"), Mode: 0o600},
+		"A/B/file2": &fstest.MapFile{Data: []byte("content")},
+		"C":         &fstest.MapFile{Data: []byte("now a file")},
+		"link":      &fstest.MapFile{Data: []byte("A/file1"), Mode: fs.ModeSymlink},
+	}
+	dst := t.TempDir()
+	for name, data := range map[string]string{
+		"A/file1":   "dumm",
+		"A/B/file2": "content",
+		"A/extra":   "extra",
+		"A/keep.o":  "ignored",
+		"C/file3":   "was a dir",
+	} {
+		path := filepath.Join(dst, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("A/B/file2", filepath.Join(dst, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	events := func(evs []Event) string {
+		var lines []string
+		for _, ev := range evs {
+			lines = append(lines, ev.Op.String()+" "+ev.Entry.RelPath)
+		}
+		return strings.Join(lines, "\n")
+	}
+	opts := []Option{Ignore("*/*.o"), DeleteExtras(true)}
+
+	plan, err := MirrorFS(src, ".", dst, append(opts, DryRun(true))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `removed A/extra
+removed C/file3
+modified A/file1
+modified C
+modified link`
+	if got := events(plan); got != want {
+		t.Errorf("planned:\n%s\nwant:\n%s", got, want)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dst, "A/file1")); string(data) != "dumm" {
+		t.Errorf("DryRun modified A/file1")
+	}
+
+	done, err := MirrorFS(src, ".", dst, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := events(done); got != want {
+		t.Errorf("performed:\n%s\nwant:\n%s", got, want)
+	}
+	if eq, err := Equal(src, ".", nil, dst, mirrorMode, Ignore("*/*.o")); err != nil || !eq {
+		t.Errorf("Equal(src, dst) = %t, %v after Mirror", eq, err)
+	}
+	if target, _ := os.Readlink(filepath.Join(dst, "link")); target != "A/file1" {
+		t.Errorf("link target = %q, want A/file1", target)
+	}
+	if fi, err := os.Stat(filepath.Join(dst, "A/file1")); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Errorf("A/file1 mode = %v, %v, want 0600", fi.Mode(), err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "A/keep.o")); err != nil {
+		t.Errorf("ignored file deleted: %v", err)
+	}
+
+	done, err = MirrorFS(src, ".", dst, opts...)
+	if err != nil || len(done) != 0 {
+		t.Errorf("second Mirror = %s, %v, want no operation", events(done), err)
+	}
+
+	// Mirroring into a new directory.
+	newdst := filepath.Join(t.TempDir(), "new")
+	if _, err := MirrorFS(src, ".", newdst); err != nil {
+		t.Fatal(err)
+	}
+	if eq, err := Equal(src, ".", nil, newdst, mirrorMode); err != nil || !eq {
+		t.Errorf("Equal(src, newdst) = %t, %v after Mirror", eq, err)
+	}
+}
+
+func TestMirrorAnnotatedDirs(t *testing.T) {
+	src := fstest.MapFS{
+		"d/keep":  &fstest.MapFile{Data: []byte("keep")},
+		"d/new":   &fstest.MapFile{Data: []byte("new")},
+		"d/empty": &fstest.MapFile{},
+	}
+	dst := t.TempDir()
+	for name, data := range map[string]string{
+		"d/keep":   "keep",
+		"d/skip.o": "ignored",
+		"d/empty":  "",
+	} {
+		path := filepath.Join(dst, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	evs, err := MirrorFS(src, ".", dst, Ignore("*/*.o"), DirChecksums(true), MarkEmpty(true), PathLimit(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evs) != 1 || evs[0].Op != Added || evs[0].Entry.RelPath != "d/new" {
+		t.Errorf("events = %v, want only d/new added", evs)
+	}
+	for _, name := range []string{"d/keep", "d/skip.o", "d/new"} {
+		if _, err := os.Stat(filepath.Join(dst, filepath.FromSlash(name))); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
@@ -1,34 +1,91 @@
 package dirtree
 
 import (
+	"errors"
 	"fmt"
+	"hash"
 	"hash/crc32"
 	"io"
 	"io/fs"
 	"os"
-	"strconv"
+	"path"
+	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 )
 
 const (
-	// ModeType indicates if file is a directory, a regular file or something
-	// else. It prints 'd', 'f' or '?' respectively.
+	// ModeType indicates the file type. It prints 'f' for a regular file, 'd'
+	// for a directory, 'l' for a symbolic link, 'p' for a named pipe, 's' for
+	// a socket, 'c' for a character device, 'b' for a block device and '?' for
+	// anything else.
 	ModeType PrintMode = 1 << iota
 
 	// ModeSize reports the length in bytes for regular files, "1234b" for
 	// example, or nothing for other types where size is not applicable (it
-	// would be OS-dependent).
+	// would be OS-dependent). See SizeUnit to report sizes in other units.
 	ModeSize
 
 	// ModeCRC32 computes and reports the CRC-32 checksum for regular files. For
 	// other file types, or for files which permissions prevent reading, it
-	// shows n/a (i.e. not applicable). Example "crc=294a245b" or "crc=n/a"
+	// shows n/a (i.e. not applicable). Example "crc=294a245b" or "crc=n/a".
+	// When the HashLimit option is used, the checksums of files longer than
+	// the limit are reported as "crc~=294a245b".
 	ModeCRC32
 
+	// ModeAlloc reports the number of bytes allocated on disk for regular
+	// files, "alloc=4096b" for example. Compared with ModeSize, it reveals
+	// sparse or compressed files. Allocated size is only known on Unix systems,
+	// it shows "alloc=n/a" elsewhere, or when walking an fs.FS which doesn't
+	// provide it, or for other file types.
+	ModeAlloc
+
+	// ModeLink resolves symbolic links and reports whether their target
+	// exists and stays inside the root directory: "link=valid", "link=broken"
+	// or "link=escape". The link target is printed after the file path, as in
+	// "A/symlink -> ../target". For other file types, or for links that can't
+	// be resolved, it shows "link=n/a".
+	ModeLink
+
+	// ModeHardlink detects regular files having multiple hard links in the
+	// listing. All but the first link met are annotated with the path of the
+	// first one, printed after the file path, as in "A/file2 => A/file1". Hard
+	// links are only detected on Unix systems.
+	ModeHardlink
+
+	// ModeBirthTime reports the file creation time, in UTC and with a second
+	// precision, as in "btime=2021-05-05T14:32:08Z". It's only available on
+	// Linux (4.11+, not for fs.FS), macOS, FreeBSD, NetBSD and Windows, it
+	// shows "btime=n/a" elsewhere or when the filesystem doesn't record it.
+	ModeBirthTime
+
+	// ModeOwner reports the user and group owning files, resolved to names, as
+	// in "owner=arl:staff". Names are looked up once per walk, and IDs which
+	// can't be resolved are printed as numbers, as with the NumericOwners
+	// option. Owners are only known on Unix systems, it shows "owner=n/a"
+	// elsewhere, or when walking an fs.FS which doesn't provide them.
+	ModeOwner
+
+	// ModeExec marks regular files having any execute permission with 'x',
+	// other regular files with '-', in a column printed after the type. It
+	// helps catching data files accidentally made executable. For other types,
+	// the column is blank. Windows doesn't record execute permissions, so no
+	// file is marked there.
+	ModeExec
+
+	// ModeDiskUsage is a mask showing, in separate columns, the apparent size
+	// and the allocated size of files, which diverge for sparse or compressed
+	// files.
+	ModeDiskUsage PrintMode = ModeSize | ModeAlloc
+
 	// ModeDefault is a mask showing file type and size.
 	ModeDefault PrintMode = ModeType | ModeSize
 
-	// ModeAll is a mask showing all information about a file.
+	// ModeAll is a mask showing all information about a file, which doesn't
+	// depend on the platform or the underlying filesystem. As such it doesn't
+	// include ModeAlloc, ModeLink, ModeHardlink, ModeBirthTime, ModeOwner nor
+	// ModeExec.
 	ModeAll PrintMode = ModeType | ModeSize | ModeCRC32
 )
 
@@ -43,14 +100,28 @@ func (m PrintMode) apply(cfg *config) error {
 	return nil
 }
 
+// A FileType represents the type of a file. FileType is a bit set, though an
+// Entry only ever has a single type.
 type FileType byte
 
 const (
-	File  FileType = 1 << iota // File is for regular files
-	Dir                        // Dir is for directories
-	Other                      // Other is for anything else (symlink, whatever, ...)
+	File       FileType = 1 << iota // File is for regular files
+	Dir                             // Dir is for directories
+	Other                           // Other is for anything not covered by other types
+	Symlink                         // Symlink is for symbolic links
+	NamedPipe                       // NamedPipe is for named pipes (FIFOs)
+	Socket                          // Socket is for Unix domain sockets
+	CharDevice                      // CharDevice is for character devices
+	Device                          // Device is for block devices
 )
 
+// fileTypes lists all file types, in the order their chars are documented.
+var fileTypes = []FileType{File, Dir, Symlink, NamedPipe, Socket, CharDevice, Device, Other}
+
+// irregular is the set of types that are neither regular files nor
+// directories.
+const irregular = Symlink | NamedPipe | Socket | CharDevice | Device | Other
+
 // byte returns the printable char corresponding to ft.
 func (ft FileType) char() byte {
 	switch ft {
@@ -58,19 +129,51 @@ func (ft FileType) char() byte {
 		return 'd'
 	case File:
 		return 'f'
+	case Symlink:
+		return 'l'
+	case NamedPipe:
+		return 'p'
+	case Socket:
+		return 's'
+	case CharDevice:
+		return 'c'
+	case Device:
+		return 'b'
 	case Other:
 		return '?'
 	}
 	panic(fmt.Sprintf("FileType.Char(): unexpected FileType value: %d", ft))
 }
 
+// String returns the char printed for ft with ModeType, 'f' for File for
+// example.
+func (ft FileType) String() string {
+	switch ft {
+	case File, Dir, Other, Symlink, NamedPipe, Socket, CharDevice, Device:
+		return string(ft.char())
+	}
+	return fmt.Sprintf("FileType(%d)", ft)
+}
+
 func filetypeFromDirEntry(dirent fs.DirEntry) FileType {
 	typ := dirent.Type()
-	if typ.IsRegular() {
+	switch {
+	case typ.IsRegular():
 		return File
-	}
-	if typ.Type() == fs.ModeDir {
+	case typ&fs.ModeSymlink != 0, isReparsePoint(dirent):
+		// Windows junctions and other reparse points are reported as
+		// symbolic links.
+		return Symlink
+	case typ.IsDir():
 		return Dir
+	case typ&fs.ModeNamedPipe != 0:
+		return NamedPipe
+	case typ&fs.ModeSocket != 0:
+		return Socket
+	case typ&fs.ModeCharDevice != 0:
+		return CharDevice
+	case typ&fs.ModeDevice != 0:
+		return Device
 	}
 	return Other
 }
@@ -80,52 +183,102 @@ func filetypeFromDirEntry(dirent fs.DirEntry) FileType {
 // just to respect that rule, we're making an exception in those cases.
 const sizeDigits = 9
 
-func formatSize(ft FileType, size int64) string {
-	if ft != File {
-		return fmt.Sprintf("%-*s", sizeDigits+1, "")
-	}
-	str := strconv.FormatInt(size, 10) + "b"
-	if len(str) > sizeDigits {
-		return str
+// appendPad appends spaces to b so that b[start:] is at least width bytes
+// long.
+func appendPad(b []byte, start, width int) []byte {
+	for len(b)-start < width {
+		b = append(b, ' ')
 	}
-
-	return fmt.Sprintf("%-*s", sizeDigits+1, str)
+	return b
 }
 
 // number of chars in hexadecimal representation of a CRC-32.
 const crcChars = crc32.Size * 2 // 2 since 2 chars per raw byte
 
-func checksum(fsys fs.FS, path string) (chksum string) {
+// hashBufSize is the size of the buffers used to read files while computing
+// checksums. Larger than the default io.Copy buffer, it reduces the number of
+// read syscalls on big files.
+const hashBufSize = 256 << 10
+
+// hashBufPool holds the buffers used to compute checksums, shared across files
+// so that buffer allocation isn't a per-file cost.
+var hashBufPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, hashBufSize)
+		return &buf
+	},
+}
+
+// checksum returns the checksum of the file at path, and the number of bytes
+// read to compute it. If limit is positive, only the first limit bytes are
+// hashed and partial reports whether the file is actually longer than that.
+// checksum never fails, it returns checksumNA() in case of error, along with
+// that error. The extra hashes are fed with the same data. Reads are throttled
+// by th, if not nil.
+func checksum(fsys fs.FS, path string, limit int64, th *throttler, extra ...hash.Hash) (chksum string, n int64, partial bool, err error) {
 	defer func() {
-		if e := recover(); e != nil || chksum == "" {
-			chksum = checksumNA()
+		if e := recover(); e != nil {
+			chksum, err = checksumNA(), fmt.Errorf("%v", e)
 		}
 	}()
-	var (
-		f   fs.File
-		err error
-	)
-	if fsys != nil {
-		f, err = fsys.Open(path)
-	} else {
-		f, err = os.Open(path)
-	}
+
+	f, err := openFile(fsys, path)
 	if err != nil {
-		panic(err)
+		return checksumNA(), 0, false, err
 	}
+	defer f.Close()
 
+	buf := hashBufPool.Get().(*[]byte)
+	defer hashBufPool.Put(buf)
+
+	// Hide any WriterTo implementation of f so that our buffer gets used.
+	var r io.Reader = struct{ io.Reader }{f}
+	if limit > 0 {
+		r = io.LimitReader(f, limit)
+	}
+	if th != nil {
+		r = throttledReader{r, th}
+	}
 	h := crc32.NewIEEE()
-	defer f.Close()
-	if _, err := io.Copy(h, f); err != nil {
-		panic(err)
+	var w io.Writer = h
+	if len(extra) != 0 {
+		w = io.MultiWriter(append([]io.Writer{h}, hashWriters(extra)...)...)
+	}
+	if n, err = io.CopyBuffer(w, r, *buf); err != nil {
+		return checksumNA(), n, false, err
 	}
+	if limit > 0 && n == limit {
+		// Check whether there's more to read.
+		var b [1]byte
+		m, _ := f.Read(b[:])
+		partial = m != 0
+	}
+
+	return fmt.Sprintf("%0*x", crcChars, h.Sum32()), n, partial, nil
+}
 
-	chksum = fmt.Sprintf("%0*x", crcChars, h.Sum32())
-	return
+// openFile opens the file at path in fsys, or in the OS filesystem if fsys is
+// nil.
+func openFile(fsys fs.FS, path string) (fs.File, error) {
+	if fsys != nil {
+		return fsys.Open(path)
+	}
+	return os.Open(path)
+}
+
+func hashWriters(hs []hash.Hash) []io.Writer {
+	ws := make([]io.Writer, len(hs))
+	for i, h := range hs {
+		ws[i] = h
+	}
+	return ws
 }
 
 const na = "n/a"
 
+// number of chars of a time formatted by ModeBirthTime.
+const timeChars = len("2006-01-02T15:04:05Z")
+
 func checksumNA() string {
 	return fmt.Sprintf("%-*s", crcChars, na)
 }
@@ -136,77 +289,543 @@ type Entry struct {
 	RelPath  string
 	Type     FileType
 	Size     int64
+	Alloc    int64 // Alloc is the allocated size, -1 if unknown
 	Checksum string
 
-	mode PrintMode
+	LinkTarget string     // LinkTarget is the target of a symbolic link
+	Link       LinkStatus // Link is the status of a symbolic link target
+
+	BirthTime time.Time // BirthTime is the creation time, zero if unknown
+	Owner     string    // Owner is the user owning the file, empty if unknown
+	Group     string    // Group is the group owning the file, empty if unknown
+	Exec      bool      // Exec reports whether a regular file has any execute permission
+	TooLong   bool      // TooLong reports whether RelPath exceeds the PathLimit option
+	Empty     bool      // Empty reports whether the file is empty, with the MarkEmpty option
+	Changed   bool      // Changed reports whether the file changed while hashed, with the DetectChanges option
+	TimedOut  bool      // TimedOut reports whether getting info or checksums exceeded the EntryTimeout option
+	Digests   []Digest  // Digests holds the checksums computed with the Hash option
+
+	// LinkChain is, with FollowSymlinks, the number of symbolic links
+	// followed to reach the file, starting with the listed link, and Resolved
+	// the path they resolve to. LinkTooLong reports whether the link hasn't
+	// been followed since its chain exceeds the MaxLinkChain option.
+	LinkChain   int
+	Resolved    string
+	LinkTooLong bool
+
+	// HardlinkOf is, for a file having multiple hard links, the RelPath of
+	// the first of its links met during the walk. It's empty for that first
+	// link.
+	HardlinkOf string
+
+	mode    PrintMode
+	partial bool        // checksum only covers the first bytes of the file
+	summed  bool        // checksums of a directory computed with DirChecksums
+	format  *formatting // nil means defaultFormatting
+
+	info   fs.FileInfo // info gathered during the walk, if any
+	dirent fs.DirEntry // directory entry met during the walk
+	fsys   fs.FS       // walked filesystem, nil for the OS one
 }
 
-func newEntry(mode PrintMode, fsys fs.FS, fullpath string, ft FileType) (*Entry, error) {
-	ent := &Entry{
-		mode: mode,
-		Type: ft,
+// newEntry creates the Entry for the file at fullpath, gathering the
+// information required by cfg.mode. dirent is the fs.DirEntry obtained while
+// walking and is used to avoid an extra stat. root is the root of the walk.
+func newEntry(cfg *config, fsys fs.FS, root, fullpath string, dirent fs.DirEntry) (*Entry, error) {
+	ent := new(Entry)
+	if err := fillEntry(ent, cfg, fsys, root, fullpath, dirent); err != nil {
+		return nil, err
 	}
+	return ent, nil
+}
 
-	if mode&ModeSize != 0 {
-		var (
-			fi  fs.FileInfo
-			err error
-		)
-		if fsys == nil {
-			fi, err = os.Stat(fullpath)
-		} else {
-			fi, err = fs.Stat(fsys, fullpath)
+// fillEntry is like newEntry but fills the provided Entry.
+func fillEntry(ent *Entry, cfg *config, fsys fs.FS, root, fullpath string, dirent fs.DirEntry) error {
+	ft := filetypeFromDirEntry(dirent)
+	ent.mode = cfg.mode
+	ent.format = &cfg.format
+	ent.Type = ft
+	ent.dirent = dirent
+	ent.fsys = fsys
+	st := cfg.stats
+
+	if cfg.mode&(ModeSize|ModeAlloc|ModeHardlink|ModeBirthTime|ModeOwner|ModeExec) != 0 || cfg.budget != 0 || cfg.markEmpty || cfg.detectChanges && cfg.hashing() {
+		var start time.Time
+		if st != nil {
+			start = time.Now()
+		}
+		var fi fs.FileInfo
+		err := cfg.withRetry(fullpath, func() error {
+			v, err := cfg.withTimeout(func() (interface{}, error) {
+				return dirent.Info()
+			})
+			fi, _ = v.(fs.FileInfo)
+			return err
+		})
+		if st != nil {
+			st.StatTime += time.Since(start)
+		}
+		switch {
+		case errors.Is(err, errTimedOut):
+			// Move on, without the info.
+			cfg.logf("%s: can't get file info: %v", fullpath, err)
+			ent.TimedOut = true
+			ent.Alloc = -1
+		case err != nil:
+			return fmt.Errorf("failed to get file info: %w", err)
+		default:
+			fillInfo(ent, cfg, fi, fsys, fullpath)
+		}
+	}
+
+	if fd, ok := dirent.(linkDirEntry); ok {
+		ent.LinkChain = fd.chain
+		ent.Resolved = filepath.ToSlash(fd.resolved)
+		ent.LinkTooLong = ft == Symlink
+	}
+
+	if (cfg.mode&ModeLink != 0 || cfg.format.markBroken) && ft == Symlink {
+		target, status, err := resolveLink(fsys, root, fullpath)
+		if err != nil {
+			cfg.logf("%s: can't resolve symbolic link: %v", fullpath, err)
 		}
+		ent.LinkTarget = target
+		ent.Link = status
+	}
+
+	if cfg.hashing() && !cfg.deferHash {
+		var start time.Time
+		if st != nil {
+			start = time.Now()
+		}
+		n, err := hashEntry(ent, cfg, fsys, fullpath)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get size of %v: %v", fullpath, err)
+			cfg.logf("%s: can't compute checksum: %v", fullpath, err)
+		}
+		if st != nil {
+			st.HashTime += time.Since(start)
+			st.BytesHashed += n
+		}
+	}
+
+	return nil
+}
+
+// fillInfo fills ent with the information of fi, describing the file at
+// fullpath.
+func fillInfo(ent *Entry, cfg *config, fi fs.FileInfo, fsys fs.FS, fullpath string) {
+	ft := ent.Type
+	ent.info = fi
+	ent.Size = fi.Size()
+	ent.Alloc = -1
+	if alloc, ok := allocSize(fi); ok && ft == File {
+		ent.Alloc = alloc
+	}
+	if cfg.mode&ModeHardlink != 0 && ft == File {
+		ent.HardlinkOf = cfg.hardlinks.record(fi, ent.RelPath)
+	}
+	if cfg.mode&ModeBirthTime != 0 {
+		if btime, ok := birthTime(fi, fullpath, fsys == nil); ok {
+			ent.BirthTime = btime
 		}
-		ent.Size = fi.Size()
 	}
+	ent.Exec = ft == File && fi.Mode().Perm()&0o111 != 0
+	ent.Empty = cfg.markEmpty && ft == File && fi.Size() == 0
+	if cfg.mode&ModeOwner != 0 {
+		if uid, gid, ok := ownerIDs(fi); ok {
+			setOwner(ent, cfg, uid, gid)
+		}
+	}
+}
+
+// hashing reports whether checksums have to be computed.
+func (cfg *config) hashing() bool {
+	return cfg.mode&ModeCRC32 != 0 || len(cfg.format.hashes) != 0
+}
 
-	if mode&ModeCRC32 != 0 {
-		if ft != File {
+// hashEntry computes the checksums of the file at fullpath, described by ent.
+// It returns the number of bytes read, and the error which prevented to
+// compute the checksums, in which case they're set to n/a.
+func hashEntry(ent *Entry, cfg *config, fsys fs.FS, fullpath string) (int64, error) {
+	if ent.Type != File {
+		if cfg.mode&ModeCRC32 != 0 {
 			ent.Checksum = na
+		}
+		return 0, nil
+	}
+
+	var hs []hash.Hash
+	for _, col := range cfg.format.hashes {
+		hs = append(hs, hashAlgos[col.algo]())
+	}
+
+	// With EntryTimeout, hashFile may be abandoned while still running, it
+	// only returns its results.
+	hashFile := func() (interface{}, error) {
+		for _, h := range hs {
+			h.Reset()
+		}
+		var (
+			res hashResult
+			err error
+		)
+		read := func() error {
+			res.chksum, res.n, res.partial, err = checksum(fsys, fullpath, cfg.hashLimit, cfg.throttler, hs...)
+			return err
+		}
+		if cfg.idleIO {
+			withIdleIO(read)
 		} else {
-			ent.Checksum = checksum(fsys, fullpath)
+			read()
 		}
+		return res, err
 	}
 
-	return ent, nil
+	var res hashResult
+	err := errTimedOut
+	if !ent.TimedOut {
+		err = cfg.withRetry(fullpath, func() error {
+			v, err := cfg.withTimeout(hashFile)
+			res, _ = v.(hashResult)
+			return err
+		})
+	}
+	if errors.Is(err, errTimedOut) {
+		ent.TimedOut = true
+		res.chksum = checksumNA()
+	}
+	if cfg.mode&ModeCRC32 != 0 {
+		ent.Checksum = res.chksum
+	}
+	ent.partial = res.partial
+	for i, h := range hs {
+		sum := na
+		if err == nil {
+			sum = fmt.Sprintf("%x", h.Sum(nil))
+		}
+		ent.Digests = append(ent.Digests, Digest{Algo: cfg.format.hashes[i].algo, Sum: sum})
+	}
+	if cfg.detectChanges && ent.info != nil {
+		ent.Changed = changedSince(ent.info, fsys, fullpath)
+	}
+	return res.n, err
+}
+
+// hashResult holds the results of checksum.
+type hashResult struct {
+	chksum  string
+	n       int64
+	partial bool
+}
+
+// changedSince reports whether the file at fullpath has changed since it's been
+// described by fi, or can't be stat'ed anymore.
+func changedSince(fi fs.FileInfo, fsys fs.FS, fullpath string) bool {
+	var (
+		now fs.FileInfo
+		err error
+	)
+	if fsys == nil {
+		now, err = os.Stat(fullpath)
+	} else {
+		now, err = fs.Stat(fsys, fullpath)
+	}
+	if err != nil {
+		return true
+	}
+	return now.Size() != fi.Size() || !now.ModTime().Equal(fi.ModTime())
+}
+
+// devIno uniquely identifies a file on a system.
+type devIno struct{ dev, ino uint64 }
+
+// hardlinks records the first path met for each file having multiple hard
+// links.
+type hardlinks map[devIno]string
+
+// record records that the file described by fi has been met at relpath, and
+// returns the path at which it has been met first, or the empty string if it's
+// the first time (or the file only has one link).
+func (h *hardlinks) record(fi fs.FileInfo, relpath string) string {
+	id, nlink, ok := fileID(fi)
+	if !ok || nlink < 2 {
+		return ""
+	}
+	if *h == nil {
+		*h = make(hardlinks)
+	}
+	if first, ok := (*h)[id]; ok {
+		return first
+	}
+	(*h)[id] = relpath
+	return ""
+}
+
+// number of entries allocated at once by an entrySlab.
+const slabSize = 256
+
+// An entrySlab allocates entries by chunks, in order to reduce the number of
+// allocations when walking large trees.
+type entrySlab []Entry
+
+func (s *entrySlab) new() *Entry {
+	if len(*s) == 0 {
+		*s = make([]Entry, slabSize)
+	}
+	ent := &(*s)[0]
+	*s = (*s)[1:]
+	return ent
+}
+
+// Info returns the fs.FileInfo describing the file of e, giving access to its
+// modification time, permission bits or system-specific data. It's gathered
+// during the walk when the PrintMode requires it, ModeSize for example, or
+// otherwise obtained on demand, which costs a stat. Symbolic links are
+// described themselves, unless followed with FollowSymlinks. Info returns nil
+// if the file can't be stat'ed anymore, or for entries which haven't been
+// obtained from a walk.
+func (e *Entry) Info() fs.FileInfo {
+	if e.info != nil {
+		return e.info
+	}
+	if e.dirent == nil {
+		return nil
+	}
+	fi, err := e.dirent.Info()
+	if err != nil {
+		return nil
+	}
+	return fi
+}
+
+// DirEntry returns the directory entry met during the walk for the file of e,
+// which type bits are available without any system call. It's nil for entries
+// which haven't been obtained from a walk.
+func (e *Entry) DirEntry() fs.DirEntry { return e.dirent }
+
+// Open opens the file of e for reading, in the filesystem it has been listed
+// from: the fs.FS given to ListFS or WriteFS for example, or the OS filesystem.
+// Symbolic links are followed.
+func (e *Entry) Open() (fs.File, error) {
+	if e.fsys != nil {
+		return e.fsys.Open(e.Path)
+	}
+	return os.Open(filepath.FromSlash(e.Path))
+}
+
+// IsDir reports whether e describes a directory.
+func (e *Entry) IsDir() bool { return e.Type == Dir }
+
+// IsRegular reports whether e describes a regular file.
+func (e *Entry) IsRegular() bool { return e.Type == File }
+
+// Base returns the last element of the entry path.
+func (e *Entry) Base() string { return path.Base(e.Path) }
+
+// Ext returns the file name extension of the entry path, that is the suffix
+// beginning at the final dot in the final element of the path. It's empty if
+// there's no dot.
+func (e *Entry) Ext() string { return path.Ext(e.Path) }
+
+// Depth returns the depth of the entry relative to the root of the walk: 0 for
+// the root itself, 1 for its direct children, etc.
+func (e *Entry) Depth() int {
+	if e.RelPath == "." {
+		return 0
+	}
+	return strings.Count(e.RelPath, "/") + 1
+}
+
+// hasSums reports whether e has checksums: regular files, and directories with
+// the DirChecksums option.
+func (e *Entry) hasSums() bool {
+	return e.Type == File || e.Type == Dir && e.summed
 }
 
 // Format returns a summary string of e. Some information might be missing,
 // depending on the PrintMode used to create the Entry.
 func (e *Entry) Format() string {
-	var sb strings.Builder
+	return string(e.AppendFormat(make([]byte, 0, 32)))
+}
+
+// appendLine appends the line describing e to b, without the trailing newline,
+// and returns the extended buffer.
+func (e *Entry) appendLine(b []byte) []byte {
+	format := e.format
+	if format == nil {
+		format = &defaultFormatting
+	}
+	b = e.AppendFormat(b)
+	b = format.appendPath(b, e.RelPath)
+	if e.mode&ModeLink != 0 && e.Type == Symlink {
+		b = append(b, " -> "...)
+		b = format.appendField(b, e.LinkTarget)
+	}
+	if e.mode&ModeHardlink != 0 && e.HardlinkOf != "" {
+		b = append(b, " => "...)
+		b = format.appendPath(b, e.HardlinkOf)
+	}
+	if e.Empty {
+		b = append(b, " (empty)"...)
+	}
+	if e.LinkTooLong {
+		b = append(b, " (link chain too long)"...)
+	}
+	if e.Changed {
+		b = append(b, " (changed during scan)"...)
+	}
+	if e.TimedOut {
+		b = append(b, " (timed out)"...)
+	}
+	if e.TooLong {
+		b = append(b, " (path too long)"...)
+	}
+	return b
+}
+
+// String returns the line describing e, as printed by Write, without the
+// trailing newline.
+func (e *Entry) String() string {
+	b := make([]byte, 0, 32+len(e.RelPath))
+	return string(e.appendLine(b))
+}
+
+// Equal reports whether e and o have the same line, as printed by Write. Path
+// is not compared, so that entries of trees listed at different locations can
+// be compared.
+//
+// Equal is used by github.com/google/go-cmp, see Capture.
+func (e *Entry) Equal(o *Entry) bool {
+	if e == nil || o == nil {
+		return e == o
+	}
+	return e.String() == o.String()
+}
+
+// AppendFormat appends the summary string of e, as returned by Format, to b and
+// returns the extended buffer. Reusing the same buffer to format many entries
+// avoids allocating a string per entry.
+func (e *Entry) AppendFormat(b []byte) []byte {
+	format := e.format
+	if format == nil {
+		format = &defaultFormatting
+	}
+
+	pad := appendPad
+	if format.compact {
+		pad = func(b []byte, _, _ int) []byte { return b }
+	}
 
-	// Separate successive mode expressions
+	// Separate successive mode expressions, even empty ones in compact mode.
+	fields := 0
 	sep := func() {
-		if sb.Len() != 0 {
-			sb.WriteByte(' ')
+		if fields != 0 {
+			b = format.appendSep(b)
 		}
+		fields++
 	}
 
 	if e.mode&ModeType != 0 {
 		sep()
-		sb.WriteByte(e.Type.char())
+		col := len(b)
+		b = pad(format.appendTypeName(b, e), col, format.typeWidth)
+	}
+
+	if e.mode&ModeExec != 0 {
+		sep()
+		switch {
+		case e.Type != File:
+			b = pad(b, len(b), 1)
+		case e.Exec:
+			b = append(b, 'x')
+		default:
+			b = append(b, '-')
+		}
 	}
 
 	if e.mode&ModeSize != 0 {
 		sep()
-		sb.WriteString(formatSize(e.Type, e.Size))
+		b = format.appendSize(b, e.Type, e.Size, format.sizeColumn())
+	}
+
+	if e.mode&ModeAlloc != 0 {
+		sep()
+		b = append(b, "alloc="...)
+		if e.Type != File || e.Alloc < 0 {
+			col := len(b)
+			b = pad(append(b, na...), col, format.allocColumn())
+		} else {
+			b = format.appendSize(b, e.Type, e.Alloc, format.allocColumn())
+		}
+	}
+
+	if e.mode&ModeLink != 0 {
+		sep()
+		b = append(b, "link="...)
+		col := len(b)
+		link := e.Link
+		if e.Type != Symlink {
+			link = LinkUnresolved
+		}
+		b = pad(append(b, link.String()...), col, linkStatusChars)
+	}
+
+	if e.mode&ModeBirthTime != 0 {
+		sep()
+		b = append(b, "btime="...)
+		col := len(b)
+		if e.BirthTime.IsZero() {
+			b = append(b, na...)
+		} else {
+			b = e.BirthTime.UTC().AppendFormat(b, time.RFC3339)
+		}
+		b = pad(b, col, timeChars)
+	}
+
+	if e.mode&ModeOwner != 0 {
+		sep()
+		b = append(b, "owner="...)
+		col := len(b)
+		if e.Owner == "" {
+			b = append(b, na...)
+		} else {
+			b = append(append(append(b, e.Owner...), ':'), e.Group...)
+		}
+		b = pad(b, col, ownerChars)
 	}
 
 	if e.mode&ModeCRC32 != 0 {
 		sep()
-		sb.WriteString("crc=")
-		if e.Type != File {
-			sb.WriteString(checksumNA())
+		field := len(b)
+		b = format.appendLabel(b, "crc32", "crc", e.partial)
+		col := len(b)
+		if !e.hasSums() {
+			b = append(b, na...)
 		} else {
-			sb.WriteString(e.Checksum)
+			b = format.appendSum(b, e.Checksum)
+		}
+		b = pad(b, col, format.sumWidth(crcChars))
+		if !e.hasSums() && format.blankNA {
+			b = format.blank(b, field)
+		}
+	}
+
+	for i, hc := range format.hashes {
+		sep()
+		field := len(b)
+		b = format.appendLabel(b, hc.algo, hc.algo, e.partial)
+		sum := na
+		if i < len(e.Digests) && e.hasSums() {
+			sum = e.Digests[i].Sum
+		}
+		col := len(b)
+		b = pad(format.appendSum(b, sum), col, format.sumWidth(hc.width))
+		if !e.hasSums() && format.blankNA {
+			b = format.blank(b, field)
 		}
 	}
 
 	// Add a separator (if necessary)
 	sep()
-	return sb.String()
+	return b
 }
@@ -1,9 +1,14 @@
 package dirtree
 
 import (
+	"io"
+	"io/fs"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"testing/fstest"
+	"time"
 )
 
 func TestEntryFormat(t *testing.T) {
@@ -18,68 +23,67 @@ func TestEntryFormat(t *testing.T) {
 		mode     PrintMode
 		root     string
 		fullpath string
-		ft       FileType
 		want     string
 		wantErr  bool
 	}{
 		{
 			name: "mode=ModeType/file1",
 			mode: ModeType,
-			root: root, fullpath: file1, ft: File,
+			root: root, fullpath: file1,
 			want: "f ",
 		},
 		{
 			name: "mode=ModeSize/file1",
 			mode: ModeSize,
-			root: root, fullpath: file1, ft: File,
+			root: root, fullpath: file1,
 			want: "13b        ",
 		},
 		{
 			name: "mode=ModeStd/file1",
 			mode: ModeDefault,
-			root: root, fullpath: file1, ft: File,
+			root: root, fullpath: file1,
 			want: "f 13b        ",
 		},
 		{
 			name: "mode=ModeAll/file1",
 			mode: ModeAll,
-			root: root, fullpath: file1, ft: File,
+			root: root, fullpath: file1,
 			want: "f 13b        crc=0451ac5e ",
 		},
 		{
 			name: "mode=ModeStd/dirA",
 			mode: ModeDefault,
-			root: root, fullpath: dirA, ft: Dir,
+			root: root, fullpath: dirA,
 			want: "d            ",
 		},
 		{
 			name: "mode=ModeType/symfile1",
 			mode: ModeDefault,
-			root: root, fullpath: symfile1, ft: Other,
-			want: "?            ",
+			root: root, fullpath: symfile1,
+			want: "l            ",
 		},
 		{
 			name: "mode=ModeType/symdirA",
 			mode: ModeDefault,
-			root: root, fullpath: symdirA, ft: Other,
-			want: "?            ",
+			root: root, fullpath: symdirA,
+			want: "l            ",
 		},
 		{
 			name: "mode=ModeCRC32/file1",
 			mode: ModeCRC32,
-			root: root, fullpath: file1, ft: File,
+			root: root, fullpath: file1,
 			want: "crc=0451ac5e ",
 		},
 		{
 			name: "mode=ModeCRC32/dirA",
 			mode: ModeCRC32,
-			root: root, fullpath: dirA, ft: Dir,
+			root: root, fullpath: dirA,
 			want: "crc=n/a      ",
 		},
 		{
 			name: "mode=ModeCRC32/symfile1",
 			mode: ModeCRC32,
-			root: root, fullpath: symfile1, ft: Other,
+			root: root, fullpath: symfile1,
 			want: "crc=n/a      ",
 		},
 
@@ -87,13 +91,13 @@ func TestEntryFormat(t *testing.T) {
 		{
 			name: "mode=ModeAll/do-not-exist",
 			mode: ModeAll,
-			root: root, fullpath: "do-not-exist", ft: Other,
+			root: root, fullpath: "do-not-exist",
 			wantErr: true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			ent, err := newEntry(tt.mode, nil, tt.fullpath, tt.ft)
+			ent, err := newEntry(&config{mode: tt.mode}, nil, tt.root, tt.fullpath, lstatDirEntry(tt.fullpath))
 			if (err != nil) != tt.wantErr {
 				t.Errorf("newEntry() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -105,21 +109,300 @@ func TestEntryFormat(t *testing.T) {
 			if got := ent.Format(); got != tt.want {
 				t.Errorf("format error\ngot :%q\nwant:%q", got, tt.want)
 			}
+			if got := string(ent.AppendFormat([]byte(">"))); got != ">"+tt.want {
+				t.Errorf("AppendFormat() = %q, want %q", got, ">"+tt.want)
+			}
 		})
 	}
 }
 
+func TestAppendFormatAllocs(t *testing.T) {
+	ent := Entry{RelPath: "A/file1", Type: File, Size: 1234, Checksum: "0451ac5e", mode: ModeAll | ModeAlloc}
+	buf := make([]byte, 0, 128)
+	allocs := testing.AllocsPerRun(100, func() {
+		buf = ent.appendLine(buf[:0])
+	})
+	if allocs != 0 {
+		t.Errorf("appendLine() allocates %v times, want 0", allocs)
+	}
+}
+
+func TestEntryAccessors(t *testing.T) {
+	tests := []struct {
+		ent       Entry
+		isDir     bool
+		isRegular bool
+		base      string
+		ext       string
+		depth     int
+	}{
+		{
+			ent:   Entry{Path: "testdata/dir", RelPath: ".", Type: Dir},
+			isDir: true, base: "dir", ext: "", depth: 0,
+		},
+		{
+			ent:   Entry{Path: "testdata/dir/A", RelPath: "A", Type: Dir},
+			isDir: true, base: "A", ext: "", depth: 1,
+		},
+		{
+			ent:       Entry{Path: "testdata/dir/A/file1.tar.gz", RelPath: "A/file1.tar.gz", Type: File},
+			isRegular: true, base: "file1.tar.gz", ext: ".gz", depth: 2,
+		},
+		{
+			ent:  Entry{Path: "testdata/dir/A/B/symdirA", RelPath: "A/B/symdirA", Type: Other},
+			base: "symdirA", ext: "", depth: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ent.RelPath, func(t *testing.T) {
+			if got := tt.ent.IsDir(); got != tt.isDir {
+				t.Errorf("IsDir() = %v, want %v", got, tt.isDir)
+			}
+			if got := tt.ent.IsRegular(); got != tt.isRegular {
+				t.Errorf("IsRegular() = %v, want %v", got, tt.isRegular)
+			}
+			if got := tt.ent.Base(); got != tt.base {
+				t.Errorf("Base() = %q, want %q", got, tt.base)
+			}
+			if got := tt.ent.Ext(); got != tt.ext {
+				t.Errorf("Ext() = %q, want %q", got, tt.ext)
+			}
+			if got := tt.ent.Depth(); got != tt.depth {
+				t.Errorf("Depth() = %d, want %d", got, tt.depth)
+			}
+		})
+	}
+}
+
+func TestNativeSeparators(t *testing.T) {
+	ent := Entry{
+		RelPath:    "A/B/file2",
+		HardlinkOf: "A/file1",
+		Type:       File,
+		mode:       ModeType | ModeHardlink,
+		format:     &formatting{pathSep: '\\'},
+	}
+	if got, want := ent.String(), `f A\B\file2 => A\file1`; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	ents, err := ListFS(fstest.MapFS{"A/file1": &fstest.MapFile{}}, ".", NativeSeparators(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.FromSlash("A/file1")
+	if got := ents[2].String(); !strings.HasSuffix(got, want) {
+		t.Errorf("String() = %q, want %q suffix", got, want)
+	}
+	if ents[2].RelPath != "A/file1" {
+		t.Errorf("RelPath = %q, want slash-separated", ents[2].RelPath)
+	}
+}
+
+func TestEntryInfo(t *testing.T) {
+	mtime := time.Date(2021, 5, 5, 14, 32, 8, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"file1": &fstest.MapFile{Data: []byte("dummy"), Mode: 0640, ModTime: mtime},
+	}
+
+	for _, mode := range []PrintMode{ModeType, ModeSize} {
+		ents, err := ListFS(fsys, ".", mode, ExcludeRoot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fi := ents[0].Info()
+		if fi == nil {
+			t.Fatalf("%v: Info() = nil", mode)
+		}
+		if fi.Name() != "file1" || fi.Size() != 5 || fi.Mode() != 0640 || !fi.ModTime().Equal(mtime) {
+			t.Errorf("%v: Info() = %v %v %v %v", mode, fi.Name(), fi.Size(), fi.Mode(), fi.ModTime())
+		}
+	}
+
+	if fi := (&Entry{}).Info(); fi != nil {
+		t.Errorf("Info() = %v, want nil", fi)
+	}
+}
+
+func TestEntryOpen(t *testing.T) {
+	fsys := fstest.MapFS{
+		"A/file1": &fstest.MapFile{Data: []byte("dummy")},
+	}
+	mapEnts, err := ListFS(fsys, "A", Type("f"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	osEnts, err := List(filepath.Join("testdata", "dir", "A"), Type("f"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, e := range []*Entry{mapEnts[0], osEnts[0]} {
+		want := "dummy"
+		if e.fsys == nil {
+			want = "dummy content"
+		}
+		f, err := e.Open()
+		if err != nil {
+			t.Fatalf("%s: Open() error = %v", e.Path, err)
+		}
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("%s: read %q, want %q", e.Path, data, want)
+		}
+	}
+
+	if _, err := (&Entry{Path: "do-not-exist"}).Open(); err == nil {
+		t.Errorf("Open() on missing file should fail")
+	}
+}
+
+func TestModeAlloc(t *testing.T) {
+	t.Run("MapFS", func(t *testing.T) {
+		fsys := fstest.MapFS{"file": &fstest.MapFile{Data: []byte("content")}}
+		got, err := SprintFS(fsys, ".", ModeDiskUsage)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "           alloc=n/a        .\n" +
+			"7b         alloc=n/a        file\n"
+		if got != want {
+			t.Errorf("got:\n%q\nwant:\n%q", got, want)
+		}
+	})
+
+	t.Run("OS", func(t *testing.T) {
+		list, err := List(filepath.Join("testdata", "dir", "A", "file1"), ModeAlloc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, known := allocSize(lstatInfo(t, filepath.Join("testdata", "dir", "A", "file1")))
+		if got := list[0].Alloc; (got >= 0) != known {
+			t.Errorf("Alloc = %d, want known=%t", got, known)
+		}
+	})
+}
+
+func TestModeBirthTime(t *testing.T) {
+	t.Run("MapFS", func(t *testing.T) {
+		fsys := fstest.MapFS{"file": &fstest.MapFile{}}
+		got, err := SprintFS(fsys, "file", ModeBirthTime)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "btime=n/a                  .\n"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("OS", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "file")
+		touch(t, path)
+		list, err := List(path, ModeBirthTime)
+		if err != nil {
+			t.Fatal(err)
+		}
+		btime := list[0].BirthTime
+		if btime.IsZero() {
+			t.Skip("creation time not supported on this platform or filesystem")
+		}
+		if d := time.Since(btime); d < -time.Minute || d > time.Minute {
+			t.Errorf("BirthTime = %v, too far from now", btime)
+		}
+		if got := list[0].Format(); !strings.HasPrefix(got, "btime="+btime.UTC().Format(time.RFC3339)) {
+			t.Errorf("Format() = %q", got)
+		}
+	})
+}
+
+func lstatInfo(t *testing.T, path string) fs.FileInfo {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fi
+}
+
+func Test_filetypeFromDirEntry(t *testing.T) {
+	fsys := fstest.MapFS{
+		"b": &fstest.MapFile{Mode: fs.ModeDevice},
+		"c": &fstest.MapFile{Mode: fs.ModeDevice | fs.ModeCharDevice},
+		"d": &fstest.MapFile{Mode: fs.ModeDir},
+		"f": &fstest.MapFile{},
+		"l": &fstest.MapFile{Mode: fs.ModeSymlink},
+		"p": &fstest.MapFile{Mode: fs.ModeNamedPipe},
+		"s": &fstest.MapFile{Mode: fs.ModeSocket},
+		"?": &fstest.MapFile{Mode: fs.ModeIrregular},
+	}
+
+	dirents, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dirent := range dirents {
+		if got := filetypeFromDirEntry(dirent).char(); string(got) != dirent.Name() {
+			t.Errorf("filetypeFromDirEntry(%s).char() = %c", dirent.Name(), got)
+		}
+	}
+}
+
 func Test_checksumNA(t *testing.T) {
 	// Verify that checksum does not fail on error and that instead, it returns
 	// the string returned by checksumNA. Errors are caught before.
 	t.Run("fsys=nil", func(t *testing.T) {
-		if got := checksum(nil, "do-not-exist"); got != checksumNA() {
+		if got, _, _, _ := checksum(nil, "do-not-exist", 0, nil); got != checksumNA() {
 			t.Errorf("checksum() = %v, want %v", got, checksumNA())
 		}
 	})
 	t.Run("fsys=MapFS", func(t *testing.T) {
-		if got := checksum(fstest.MapFS{}, "do-not-exist"); got != checksumNA() {
+		if got, _, _, _ := checksum(fstest.MapFS{}, "do-not-exist", 0, nil); got != checksumNA() {
 			t.Errorf("checksum() = %v, want %v", got, checksumNA())
 		}
 	})
 }
+
+// lstatDirEntry returns a fs.DirEntry for the file at path, as if it were
+// obtained while walking its parent directory.
+func lstatDirEntry(path string) fs.DirEntry {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return errDirEntry{name: filepath.Base(path), err: err}
+	}
+	return infoDirEntry{fi}
+}
+
+// errDirEntry is a fs.DirEntry which Info method fails.
+type errDirEntry struct {
+	name string
+	err  error
+}
+
+func (d errDirEntry) Name() string               { return d.name }
+func (d errDirEntry) IsDir() bool                { return false }
+func (d errDirEntry) Type() fs.FileMode          { return 0 }
+func (d errDirEntry) Info() (fs.FileInfo, error) { return nil, d.err }
+
+func TestModeExec(t *testing.T) {
+	fsys := fstest.MapFS{
+		"run.sh":   &fstest.MapFile{Data: []byte("#!/bin/sh"), Mode: 0o755},
+		"data.csv": &fstest.MapFile{Data: []byte("a,b"), Mode: 0o644},
+		"group-x":  &fstest.MapFile{Mode: 0o610},
+		"dir":      &fstest.MapFile{Mode: fs.ModeDir | 0o755},
+	}
+	got, err := SprintFS(fsys, ".", ModeType|ModeExec, ExcludeRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `f - data.csv
+d   dir
+f x group-x
+f x run.sh
+`
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,197 @@
+package dirtree
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// A Node is a file of a tree built from a listing by Build, linked to its
+// parent directory and to the files it contains.
+type Node struct {
+	RelPath  string  // RelPath is the path relative to the root, "." for the root
+	Entry    *Entry  // Entry is the listed entry, nil for directories not listed
+	Parent   *Node   // Parent is the parent directory, nil for the root
+	Children []*Node // Children are the files in the directory, in listing order
+
+	// Aggregates over the subtree rooted at the node, the node excluded.
+	Files int   // Files is the number of regular files
+	Dirs  int   // Dirs is the number of directories
+	Bytes int64 // Bytes is the total size of the regular files, with ModeSize
+}
+
+// Build builds the tree of the entries of a listing, as returned by List, and
+// returns its root. Directories which are not part of the listing, because
+// they've been filtered out, are still created so that all nodes are linked to
+// the root, but their Entry is nil. Entries which RelPath isn't a valid
+// relative path, as those returned by Stat for absolute paths, are skipped.
+func Build(entries []*Entry) *Node {
+	root := &Node{RelPath: "."}
+	nodes := map[string]*Node{".": root}
+
+	var node func(rel string) *Node
+	node = func(rel string) *Node {
+		if n, ok := nodes[rel]; ok {
+			return n
+		}
+		parent := node(path.Dir(rel))
+		n := &Node{RelPath: rel, Parent: parent}
+		parent.Children = append(parent.Children, n)
+		nodes[rel] = n
+		return n
+	}
+	for _, e := range entries {
+		if fs.ValidPath(e.RelPath) {
+			node(e.RelPath).Entry = e
+		}
+	}
+	root.aggregate()
+	return root
+}
+
+// aggregate computes the aggregates of the subtree rooted at n.
+func (n *Node) aggregate() {
+	for _, c := range n.Children {
+		c.aggregate()
+		n.Files += c.Files
+		n.Dirs += c.Dirs
+		n.Bytes += c.Bytes
+		switch {
+		case c.IsDir():
+			n.Dirs++
+		case c.Entry.IsRegular():
+			n.Files++
+			n.Bytes += c.Entry.Size
+		}
+	}
+}
+
+// Name returns the last element of the node path.
+func (n *Node) Name() string { return path.Base(n.RelPath) }
+
+// IsDir reports whether n is a directory.
+func (n *Node) IsDir() bool { return n.Entry == nil || n.Entry.IsDir() }
+
+// Walk calls fn for n and each node of its subtree, in depth-first order,
+// parents before their children. If fn returns fs.SkipDir for a node, the
+// children of that node are skipped. Any other error stops the walk and is
+// returned by Walk.
+func (n *Node) Walk(fn func(n *Node) error) error {
+	err := n.walk(fn)
+	if err == fs.SkipDir {
+		return nil
+	}
+	return err
+}
+
+func (n *Node) walk(fn func(n *Node) error) error {
+	if err := fn(n); err != nil {
+		return err
+	}
+	for _, c := range n.Children {
+		if err := c.walk(fn); err != nil && err != fs.SkipDir {
+			return err
+		}
+	}
+	return nil
+}
+
+// WalkPost calls fn for n and each node of its subtree, in depth-first order,
+// children before their parents, which is handy to compute aggregates. An error
+// returned by fn stops the walk and is returned by WalkPost.
+func (n *Node) WalkPost(fn func(n *Node) error) error {
+	for _, c := range n.Children {
+		if err := c.WalkPost(fn); err != nil {
+			return err
+		}
+	}
+	return fn(n)
+}
+
+// Subtree returns the node at path, relative to n and slash-separated, or nil
+// if there's none.
+func (n *Node) Subtree(relpath string) *Node {
+	relpath = path.Clean(relpath)
+	if relpath == "." {
+		return n
+	}
+	if strings.HasPrefix(relpath, "../") || relpath == ".." || path.IsAbs(relpath) {
+		return nil
+	}
+next:
+	for _, name := range strings.Split(relpath, "/") {
+		for _, c := range n.Children {
+			if c.Name() == name {
+				n = c
+				continue next
+			}
+		}
+		return nil
+	}
+	return n
+}
+
+// Find returns the nodes of the subtree rooted at n, n included, which RelPath
+// matches pattern, in depth-first order. Patterns have the syntax of the Match
+// option, a trailing slash only matching directories. It returns an error
+// wrapping ErrInvalidPattern if pattern is malformed.
+func (n *Node) Find(pattern string) ([]*Node, error) {
+	p, err := newPattern(pattern, match)
+	if err != nil {
+		return nil, fmt.Errorf("dirtree: %w: %q: %v", ErrInvalidPattern, pattern, err)
+	}
+	var found []*Node
+	n.Walk(func(n *Node) error {
+		if p.matches(n.RelPath, n.IsDir()) {
+			found = append(found, n)
+		}
+		return nil
+	})
+	return found, nil
+}
+
+// FilterFunc returns a copy of the subtree rooted at n, only keeping the nodes
+// for which keep returns true and their parent directories, so that they stay
+// linked to the root. The aggregates of the copy only count the nodes kept. n
+// is left untouched, but the entries are shared with the copy.
+func (n *Node) FilterFunc(keep func(n *Node) bool) *Node {
+	cp := n.filter(keep)
+	if cp == nil {
+		cp = &Node{RelPath: n.RelPath, Entry: n.Entry}
+	}
+	cp.aggregate()
+	return cp
+}
+
+// filter returns the copy of n, or nil if neither n nor any of its children is
+// kept.
+func (n *Node) filter(keep func(n *Node) bool) *Node {
+	var children []*Node
+	for _, c := range n.Children {
+		if cc := c.filter(keep); cc != nil {
+			children = append(children, cc)
+		}
+	}
+	if children == nil && !keep(n) {
+		return nil
+	}
+	cp := &Node{RelPath: n.RelPath, Entry: n.Entry, Children: children}
+	for _, c := range children {
+		c.Parent = cp
+	}
+	return cp
+}
+
+// Entries returns the entries of n and its subtree, in depth-first order,
+// skipping directories which are not part of the listing.
+func (n *Node) Entries() Entries {
+	var ents Entries
+	n.Walk(func(n *Node) error {
+		if n.Entry != nil {
+			ents = append(ents, n.Entry)
+		}
+		return nil
+	})
+	return ents
+}
@@ -0,0 +1,146 @@
+package dirtree
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestBuild(t *testing.T) {
+	fsys := fstest.MapFS{
+		"A/file1":   &fstest.MapFile{Data: []byte("dummy")},
+		"A/B/file2": &fstest.MapFile{Data: []byte("content")},
+		"C":         &fstest.MapFile{Data: []byte("c")},
+	}
+
+	ents, err := ListFS(fsys, ".", ModeSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := Build(ents)
+	if root.Entry != ents[0] || root.Parent != nil {
+		t.Errorf("root = %+v, want the . entry without parent", root)
+	}
+	if root.Files != 3 || root.Dirs != 2 || root.Bytes != 13 {
+		t.Errorf("root aggregates = %d files, %d dirs, %d bytes, want 3, 2, 13", root.Files, root.Dirs, root.Bytes)
+	}
+	a := root.Children[0]
+	if a.Name() != "A" || a.Parent != root || a.Files != 2 || a.Dirs != 1 || a.Bytes != 12 {
+		t.Errorf("A = %+v", a)
+	}
+
+	var visited []string
+	err = root.Walk(func(n *Node) error {
+		visited = append(visited, n.RelPath)
+		if n.RelPath == "A/B" {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(visited, " "), ". A A/B A/file1 C"; got != want {
+		t.Errorf("Walk() visited %q, want %q", got, want)
+	}
+
+	// Directories filtered out of the listing are still created.
+	ents, err = ListFS(fsys, ".", Type("f"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root = Build(ents)
+	b := root.Children[0].Children[0]
+	if b.RelPath != "A/B" || b.Entry != nil || !b.IsDir() || b.Children[0].Entry == nil {
+		t.Errorf("A/B = %+v, want a directory without entry", b)
+	}
+	if root.Files != 3 || root.Dirs != 2 {
+		t.Errorf("root aggregates = %d files, %d dirs, want 3, 2", root.Files, root.Dirs)
+	}
+
+	// Entries which RelPath isn't relative are skipped.
+	ents = []*Entry{
+		{RelPath: "/abs/path", Type: File},
+		{RelPath: "../up", Type: File},
+		{RelPath: "ok", Type: File},
+	}
+	root = Build(ents)
+	if len(root.Children) != 1 || root.Children[0].RelPath != "ok" || root.Files != 1 {
+		t.Errorf("Build(invalid paths) = %+v, want only ok", root.Children)
+	}
+}
+
+func TestNodeQueries(t *testing.T) {
+	fsys := fstest.MapFS{
+		"A/file1.go": &fstest.MapFile{Data: []byte("dummy")},
+		"A/B/file2":  &fstest.MapFile{Data: []byte("content")},
+		"C.go":       &fstest.MapFile{Data: []byte("c")},
+	}
+
+	ents, err := ListFS(fsys, ".", ModeSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := Build(ents)
+
+	relpaths := func(nodes []*Node) string {
+		var paths []string
+		for _, n := range nodes {
+			paths = append(paths, n.RelPath)
+		}
+		return strings.Join(paths, " ")
+	}
+
+	found, err := root.Find("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := relpaths(found), "C.go"; got != want {
+		t.Errorf("Find(*.go) = %q, want %q", got, want)
+	}
+	if found, _ = root.Find("A/*/"); relpaths(found) != "A/B" {
+		t.Errorf("Find(A/*/) = %q, want %q", relpaths(found), "A/B")
+	}
+	if _, err := root.Find("[a-"); !errors.Is(err, ErrInvalidPattern) {
+		t.Errorf("Find() error = %v, want ErrInvalidPattern", err)
+	}
+
+	b := root.Subtree("A/B")
+	if b == nil || b.RelPath != "A/B" {
+		t.Fatalf("Subtree(A/B) = %v", b)
+	}
+	if got := root.Subtree("A").Subtree("B/file2"); got == nil || got.RelPath != "A/B/file2" {
+		t.Errorf("Subtree(B/file2) = %v", got)
+	}
+	for _, p := range []string{"missing", "../A", "A/file1.go/x"} {
+		if got := root.Subtree(p); got != nil {
+			t.Errorf("Subtree(%q) = %v, want nil", p, got)
+		}
+	}
+
+	var post []string
+	root.Subtree("A").WalkPost(func(n *Node) error {
+		post = append(post, n.RelPath)
+		return nil
+	})
+	if got, want := strings.Join(post, " "), "A/B/file2 A/B A/file1.go A"; got != want {
+		t.Errorf("WalkPost() visited %q, want %q", got, want)
+	}
+
+	small := root.FilterFunc(func(n *Node) bool { return !n.IsDir() && n.Entry.Size < 6 })
+	var kept []string
+	for _, e := range small.Entries() {
+		kept = append(kept, e.RelPath)
+	}
+	if got, want := strings.Join(kept, " "), ". A A/file1.go C.go"; got != want {
+		t.Errorf("FilterFunc() entries = %q, want %q", got, want)
+	}
+	if small.Files != 2 || small.Bytes != 6 || small.Children[0].Parent != small {
+		t.Errorf("FilterFunc() = %+v", small)
+	}
+	if root.Files != 3 {
+		t.Errorf("FilterFunc() modified the original tree")
+	}
+}
@@ -0,0 +1,189 @@
+package dirtree
+
+import (
+	"sort"
+	"unicode/utf8"
+)
+
+//go:generate go run gen_norm.go
+
+// NFC returns s in Unicode Normalization Form C, characters being composed as
+// much as possible. File names are usually in NFC on Linux and Windows. NFC is
+// meant to be used with the Normalize option:
+//
+//	dirtree.List("dir", dirtree.Normalize(dirtree.NFC))
+//
+// Bytes of s which are not valid UTF-8 are left as is.
+func NFC(s string) string { return normalize(s, true) }
+
+// NFD returns s in Unicode Normalization Form D, characters being decomposed.
+// File names are usually in NFD on macOS. NFD is meant to be used with the
+// Normalize option:
+//
+//	dirtree.List("dir", dirtree.Normalize(dirtree.NFD))
+//
+// Bytes of s which are not valid UTF-8 are left as is.
+func NFD(s string) string { return normalize(s, false) }
+
+type cccRange struct {
+	lo, hi rune
+	ccc    uint8
+}
+
+type decomposition struct {
+	r rune
+	d string
+}
+
+type composition struct{ a, b, c rune }
+
+// Hangul syllables are decomposed and composed algorithmically.
+const (
+	hangulSBase  = 0xAC00
+	hangulLBase  = 0x1100
+	hangulVBase  = 0x1161
+	hangulTBase  = 0x11A7
+	hangulLCount = 19
+	hangulVCount = 21
+	hangulTCount = 28
+	hangulNCount = hangulVCount * hangulTCount
+	hangulSCount = hangulLCount * hangulNCount
+)
+
+func normalize(s string, compose bool) string {
+	i := 0
+	for i < len(s) && s[i] < utf8.RuneSelf {
+		i++
+	}
+	if i == len(s) {
+		return s
+	}
+	if i > 0 {
+		// The last ASCII character may compose with the following ones.
+		i--
+	}
+
+	b := make([]byte, i, len(s)+utf8.UTFMax)
+	copy(b, s)
+	var seg []rune // decomposed characters, since the last invalid byte
+	flush := func() {
+		reorder(seg)
+		if compose {
+			seg = recompose(seg)
+		}
+		var buf [utf8.UTFMax]byte
+		for _, r := range seg {
+			n := utf8.EncodeRune(buf[:], r)
+			b = append(b, buf[:n]...)
+		}
+		seg = seg[:0]
+	}
+	for i < len(s) {
+		r, n := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && n == 1 {
+			flush()
+			b = append(b, s[i])
+			i++
+			continue
+		}
+		seg = decompose(seg, r)
+		i += n
+	}
+	flush()
+	return string(b)
+}
+
+// decompose appends the full canonical decomposition of r to rs.
+func decompose(rs []rune, r rune) []rune {
+	if r < 0xC0 {
+		return append(rs, r)
+	}
+	if s := r - hangulSBase; s >= 0 && s < hangulSCount {
+		rs = append(rs, hangulLBase+s/hangulNCount, hangulVBase+s%hangulNCount/hangulTCount)
+		if t := s % hangulTCount; t != 0 {
+			rs = append(rs, hangulTBase+t)
+		}
+		return rs
+	}
+	i := sort.Search(len(decompositions), func(i int) bool { return decompositions[i].r >= r })
+	if i < len(decompositions) && decompositions[i].r == r {
+		for _, d := range decompositions[i].d {
+			rs = append(rs, d)
+		}
+		return rs
+	}
+	return append(rs, r)
+}
+
+// reorder sorts the sequences of non-starters of rs by combining class.
+func reorder(rs []rune) {
+	for i := 1; i < len(rs); i++ {
+		c := ccc(rs[i])
+		if c == 0 {
+			continue
+		}
+		for j := i; j > 0 && ccc(rs[j-1]) > c; j-- {
+			rs[j-1], rs[j] = rs[j], rs[j-1]
+		}
+	}
+}
+
+// recompose applies the canonical composition algorithm to rs, in place, and
+// returns the composed characters.
+func recompose(rs []rune) []rune {
+	if len(rs) == 0 {
+		return rs
+	}
+	starter := 0
+	last := ccc(rs[0]) // class of the last character not composed
+	if last != 0 {
+		last = 256 // no starter to compose with
+	}
+	n := 1
+	for _, r := range rs[1:] {
+		c := ccc(r)
+		if last < c || last == 0 {
+			if comp, ok := composePair(rs[starter], r); ok {
+				rs[starter] = comp
+				continue
+			}
+		}
+		if c == 0 {
+			starter = n
+		}
+		last = c
+		rs[n] = r
+		n++
+	}
+	return rs[:n]
+}
+
+// composePair returns the primary composite of a and b, if any.
+func composePair(a, b rune) (rune, bool) {
+	if l, v := a-hangulLBase, b-hangulVBase; l >= 0 && l < hangulLCount && v >= 0 && v < hangulVCount {
+		return hangulSBase + (l*hangulVCount+v)*hangulTCount, true
+	}
+	if s, t := a-hangulSBase, b-hangulTBase; s >= 0 && s < hangulSCount && s%hangulTCount == 0 && t > 0 && t < hangulTCount {
+		return a + t, true
+	}
+	i := sort.Search(len(compositions), func(i int) bool {
+		c := compositions[i]
+		return c.a > a || c.a == a && c.b >= b
+	})
+	if i < len(compositions) && compositions[i].a == a && compositions[i].b == b {
+		return compositions[i].c, true
+	}
+	return 0, false
+}
+
+// ccc returns the canonical combining class of r.
+func ccc(r rune) int {
+	if r < 0x300 {
+		return 0
+	}
+	i := sort.Search(len(cccRanges), func(i int) bool { return cccRanges[i].hi >= r })
+	if i < len(cccRanges) && cccRanges[i].lo <= r {
+		return int(cccRanges[i].ccc)
+	}
+	return 0
+}
@@ -0,0 +1,42 @@
+package dirtree
+
+import (
+	"testing"
+	"unicode"
+)
+
+func TestNormalizationForms(t *testing.T) {
+	tests := []struct {
+		in, nfc, nfd string
+	}{
+		{"dir/file.go", "dir/file.go", "dir/file.go"},
+		{"caf\u00e9", "caf\u00e9", "cafe\u0301"},
+		{"cafe\u0301", "caf\u00e9", "cafe\u0301"},
+		// Combining marks are reordered by class.
+		{"a\u0301\u0323", "\u1ea1\u0301", "a\u0323\u0301"},
+		{"\u1e0b\u0323", "\u1e0d\u0307", "d\u0323\u0307"},
+		// Blocked composition.
+		{"a\u0301\u0301", "\u00e1\u0301", "a\u0301\u0301"},
+		// Singletons and composition exclusions are never composed.
+		{"\u212b", "\u00c5", "A\u030a"},
+		{"\u0958", "\u0915\u093c", "\u0915\u093c"},
+		// Hangul syllables.
+		{"\ud55c\uae00", "\ud55c\uae00", "\u1112\u1161\u11ab\u1100\u1173\u11af"},
+		{"\u1112\u1161\u11ab", "\ud55c", "\u1112\u1161\u11ab"},
+		// Invalid UTF-8 is kept.
+		{"e\xff\u0301", "e\xff\u0301", "e\xff\u0301"},
+		{"\xffcafe\u0301", "\xffcaf\u00e9", "\xffcafe\u0301"},
+	}
+	for _, tt := range tests {
+		if got := NFC(tt.in); got != tt.nfc {
+			t.Errorf("NFC(%+q) = %+q, want %+q", tt.in, got, tt.nfc)
+		}
+		if got := NFD(tt.in); got != tt.nfd {
+			t.Errorf("NFD(%+q) = %+q, want %+q", tt.in, got, tt.nfd)
+		}
+	}
+
+	if normVersion != unicode.Version {
+		t.Logf("normalization tables are for Unicode %s, unicode package for %s", normVersion, unicode.Version)
+	}
+}
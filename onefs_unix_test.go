@@ -0,0 +1,56 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package dirtree
+
+import (
+	"io/fs"
+	"reflect"
+	"syscall"
+	"testing"
+	"testing/fstest"
+)
+
+func TestOneFileSystem(t *testing.T) {
+	dir := func(dev uint64) *fstest.MapFile {
+		st := &syscall.Stat_t{}
+		// The type of Stat_t.Dev depends on the platform.
+		switch v := reflect.ValueOf(&st.Dev).Elem(); v.Kind() {
+		case reflect.Int32, reflect.Int64:
+			v.SetInt(int64(dev))
+		default:
+			v.SetUint(dev)
+		}
+		return &fstest.MapFile{Mode: fs.ModeDir, Sys: st}
+	}
+	fsys := fstest.MapFS{
+		"root":            dir(1),
+		"root/a":          dir(1),
+		"root/a/file":     &fstest.MapFile{},
+		"root/mnt":        dir(2),
+		"root/mnt/sub":    dir(2),
+		"root/mnt/file":   &fstest.MapFile{},
+		"root/mnt/sub/f2": &fstest.MapFile{},
+	}
+
+	list := func(opts ...Option) []string {
+		t.Helper()
+		ents, err := ListFS(fsys, "root", opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var paths []string
+		for _, e := range ents {
+			paths = append(paths, e.RelPath)
+		}
+		return paths
+	}
+
+	want := []string{".", "a", "a/file", "mnt"}
+	if got := list(OneFileSystem(true)); !reflect.DeepEqual(got, want) {
+		t.Errorf("OneFileSystem(true) got %q, want %q", got, want)
+	}
+	if got := list(OneFileSystem(false)); len(got) != 7 {
+		t.Errorf("OneFileSystem(false) got %q, want 7 entries", got)
+	}
+}
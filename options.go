@@ -2,15 +2,65 @@ package dirtree
 
 import (
 	"fmt"
+	"io"
 	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
 )
 
 type config struct {
-	mode     PrintMode
-	showRoot bool
-	globs    []pattern
-	depth    int
-	types    FileType
+	mode       PrintMode
+	showRoot   bool
+	globs      []pattern
+	depth      int
+	types      FileType
+	stats      *Stats
+	collisions *CaseCollisions
+	logfn      LogFunc
+	logmu      *sync.Mutex // serializes the calls to logfn
+
+	hashLimit     int64
+	detectChanges bool
+	retry         Retry
+	throttle      int64
+	idleIO        bool
+	entryTimeout  time.Duration
+	numericOwners bool
+	bufSize       int
+	format        formatting
+	normalize     Normalize
+	collation     Collation
+	pathLimit     int
+	budget        int64
+	deadline      time.Duration
+	cursor        string
+	oneFS         bool
+	matchBase     bool
+	encoding      Encoding
+	header        bool
+	follow        bool
+	loops         *SymlinkLoops
+	maxChain      int
+	dirSums       bool
+	markEmpty     bool
+	gitignore     bool
+
+	jobs int
+	pool bool
+
+	dryRun       bool
+	deleteExtras bool
+
+	// walk state
+	hardlinks hardlinks
+	owners    ownerNames
+	throttler *throttler       // throttles checksum reads
+	limit     int              // number of entries listed by listTree, 0 for all
+	deferHash bool             // checksums are computed by a hashPipeline
+	start     string           // directory, or file, below root where the walk starts
+	descend   func(dir string) // called with each directory the walk descends into
 }
 
 var defaultCfg = config{
@@ -18,7 +68,9 @@ var defaultCfg = config{
 	showRoot: true,
 	globs:    nil,
 	depth:    int(infiniteDepth),
-	types:    File | Dir | Other,
+	types:    File | Dir | irregular,
+	bufSize:  defaultBufSize,
+	format:   defaultFormatting,
 }
 
 // Option is the interface implemented by dirtree types used to control what to
@@ -29,33 +81,99 @@ type Option interface {
 
 // The Type option limits the files to list based their type.
 // Type can be formed of one or more of:
-//  'f' for regular files
-//  'd' for directories
-//  '?' for anything else (symlink, etc.)
+//
+//	'f' for regular files
+//	'd' for directories
+//	'l' for symbolic links (and Windows junctions and reparse points)
+//	'p' for named pipes
+//	's' for sockets
+//	'c' for character devices
+//	'b' for block devices
+//	'?' for anything else than regular files and directories, that is all the
+//	    above types and the ones not covered by them.
 type Type string
 
 func (t Type) apply(cfg *config) error {
 	if t == "" {
-		return fmt.Errorf("invalid Type: at least one type must be listed")
+		return fmt.Errorf("%w: at least one type must be listed", ErrInvalidType)
 	}
 
 	var types FileType
+next:
 	for _, r := range string(t) {
-		switch r {
-		case rune(File.char()):
-			types |= File
-		case rune(Dir.char()):
-			types |= Dir
-		case rune(Other.char()):
-			types |= Other
-		default:
-			return fmt.Errorf("invalid Type char %c, must be %c, %c or %c", r, File.char(), Dir.char(), Other.char())
+		if r == rune(Other.char()) {
+			types |= irregular
+			continue
+		}
+		for _, ft := range fileTypes {
+			if r == rune(ft.char()) {
+				types |= ft
+				continue next
+			}
 		}
+		return fmt.Errorf("%w: char %c, must be one of %s", ErrInvalidType, r, typeChars())
 	}
 	cfg.types = types
 	return nil
 }
 
+// typeChars returns the list of chars accepted by the Type option.
+func typeChars() string {
+	b := make([]byte, len(fileTypes))
+	for i, ft := range fileTypes {
+		b[i] = ft.char()
+	}
+	return string(b)
+}
+
+// The TypeNames option overrides the strings printed for file types, when
+// ModeType is set. Types which are not present in the map keep their default
+// char. Since all type names are padded to the longest one, the columns stay
+// aligned. For example:
+//
+//	dirtree.TypeNames{dirtree.Dir: "DIR", dirtree.File: "REG"}
+//
+// TypeNames has no effect on the chars accepted by the Type option.
+type TypeNames map[FileType]string
+
+func (tn TypeNames) apply(cfg *config) error {
+	names := make(map[FileType]string, len(tn))
+	width := 0
+	for _, ft := range fileTypes {
+		name, ok := tn[ft]
+		if !ok {
+			name = string(ft.char())
+		} else {
+			if name == "" || strings.ContainsAny(name, "\t\n\r") {
+				return fmt.Errorf("%w: TypeNames: invalid name %q", ErrInvalidOption, name)
+			}
+			names[ft] = name
+		}
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+	for ft := range tn {
+		if _, ok := names[ft]; !ok {
+			return fmt.Errorf("%w: TypeNames: invalid FileType %d", ErrInvalidOption, ft)
+		}
+	}
+
+	cfg.format.typeNames = names
+	cfg.format.typeWidth = width
+	return nil
+}
+
+// The MarkBrokenLinks option, when true, resolves symbolic links and prints 'x'
+// instead of 'l' as the type of those which target doesn't exist, so that
+// dangling links stand out in the listing. It only has effect with ModeType.
+type MarkBrokenLinks bool
+
+func (mb MarkBrokenLinks) apply(cfg *config) error {
+	cfg.format.markBroken = bool(mb)
+	return nil
+}
+
 // The ExcludeRoot option hides the root directory from the list.
 var ExcludeRoot Option = IncludeRoot(false)
 
@@ -69,11 +187,59 @@ func (in IncludeRoot) apply(cfg *config) error {
 }
 
 type pattern struct {
-	pat string        // pattern matched against
-	moi matchOrIgnore // is this a match or an ignore pattern
+	pat     string        // pattern matched against
+	moi     matchOrIgnore // is this a match or an ignore pattern
+	name    bool          // match against each path element rather than the whole path
+	prune   bool          // don't descend into matching directories
+	dirOnly bool          // only match directories
+	base    bool          // match against the last path element (MatchBase)
+}
+
+// newPattern returns the pattern corresponding to pat. A trailing slash
+// restricts the pattern to directories, like in .gitignore files.
+func newPattern(pat string, moi matchOrIgnore) (pattern, error) {
+	p := pattern{pat: pat, moi: moi}
+	if len(pat) > 1 && pat[len(pat)-1] == '/' {
+		p.pat = pat[:len(pat)-1]
+		p.dirOnly = true
+	}
+	if _, err := filepath.Match(p.pat, "/"); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+// matches reports whether path, which is a directory if isDir is true, matches
+// p.
+func (p pattern) matches(path string, isDir bool) bool {
+	if !p.name {
+		if p.dirOnly && !isDir {
+			return false
+		}
+		if p.base {
+			path = path[strings.LastIndexByte(path, '/')+1:]
+		}
+		m, _ := filepath.Match(p.pat, path)
+		return m
+	}
+	for {
+		i := strings.IndexByte(path, '/')
+		if i < 0 {
+			if p.dirOnly && !isDir {
+				return false
+			}
+			m, _ := filepath.Match(p.pat, path)
+			return m
+		}
+		// Elements before the last are necessarily directories.
+		if m, _ := filepath.Match(p.pat, path[:i]); m {
+			return true
+		}
+		path = path[i+1:]
+	}
 }
 
-func shouldKeepPath(path string, ps []pattern) bool {
+func shouldKeepPath(path string, isDir bool, ps []pattern) bool {
 	if ps == nil {
 		return true
 	}
@@ -81,7 +247,7 @@ func shouldKeepPath(path string, ps []pattern) bool {
 	keep := false
 	hasMatch := false
 	for _, p := range ps {
-		m, _ := filepath.Match(p.pat, path)
+		m := p.matches(path, isDir)
 		if m && p.moi == ignore {
 			return false
 		}
@@ -93,6 +259,16 @@ func shouldKeepPath(path string, ps []pattern) bool {
 	return !hasMatch || keep
 }
 
+// shouldPrune reports whether the directory at path matches a Prune pattern.
+func shouldPrune(path string, ps []pattern) bool {
+	for _, p := range ps {
+		if p.prune && p.matches(path, true) {
+			return true
+		}
+	}
+	return false
+}
+
 // The Ignore option allows to ignore files matching a pattern. The path
 // relative to the chosen root is matched against the pattern. Ignore follows
 // the syntax used and described with the filepath.Match function. Before
@@ -103,13 +279,18 @@ func shouldKeepPath(path string, ps []pattern) bool {
 // Ignore can be provided multiple times to ignore multiple patterns. A file is
 // ignored from the listing as long as at it matches at least one Ignore
 // pattern. Also, Ignore has precedence over Match.
+//
+// Like in .gitignore files, a pattern ending with a slash only matches
+// directories: Ignore("*/build/") ignores build directories but not regular
+// files named build.
 type Ignore string
 
 func (i Ignore) apply(cfg *config) error {
-	if _, err := filepath.Match(string(i), "/"); err != nil {
-		return fmt.Errorf("invalid ignore pattern %v: %v", i, err)
+	p, err := newPattern(string(i), ignore)
+	if err != nil {
+		return fmt.Errorf("%w: Ignore(%q): %v", ErrInvalidPattern, string(i), err)
 	}
-	cfg.globs = append(cfg.globs, pattern{pat: string(i), moi: ignore})
+	cfg.globs = append(cfg.globs, p)
 	return nil
 }
 
@@ -122,13 +303,67 @@ func (i Ignore) apply(cfg *config) error {
 // Match can be provided multiple times to match multiple patterns. A file is
 // included in the listing as long as at it matches at least one Match pattern,
 // unless it matches an Ignore pattern (since Ignore has precedence over Match).
+//
+// A pattern ending with a slash only matches directories.
 type Match string
 
 func (m Match) apply(cfg *config) error {
-	if _, err := filepath.Match(string(m), "/"); err != nil {
-		return fmt.Errorf("invalid match pattern %v: %v", m, err)
+	p, err := newPattern(string(m), match)
+	if err != nil {
+		return fmt.Errorf("%w: Match(%q): %v", ErrInvalidPattern, string(m), err)
+	}
+	cfg.globs = append(cfg.globs, p)
+	return nil
+}
+
+// The MatchBase option, when true, matches the Ignore, Match and Prune patterns
+// against the base name of files, that is the last element of their path,
+// instead of the whole relative path, like find -name does. For example
+// Ignore("*.tmp") then ignores the .tmp files at any depth.
+type MatchBase bool
+
+func (mb MatchBase) apply(cfg *config) error {
+	cfg.matchBase = bool(mb)
+	return nil
+}
+
+// ignoreNames ignores the directories having one of the names, and all their
+// descendants.
+type ignoreNames []string
+
+func (in ignoreNames) apply(cfg *config) error {
+	for _, name := range in {
+		cfg.globs = append(cfg.globs, pattern{pat: name, moi: ignore, name: true, prune: true, dirOnly: true})
 	}
-	cfg.globs = append(cfg.globs, pattern{pat: string(m), moi: match})
+	return nil
+}
+
+// IgnoreVCS is a preset option that ignores the directories used by version
+// control systems (.git, .hg, .svn, .bzr), and their content, at any depth.
+var IgnoreVCS Option = ignoreNames{".git", ".hg", ".svn", ".bzr"}
+
+// IgnoreBuildArtifacts is a preset option that ignores the directories commonly
+// containing build artifacts and dependencies (node_modules, target, dist,
+// __pycache__), and their content, at any depth.
+var IgnoreBuildArtifacts Option = ignoreNames{"node_modules", "target", "dist", "__pycache__"}
+
+// The Prune option is like Ignore, but also prevents the walk from descending
+// into directories matching the pattern, so that their content is never visited.
+// This can save a lot of time on big subtrees (e.g node_modules) since, with
+// Ignore, the content of an ignored directory is still walked, and listed unless
+// it's also ignored.
+//
+// Prune can be provided multiple times to prune multiple patterns. As with
+// Ignore, a pattern ending with a slash only matches directories.
+type Prune string
+
+func (p Prune) apply(cfg *config) error {
+	pat, err := newPattern(string(p), ignore)
+	if err != nil {
+		return fmt.Errorf("%w: Prune(%q): %v", ErrInvalidPattern, string(p), err)
+	}
+	pat.prune = true
+	cfg.globs = append(cfg.globs, pat)
 	return nil
 }
 
@@ -145,10 +380,347 @@ type Depth int
 
 func (d Depth) apply(cfg *config) error {
 	if d < 0 {
-		return fmt.Errorf("negative Depth is invalid")
+		return fmt.Errorf("%w: negative Depth", ErrInvalidOption)
 	}
 	cfg.depth = int(d)
 	return nil
 }
 
 const infiniteDepth Depth = 0
+
+// The HashLimit option limits checksum computation to the first n bytes of
+// each file. This gives a fast, approximate, fingerprint of trees containing
+// big files. Checksums of files longer than the limit are reported as
+// "crc~=XXXXXXXX" instead of "crc=XXXXXXXX". 0, the default, means there's no
+// limit.
+type HashLimit int64
+
+func (n HashLimit) apply(cfg *config) error {
+	if n < 0 {
+		return fmt.Errorf("%w: negative HashLimit", ErrInvalidOption)
+	}
+	cfg.hashLimit = int64(n)
+	return nil
+}
+
+// The DetectChanges option, when true, stats again each regular file after its
+// checksums have been computed and flags those which size or modification time
+// changed in the meantime, with a "(changed during scan)" annotation printed
+// after the path. The checksums of such files may not match any version of
+// their content, this helps telling which lines of a listing of a live
+// directory may be inconsistent. It only has effect with ModeCRC32 or Hash.
+type DetectChanges bool
+
+func (dc DetectChanges) apply(cfg *config) error {
+	cfg.detectChanges = bool(dc)
+	return nil
+}
+
+// The IdleIO option, when true, reads files to compute their checksums with the
+// idle I/O priority on Linux, see ioprio_set(2): they're only read when no
+// other process needs the disk, so that scheduled scans yield to interactive
+// workloads. This requires an I/O scheduler supporting priorities, such as BFQ.
+// IdleIO has no effect on other systems.
+type IdleIO bool
+
+func (ii IdleIO) apply(cfg *config) error {
+	cfg.idleIO = bool(ii)
+	return nil
+}
+
+// The BufferSize option sets the size of the buffer used by Write and WriteFS
+// to write the listing. A larger buffer reduces the number of writes to the
+// underlying io.Writer, which can speed up writing very large listings to
+// network connections, pipes, etc.
+type BufferSize int
+
+func (n BufferSize) apply(cfg *config) error {
+	if n <= 0 {
+		return fmt.Errorf("%w: BufferSize must be positive", ErrInvalidOption)
+	}
+	cfg.bufSize = int(n)
+	return nil
+}
+
+const defaultBufSize = 4096
+
+// The OneFileSystem option, when true, prevents the walk from descending into
+// directories residing on another filesystem than root, like find -xdev does.
+// Mount points themselves are still listed. This is based on device numbers, so
+// it only has effect on Unix systems.
+type OneFileSystem bool
+
+func (ofs OneFileSystem) apply(cfg *config) error {
+	cfg.oneFS = bool(ofs)
+	return nil
+}
+
+// The FollowSymlinks option, when true, follows symbolic links: links to
+// directories are walked as directories and, as all followed links, listed with
+// the information of their target. Links which target doesn't exist are listed
+// as symbolic links. Links to a directory being walked, which would make the
+// walk loop forever, are not followed either, they're reported in SymlinkLoops.
+//
+// When walking a fs.FS, it must support symbolic links like fs.ReadLinkFS does
+// (Go 1.25), otherwise FollowSymlinks has no effect.
+type FollowSymlinks bool
+
+func (fl FollowSymlinks) apply(cfg *config) error {
+	cfg.follow = bool(fl)
+	return nil
+}
+
+// SymlinkLoops lists the symbolic links which haven't been followed, with
+// FollowSymlinks, since they point to one of their parent directories.
+//
+// A *SymlinkLoops is an Option: when provided, it gets reset at the beginning of
+// the walk and then filled with the paths of the links, relative to root and
+// slash-separated, as the walk proceeds.
+type SymlinkLoops []string
+
+func (sl *SymlinkLoops) apply(cfg *config) error {
+	cfg.loops = sl
+	return nil
+}
+
+// The MaxLinkChain option, with FollowSymlinks, doesn't follow the symbolic
+// links which chain, a link pointing to another link and so on, is made of
+// more than n links. They're listed as symbolic links, with a "(link chain too
+// long)" annotation printed after the path. 0, the default, means there's no
+// limit, other than the one of the operating system.
+//
+// The length of the chain of the followed links, and the path they resolve to,
+// are reported in Entry.LinkChain and Entry.Resolved.
+type MaxLinkChain int
+
+func (n MaxLinkChain) apply(cfg *config) error {
+	if n < 0 {
+		return fmt.Errorf("%w: negative MaxLinkChain", ErrInvalidOption)
+	}
+	cfg.maxChain = int(n)
+	return nil
+}
+
+// The Jobs option sets the number of files which checksums are computed
+// concurrently, which can speed up listings with ModeCRC32 or Hash on fast
+// storage, or on network filesystems with high latency. The walk itself stays
+// sequential, and entries are still listed in order. The default is 1, i.e.
+// checksums are computed one file at a time.
+type Jobs int
+
+func (n Jobs) apply(cfg *config) error {
+	if n < 0 {
+		return fmt.Errorf("%w: negative Jobs", ErrInvalidOption)
+	}
+	cfg.jobs = int(n)
+	return nil
+}
+
+// The PoolEntries option, when true, makes List, ListFS, ListAppend and
+// ListAppendFS allocate entries from a pool, filled by Entries.Release. Programs
+// listing trees at a high frequency can release the entries of a listing once
+// done with them, so that the next listings reuse them and the pressure on the
+// garbage collector stays flat:
+//
+//	for range ticker.C {
+//		entries, err := dirtree.List("dir", dirtree.PoolEntries(true))
+//		// ...
+//		dirtree.Entries(entries).Release()
+//	}
+type PoolEntries bool
+
+func (pe PoolEntries) apply(cfg *config) error {
+	cfg.pool = bool(pe)
+	return nil
+}
+
+// The PathLimit option flags the entries which relative path is longer than n
+// characters, with a "(path too long)" annotation printed after the path. This
+// helps catching trees which can't be extracted on some systems, for example
+// Windows limits paths to 260 characters by default. 0, the default, means
+// there's no limit.
+//
+// The length of the longest path is reported in Stats.MaxPathLen.
+type PathLimit int
+
+func (n PathLimit) apply(cfg *config) error {
+	if n < 0 {
+		return fmt.Errorf("%w: negative PathLimit", ErrInvalidOption)
+	}
+	cfg.pathLimit = int(n)
+	return nil
+}
+
+// The SizeBudget option makes the walk fail, with an error wrapping
+// ErrOverBudget, as soon as the total size of the listed regular files exceeds
+// n bytes, for example to check that a directory of artifacts stays under
+// 50MiB. Sizes are gathered even without ModeSize. 0, the default, means
+// there's no budget.
+//
+// To report the total size without failing, use Stats.Bytes.
+type SizeBudget int64
+
+func (n SizeBudget) apply(cfg *config) error {
+	if n < 0 {
+		return fmt.Errorf("%w: negative SizeBudget", ErrInvalidOption)
+	}
+	cfg.budget = int64(n)
+	return nil
+}
+
+// The Deadline option makes the walk fail, with an error wrapping
+// ErrDeadlineExceeded, as soon as it has lasted longer than d, for example to
+// bound the time spent by a scheduled scan without passing a context around.
+// The files listed so far have already been passed to the function given to
+// Walk, or written by Write. 0, the default, means there's no deadline.
+type Deadline time.Duration
+
+func (d Deadline) apply(cfg *config) error {
+	if d < 0 {
+		return fmt.Errorf("%w: negative Deadline", ErrInvalidOption)
+	}
+	cfg.deadline = time.Duration(d)
+	return nil
+}
+
+// The Align option, when true, sizes the ModeSize and ModeAlloc columns after
+// the largest size listed, rather than padding sizes to 9 digits, so that
+// listings of small files are more compact and listings of huge files stay
+// aligned. Since column widths are only known at the end of the walk, Write and
+// WriteFS don't print anything before the walk is over.
+type Align bool
+
+func (a Align) apply(cfg *config) error {
+	cfg.format.align = bool(a)
+	return nil
+}
+
+// The Compact option, when true, doesn't pad fields to align them: successive
+// fields are separated by exactly one space. Fields which don't apply to a file,
+// like the size of a directory, are left empty rather than omitted, so that all
+// lines have the same number of fields. Compact takes precedence over Align.
+type Compact bool
+
+func (c Compact) apply(cfg *config) error {
+	cfg.format.compact = bool(c)
+	return nil
+}
+
+// The Separator option sets the string separating fields, and the last field
+// from the path, instead of a space. Fields are then not padded, as with
+// Compact. Separator("\t") makes listings easy to process with tools like awk
+// or cut:
+//
+//	dirtree.Write(os.Stdout, "dir", dirtree.ModeAll, dirtree.Separator("\t"))
+//
+// So that all lines have the same number of fields, paths and link targets are
+// escaped: backslashes, newlines, carriage returns and tabs are printed as \\,
+// \n, \r and \t, and other occurrences of the separator are preceded by a
+// backslash. A file named "a<TAB>b" is printed as a\tb for example.
+type Separator string
+
+func (s Separator) apply(cfg *config) error {
+	if strings.ContainsAny(string(s), "\n\r") {
+		return fmt.Errorf("%w: Separator: invalid separator %q", ErrInvalidOption, string(s))
+	}
+	cfg.format.sep = string(s)
+	return nil
+}
+
+// The Header option, when true, makes Write and WriteFS print a first line
+// naming the columns in effect, separated as fields are, after a "#dirtree/1"
+// marker giving the version of the format. The FollowSymlinks and
+// RespectGitIgnore options, which change the files listed, follow when set.
+// For example, with ModeAll and FollowSymlinks(true):
+//
+//	#dirtree/1 type size crc32 path follow=true
+//
+// ParseHeader returns the options recorded in a header. The header is only
+// printed with the "line" format, the default one, not with templates nor
+// other formats.
+type Header bool
+
+func (h Header) apply(cfg *config) error {
+	cfg.header = bool(h)
+	return nil
+}
+
+// The NativeSeparators option, when true, prints paths with the separator of
+// the OS, that is with backslashes on Windows, for listings consumed by native
+// tools. By default, paths are printed with slashes on all systems, so that
+// listings are the same everywhere. Only printed lines are concerned: Entry
+// fields, like RelPath, are always slash-separated.
+type NativeSeparators bool
+
+func (ns NativeSeparators) apply(cfg *config) error {
+	cfg.format.pathSep = 0
+	if ns {
+		cfg.format.pathSep = filepath.Separator
+	}
+	return nil
+}
+
+// The Template option replaces the lines printed by Write, WriteFS, Sprint and
+// SprintFS with the result of the execution of a text/template on each Entry.
+// A newline is appended after each entry. For example:
+//
+//	dirtree.Template("{{.Type}} {{.Size}} {{.RelPath}}")
+//
+// The information available in the Entry still depends on the PrintMode,
+// ModeSize is required for Size to be set for example. Template and Encoding
+// override each other, the last one provided wins.
+type Template string
+
+func (t Template) apply(cfg *config) error {
+	tmpl, err := template.New("dirtree").Parse(string(t))
+	if err != nil {
+		return fmt.Errorf("%w: Template: %v", ErrInvalidOption, err)
+	}
+	cfg.encoding = func(w io.Writer) Encoder {
+		return NewTemplateEncoder(w, tmpl)
+	}
+	return nil
+}
+
+// The Normalize option sets a function applied to the relative path of each
+// file, before it's matched against patterns and listed. It's meant to be used
+// for Unicode normalization, with NFC or NFD, so that listings of the same tree
+// taken on different systems (e.g macOS uses NFD and Linux NFC) compare equal:
+//
+//	dirtree.List("dir", dirtree.Normalize(dirtree.NFC))
+//
+// Entry.Path is left untouched so that it can still be used to access the
+// file.
+type Normalize func(string) string
+
+func (fn Normalize) apply(cfg *config) error {
+	cfg.normalize = fn
+	return nil
+}
+
+// The LogFunc option sets a function that is called to log debug information
+// during the walk: skipped entries and the reason they were skipped, errors,
+// symbolic links not followed, etc. It has the signature of log.Printf so
+// that a *log.Logger Printf method can directly be used:
+//
+//	dirtree.List("dir", dirtree.LogFunc(logger.Printf))
+//
+// The function is never called concurrently by a walk, even when files are
+// hashed by several goroutines with Jobs. It may be called concurrently by
+// separate walks though, when given to several of them.
+type LogFunc func(format string, args ...interface{})
+
+func (fn LogFunc) apply(cfg *config) error {
+	cfg.logfn = fn
+	cfg.logmu = new(sync.Mutex)
+	return nil
+}
+
+// logf logs a message via the LogFunc option, if set.
+func (cfg *config) logf(format string, args ...interface{}) {
+	if cfg.logfn != nil {
+		cfg.logmu.Lock()
+		defer cfg.logmu.Unlock()
+		cfg.logfn(format, args...)
+	}
+}
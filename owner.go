@@ -0,0 +1,74 @@
+package dirtree
+
+import (
+	"os/user"
+	"strconv"
+)
+
+// The NumericOwners option, when true, makes ModeOwner report the numeric user
+// and group IDs of files, rather than resolving them to names.
+type NumericOwners bool
+
+func (no NumericOwners) apply(cfg *config) error {
+	cfg.numericOwners = bool(no)
+	return nil
+}
+
+// number of chars of the owner column, "owner=" excluded, shorter owners are
+// padded.
+const ownerChars = 17
+
+// ownerNames resolves user and group IDs to names, caching the results for the
+// duration of a walk so that each ID is only looked up once.
+type ownerNames struct {
+	users  map[uint32]string
+	groups map[uint32]string
+}
+
+// user returns the name of the user with the given ID, or the ID itself if it
+// can't be resolved.
+func (on *ownerNames) user(uid uint32) string {
+	if name, ok := on.users[uid]; ok {
+		return name
+	}
+	if on.users == nil {
+		on.users = make(map[uint32]string)
+	}
+	id := strconv.FormatUint(uint64(uid), 10)
+	name := id
+	if u, err := user.LookupId(id); err == nil {
+		name = u.Username
+	}
+	on.users[uid] = name
+	return name
+}
+
+// group returns the name of the group with the given ID, or the ID itself if
+// it can't be resolved.
+func (on *ownerNames) group(gid uint32) string {
+	if name, ok := on.groups[gid]; ok {
+		return name
+	}
+	if on.groups == nil {
+		on.groups = make(map[uint32]string)
+	}
+	id := strconv.FormatUint(uint64(gid), 10)
+	name := id
+	if g, err := user.LookupGroupId(id); err == nil {
+		name = g.Name
+	}
+	on.groups[gid] = name
+	return name
+}
+
+// setOwner sets the Owner and Group of ent from the uid and gid IDs, as
+// configured by cfg.
+func setOwner(ent *Entry, cfg *config, uid, gid uint32) {
+	if cfg.numericOwners {
+		ent.Owner = strconv.FormatUint(uint64(uid), 10)
+		ent.Group = strconv.FormatUint(uint64(gid), 10)
+		return
+	}
+	ent.Owner = cfg.owners.user(uid)
+	ent.Group = cfg.owners.group(gid)
+}
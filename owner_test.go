@@ -0,0 +1,67 @@
+package dirtree
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"testing/fstest"
+)
+
+func TestModeOwner(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "file")
+	if err := os.WriteFile(name, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Lstat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uid, gid, ok := ownerIDs(fi)
+	if !ok {
+		t.Skip("owners not available on this system")
+	}
+
+	wantOwner, wantGroup := strconv.Itoa(int(uid)), strconv.Itoa(int(gid))
+	ent, err := Stat(name, ModeOwner, NumericOwners(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ent.Owner != wantOwner || ent.Group != wantGroup {
+		t.Errorf("NumericOwners: owner = %s:%s, want %s:%s", ent.Owner, ent.Group, wantOwner, wantGroup)
+	}
+
+	if u, err := user.LookupId(wantOwner); err == nil {
+		wantOwner = u.Username
+	}
+	if g, err := user.LookupGroupId(wantGroup); err == nil {
+		wantGroup = g.Name
+	}
+	ents, err := List(dir, ModeType|ModeOwner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range ents {
+		if e.RelPath != "file" {
+			continue
+		}
+		if e.Owner != wantOwner || e.Group != wantGroup {
+			t.Errorf("owner = %s:%s, want %s:%s", e.Owner, e.Group, wantOwner, wantGroup)
+		}
+		want := "f owner=" + wantOwner + ":" + wantGroup
+		if got := e.Format(); len(got) < len(want) || got[:len(want)] != want {
+			t.Errorf("Format() = %q, want prefix %q", got, want)
+		}
+	}
+
+	// Owners of MapFS files are unknown.
+	got, err := SprintFS(fstest.MapFS{"file": {}}, ".", ModeType|ModeOwner, ExcludeRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "f owner=n/a               file\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
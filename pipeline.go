@@ -0,0 +1,113 @@
+package dirtree
+
+import (
+	"io/fs"
+	"path/filepath"
+	"time"
+)
+
+// hashPipeline computes the checksums of entries concurrently, with the Jobs
+// option, and passes them to fn in the order they've been added.
+type hashPipeline struct {
+	cfg  *config
+	fsys fs.FS
+	fn   func(*Entry) error
+
+	sem   chan struct{} // limits the number of concurrent jobs
+	queue []*hashJob    // entries waiting to be passed to fn, in order
+}
+
+type hashJob struct {
+	ent      Entry
+	fullpath string
+	done     chan struct{} // closed once the checksums are computed
+
+	n   int64
+	dur time.Duration
+	err error
+}
+
+func newHashPipeline(cfg *config, fsys fs.FS, fn func(*Entry) error) *hashPipeline {
+	return &hashPipeline{
+		cfg:  cfg,
+		fsys: fsys,
+		fn:   fn,
+		sem:  make(chan struct{}, cfg.jobs),
+	}
+}
+
+// add adds a copy of ent to the pipeline, and passes the oldest entries to fn,
+// if they're ready or if there are too many waiting.
+func (p *hashPipeline) add(ent *Entry) error {
+	j := &hashJob{ent: *ent, done: make(chan struct{})}
+	j.fullpath = ent.Path
+	if p.fsys == nil {
+		j.fullpath = filepath.FromSlash(ent.Path)
+	}
+
+	if ent.Type != File {
+		hashEntry(&j.ent, p.cfg, p.fsys, j.fullpath)
+		close(j.done)
+	} else {
+		p.sem <- struct{}{}
+		go func() {
+			start := time.Now()
+			j.n, j.err = hashEntry(&j.ent, p.cfg, p.fsys, j.fullpath)
+			j.dur = time.Since(start)
+			<-p.sem
+			close(j.done)
+		}()
+	}
+	p.queue = append(p.queue, j)
+
+	// Keep enough entries in the queue for all the jobs to be busy.
+	for len(p.queue) > 0 {
+		select {
+		case <-p.queue[0].done:
+		default:
+			if len(p.queue) <= 4*p.cfg.jobs {
+				return nil
+			}
+		}
+		if err := p.pop(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pop waits for the oldest entry to be ready and passes it to fn.
+func (p *hashPipeline) pop() error {
+	j := p.queue[0]
+	p.queue[0] = nil
+	p.queue = p.queue[1:]
+
+	<-j.done
+	if j.err != nil {
+		p.cfg.logf("%s: can't compute checksum: %v", j.fullpath, j.err)
+	}
+	if st := p.cfg.stats; st != nil {
+		st.HashTime += j.dur
+		st.BytesHashed += j.n
+	}
+	return p.fn(&j.ent)
+}
+
+// flush passes all remaining entries to fn.
+func (p *hashPipeline) flush() error {
+	for len(p.queue) > 0 {
+		if err := p.pop(); err != nil {
+			p.wait()
+			return err
+		}
+	}
+	return nil
+}
+
+// wait waits for all running jobs to finish.
+func (p *hashPipeline) wait() {
+	for _, j := range p.queue {
+		<-j.done
+	}
+	p.queue = nil
+}
@@ -0,0 +1,150 @@
+package dirtree
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+)
+
+// errFound stops a walk once the searched file has been found.
+var errFound = errors.New("found")
+
+// Find walks the directory rooted at root, as List, and returns the first file
+// listed which relative path matches pattern, or nil if there's none. The walk
+// stops as soon as it's found, which is far cheaper than listing the whole
+// tree when only the existence of a file, or a single path, is needed.
+//
+// pattern has the syntax of the Match option, with which it's combined: if
+// other Match options are provided, the first file matching any of the
+// patterns is returned.
+//
+//	e, err := dirtree.Find("dir", "*/go.mod", dirtree.Type("f"))
+func Find(root, pattern string, opts ...Option) (*Entry, error) {
+	return FindFS(nil, root, pattern, opts...)
+}
+
+// FindFS is like Find, for the directory rooted at root in the given
+// filesystem.
+func FindFS(fsys fs.FS, root, pattern string, opts ...Option) (*Entry, error) {
+	cfg, err := newConfig(append(opts[:len(opts):len(opts)], Match(pattern)))
+	if err != nil {
+		return nil, fmt.Errorf("dirtree: %w", err)
+	}
+
+	var found *Entry
+	err = walkTree(root, fsys, &cfg, func(ent *Entry) error {
+		found = new(Entry)
+		*found = *ent
+		return errFound
+	})
+	if err != nil && err != errFound {
+		return nil, fmt.Errorf("dirtree: %w", err)
+	}
+	return found, nil
+}
+
+// Any walks the directory rooted at root, as List, and reports whether any
+// file below root is listed, the walk stopping as soon as one is found. It's
+// meant for quick checks, whether there's any Go file in a directory for
+// example:
+//
+//	found, err := dirtree.Any("dir", dirtree.Match("*.go"), dirtree.MatchBase(true))
+//
+// The root directory itself is never taken into account. No information is
+// gathered about the files, whatever the PrintMode.
+func Any(root string, opts ...Option) (bool, error) {
+	return AnyFS(nil, root, opts...)
+}
+
+// AnyFS is like Any, for the directory rooted at root in the given filesystem.
+func AnyFS(fsys fs.FS, root string, opts ...Option) (bool, error) {
+	cfg, err := newQueryConfig(opts)
+	if err != nil {
+		return false, fmt.Errorf("dirtree: %w", err)
+	}
+
+	err = walkTree(root, fsys, &cfg, func(*Entry) error {
+		return errFound
+	})
+	if err != nil && err != errFound {
+		return false, fmt.Errorf("dirtree: %w", err)
+	}
+	return err == errFound, nil
+}
+
+// Count walks the directory rooted at root, as List, and returns the number of
+// files listed below root, the root directory itself not being counted. No
+// entry is allocated and no information is gathered about the files, whatever
+// the PrintMode, which makes it cheap to check the size of giant trees:
+//
+//	n, err := dirtree.Count("dir", dirtree.Type("f"))
+func Count(root string, opts ...Option) (int, error) {
+	return CountFS(nil, root, opts...)
+}
+
+// CountFS is like Count, for the directory rooted at root in the given
+// filesystem.
+func CountFS(fsys fs.FS, root string, opts ...Option) (int, error) {
+	cfg, err := newQueryConfig(opts)
+	if err != nil {
+		return 0, fmt.Errorf("dirtree: %w", err)
+	}
+
+	n := 0
+	err = walkTree(root, fsys, &cfg, func(*Entry) error {
+		n++
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("dirtree: %w", err)
+	}
+	return n, nil
+}
+
+// TotalSize walks the directory rooted at root, as List, and returns the sum
+// of the sizes of the regular files listed below root. As with Count, no entry
+// is allocated and no information other than file sizes is gathered, whatever
+// the PrintMode:
+//
+//	n, err := dirtree.TotalSize("dir", dirtree.Match("*.log"), dirtree.MatchBase(true))
+//
+// Directories and symbolic links are filtered as usual but don't add to the
+// total.
+func TotalSize(root string, opts ...Option) (int64, error) {
+	return TotalSizeFS(nil, root, opts...)
+}
+
+// TotalSizeFS is like TotalSize, for the directory rooted at root in the given
+// filesystem.
+func TotalSizeFS(fsys fs.FS, root string, opts ...Option) (int64, error) {
+	cfg, err := newQueryConfig(opts)
+	if err != nil {
+		return 0, fmt.Errorf("dirtree: %w", err)
+	}
+	cfg.mode = ModeSize
+
+	var total int64
+	err = walkTree(root, fsys, &cfg, func(ent *Entry) error {
+		if ent.Type == File {
+			total += ent.Size
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("dirtree: %w", err)
+	}
+	return total, nil
+}
+
+// newQueryConfig returns the config built from opts, for queries only
+// interested in which files are listed below the root.
+func newQueryConfig(opts []Option) (config, error) {
+	cfg, err := newConfig(append(opts[:len(opts):len(opts)], ExcludeRoot))
+	if err != nil {
+		return cfg, err
+	}
+	cfg.mode = 0
+	cfg.format.hashes = nil
+	cfg.format.markBroken = false
+	return cfg, nil
+}
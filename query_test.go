@@ -0,0 +1,145 @@
+package dirtree
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+)
+
+var queryFS = fstest.MapFS{
+	"A/file1.go":   &fstest.MapFile{Data: []byte("dummy")},
+	"A/B/file2.go": &fstest.MapFile{Data: []byte("content")},
+	"C/file3.txt":  &fstest.MapFile{Data: []byte("other")},
+	"C/D/file4.go": &fstest.MapFile{Data: []byte("dummy")},
+}
+
+func TestFind(t *testing.T) {
+	var st Stats
+	e, err := FindFS(queryFS, ".", "A/*.go", ModeSize, &st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e == nil || e.RelPath != "A/file1.go" || e.Size != 5 {
+		t.Errorf("Find(A/*.go) = %v, want A/file1.go of 5 bytes", e)
+	}
+	if st.Visited != 5 {
+		t.Errorf("Find(A/*.go) visited %d files, want 5", st.Visited)
+	}
+
+	if e, err := FindFS(queryFS, ".", "*.go", MatchBase(true), Jobs(2), ModeCRC32); err != nil || e == nil || e.RelPath != "A/B/file2.go" {
+		t.Errorf("Find(*.go) = (%v, %v), want A/B/file2.go", e, err)
+	}
+	if e, err := FindFS(queryFS, ".", "*.go", Ignore("*/*.go")); err != nil || e != nil {
+		t.Errorf("Find(*.go) = (%v, %v), want (nil, nil)", e, err)
+	}
+	if _, err := FindFS(queryFS, ".", "[a"); !errors.Is(err, ErrInvalidPattern) {
+		t.Errorf("Find([a) error = %v, want ErrInvalidPattern", err)
+	}
+}
+
+func TestAny(t *testing.T) {
+	tests := []struct {
+		opts []Option
+		want bool
+	}{
+		{nil, true},
+		{[]Option{Match("*.go"), MatchBase(true)}, true},
+		{[]Option{Match("*.txt"), MatchBase(true), ModeAll}, true},
+		{[]Option{Match("*.md"), MatchBase(true)}, false},
+		{[]Option{Type("l")}, false},
+		{[]Option{Depth(1), Type("f")}, false},
+	}
+	for _, tt := range tests {
+		got, err := AnyFS(queryFS, ".", tt.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("Any(%v) = %t, want %t", tt.opts, got, tt.want)
+		}
+	}
+
+	var st Stats
+	if _, err := AnyFS(queryFS, ".", Type("f"), &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.Visited != 4 || st.BytesHashed != 0 {
+		t.Errorf("Any(Type(f)) visited %d files and hashed %d bytes, want 4 and 0", st.Visited, st.BytesHashed)
+	}
+
+	if _, err := AnyFS(queryFS, ".", Type("z")); !errors.Is(err, ErrInvalidType) {
+		t.Errorf("Any(Type(z)) error = %v, want ErrInvalidType", err)
+	}
+}
+
+func TestCount(t *testing.T) {
+	tests := []struct {
+		opts []Option
+		want int
+	}{
+		{nil, 8},
+		{[]Option{Type("f"), ModeAll, Hash("sha256")}, 4},
+		{[]Option{Type("d")}, 4},
+		{[]Option{Match("*.go"), MatchBase(true)}, 3},
+		{[]Option{Depth(1)}, 2},
+	}
+	for _, tt := range tests {
+		var st Stats
+		got, err := CountFS(queryFS, ".", append(tt.opts, &st)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("Count(%v) = %d, want %d", tt.opts, got, tt.want)
+		}
+		if st.BytesHashed != 0 {
+			t.Errorf("Count(%v) hashed %d bytes", tt.opts, st.BytesHashed)
+		}
+	}
+
+	allocs := testing.AllocsPerRun(10, func() {
+		CountFS(queryFS, ".", Type("f"), ModeAll)
+	})
+	withEntries := testing.AllocsPerRun(10, func() {
+		ListFS(queryFS, ".", Type("f"), ModeAll)
+	})
+	if allocs >= withEntries {
+		t.Errorf("Count made %v allocations, not less than List (%v)", allocs, withEntries)
+	}
+}
+
+func TestTotalSize(t *testing.T) {
+	tests := []struct {
+		opts []Option
+		want int64
+	}{
+		{nil, 22},
+		{[]Option{Type("f"), ModeAll, Hash("sha256")}, 22},
+		{[]Option{Type("d")}, 0},
+		{[]Option{Match("*.go"), MatchBase(true)}, 17},
+		{[]Option{Depth(2)}, 10},
+	}
+	for _, tt := range tests {
+		var st Stats
+		got, err := TotalSizeFS(queryFS, ".", append(tt.opts, &st)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("TotalSize(%v) = %d, want %d", tt.opts, got, tt.want)
+		}
+		if st.BytesHashed != 0 {
+			t.Errorf("TotalSize(%v) hashed %d bytes", tt.opts, st.BytesHashed)
+		}
+	}
+
+	allocs := testing.AllocsPerRun(10, func() {
+		TotalSizeFS(queryFS, ".", Type("f"), ModeAll)
+	})
+	withEntries := testing.AllocsPerRun(10, func() {
+		ListFS(queryFS, ".", Type("f"), ModeAll)
+	})
+	if allocs >= withEntries {
+		t.Errorf("TotalSize made %v allocations, not less than List (%v)", allocs, withEntries)
+	}
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+// +build !windows
+
+package dirtree
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// isReparsePoint reports whether dirent is a reparse point behaving like a
+// symbolic link. Reparse points only exist on Windows.
+func isReparsePoint(dirent fs.DirEntry) bool { return false }
+
+// readOSLink returns the target of the symbolic link at fullpath.
+func readOSLink(fullpath string) (string, error) { return os.Readlink(fullpath) }
+
+// evalOSLink returns the path of the file the symbolic link at fullpath, which
+// target is target, eventually points to.
+func evalOSLink(fullpath, target string) (string, error) {
+	return filepath.EvalSymlinks(fullpath)
+}
@@ -0,0 +1,151 @@
+package dirtree
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unicode/utf16"
+)
+
+// isReparsePoint reports whether dirent is a reparse point behaving like a
+// symbolic link, a directory junction for example. Such reparse points are
+// reported as directories or irregular files by the os package, depending on
+// the Go version.
+func isReparsePoint(dirent fs.DirEntry) bool {
+	if dirent.Type().IsRegular() {
+		// For example deduplicated files, which are regular files for all
+		// intents and purposes.
+		return false
+	}
+	fi, err := dirent.Info()
+	if err != nil {
+		return false
+	}
+	data, ok := fi.Sys().(*syscall.Win32FileAttributeData)
+	return ok && data.FileAttributes&syscall.FILE_ATTRIBUTE_REPARSE_POINT != 0
+}
+
+const (
+	ioReparseTagMountPoint = 0xA0000003 // directory junctions and volume mount points
+	symlinkFlagRelative    = 1
+)
+
+// readOSLink returns the target of the symbolic link, or of the reparse point
+// behaving like one, at fullpath. The target is read from the reparse point
+// data, rather than with os.Readlink, which fails on volume mount points.
+func readOSLink(fullpath string) (string, error) {
+	p, err := syscall.UTF16PtrFromString(fullpath)
+	if err != nil {
+		return "", err
+	}
+	h, err := syscall.CreateFile(p, 0, syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
+		nil, syscall.OPEN_EXISTING, syscall.FILE_FLAG_OPEN_REPARSE_POINT|syscall.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	if err != nil {
+		return "", &fs.PathError{Op: "readlink", Path: fullpath, Err: err}
+	}
+	defer syscall.CloseHandle(h)
+
+	buf := make([]byte, syscall.MAXIMUM_REPARSE_DATA_BUFFER_SIZE)
+	var n uint32
+	err = syscall.DeviceIoControl(h, syscall.FSCTL_GET_REPARSE_POINT, nil, 0, &buf[0], uint32(len(buf)), &n, nil)
+	if err != nil {
+		return "", &fs.PathError{Op: "readlink", Path: fullpath, Err: err}
+	}
+	target, err := parseReparseData(buf[:n])
+	if err != nil {
+		return "", &fs.PathError{Op: "readlink", Path: fullpath, Err: err}
+	}
+	return target, nil
+}
+
+// parseReparseData returns the target stored in the REPARSE_DATA_BUFFER b, of
+// a symbolic link or a mount point. Absolute targets are returned as Win32
+// paths, C:\dir or \\server\share\dir for example, as os.Readlink does.
+func parseReparseData(b []byte) (string, error) {
+	if len(b) < 8 {
+		return "", errors.New("short reparse data")
+	}
+	tag := binary.LittleEndian.Uint32(b)
+	b = b[8:]
+
+	var hdr int // size of the header preceding the path buffer
+	switch tag {
+	case syscall.IO_REPARSE_TAG_SYMLINK:
+		hdr = 12
+	case ioReparseTagMountPoint:
+		hdr = 8
+	default:
+		return "", fmt.Errorf("unsupported reparse point tag %#x", tag)
+	}
+	if len(b) < hdr {
+		return "", errors.New("short reparse data")
+	}
+	subOff := int(binary.LittleEndian.Uint16(b[0:]))
+	subLen := int(binary.LittleEndian.Uint16(b[2:]))
+	printOff := int(binary.LittleEndian.Uint16(b[4:]))
+	printLen := int(binary.LittleEndian.Uint16(b[6:]))
+	relative := tag == syscall.IO_REPARSE_TAG_SYMLINK && binary.LittleEndian.Uint32(b[8:])&symlinkFlagRelative != 0
+	paths := b[hdr:]
+
+	name := func(off, n int) (string, error) {
+		if off+n > len(paths) || n%2 != 0 {
+			return "", errors.New("invalid reparse data")
+		}
+		u := make([]uint16, n/2)
+		for i := range u {
+			u[i] = binary.LittleEndian.Uint16(paths[off+2*i:])
+		}
+		return string(utf16.Decode(u)), nil
+	}
+
+	// The print name is the target as the user gave it, but it's optional.
+	// The substitute name is an NT path for absolute targets.
+	if printLen != 0 {
+		return name(printOff, printLen)
+	}
+	target, err := name(subOff, subLen)
+	if err != nil || relative {
+		return target, err
+	}
+	return ntToWin32Path(target), nil
+}
+
+// ntToWin32Path converts the NT path p, \??\C:\dir for example, to a Win32
+// path.
+func ntToWin32Path(p string) string {
+	if !strings.HasPrefix(p, `\??\`) {
+		return p
+	}
+	p = p[4:]
+	switch {
+	case strings.HasPrefix(p, `UNC\`):
+		return `\` + p[3:]
+	case len(p) >= 2 && p[1] == ':':
+		return p
+	}
+	// A volume GUID path, Volume{...}\, or any other device path.
+	return `\\?\` + p
+}
+
+// evalOSLink returns the path of the file the symbolic link at fullpath, which
+// target is target, eventually points to. filepath.EvalSymlinks doesn't follow
+// all reparse points, so target is used as is when it fails, as long as it
+// exists.
+func evalOSLink(fullpath, target string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(fullpath)
+	if err == nil {
+		return resolved, nil
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(fullpath), target)
+	}
+	if _, serr := os.Stat(target); serr != nil {
+		return "", err
+	}
+	return target, nil
+}
@@ -0,0 +1,108 @@
+package dirtree
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"unicode/utf16"
+)
+
+func TestJunction(t *testing.T) {
+	dir := t.TempDir()
+	mkdirs(t, filepath.Join(dir, "target"))
+	touch(t, filepath.Join(dir, "target", "file"))
+
+	out, err := exec.Command("cmd", "/c", "mklink", "/J", filepath.Join(dir, "junction"), filepath.Join(dir, "target")).CombinedOutput()
+	if err != nil {
+		t.Skipf("can't create junction: %v\n%s", err, out)
+	}
+
+	got, err := Sprint(dir, ModeType, ExcludeRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "l junction\nd target\nf target/file"
+	if got = strings.TrimSpace(got); got != want {
+		t.Errorf("got:\n%s\n\nwant:\n%s", got, want)
+	}
+
+	target, err := os.Readlink(filepath.Join(dir, "junction"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := List(filepath.Join(dir, "junction"), ModeLink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list[0].LinkTarget != target {
+		t.Errorf("LinkTarget = %q, want %q", list[0].LinkTarget, target)
+	}
+}
+
+func le16(b []byte, v uint16) []byte { return append(b, byte(v), byte(v>>8)) }
+func le32(b []byte, v uint32) []byte { return le16(le16(b, uint16(v)), uint16(v>>16)) }
+
+// reparseData returns a REPARSE_DATA_BUFFER for a reparse point of the given
+// tag, substitute and print names.
+func reparseData(tag uint32, flags uint32, sub, print string) []byte {
+	enc := func(s string) []byte {
+		b := make([]byte, 0, 2*len(s))
+		for _, u := range utf16.Encode([]rune(s)) {
+			b = le16(b, u)
+		}
+		return b
+	}
+	bsub, bprint := enc(sub), enc(print)
+
+	var hdr []byte
+	hdr = le16(hdr, 0)
+	hdr = le16(hdr, uint16(len(bsub)))
+	hdr = le16(hdr, uint16(len(bsub)))
+	hdr = le16(hdr, uint16(len(bprint)))
+	if tag == syscall.IO_REPARSE_TAG_SYMLINK {
+		hdr = le32(hdr, flags)
+	}
+	data := append(append(hdr, bsub...), bprint...)
+
+	var b []byte
+	b = le32(b, tag)
+	b = le16(b, uint16(len(data)))
+	b = le16(b, 0)
+	return append(b, data...)
+}
+
+func TestParseReparseData(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"symlink", reparseData(syscall.IO_REPARSE_TAG_SYMLINK, 0, `\??\C:\dir\file`, `C:\dir\file`), `C:\dir\file`},
+		{"relative symlink", reparseData(syscall.IO_REPARSE_TAG_SYMLINK, symlinkFlagRelative, `..\file`, ""), `..\file`},
+		{"symlink without print name", reparseData(syscall.IO_REPARSE_TAG_SYMLINK, 0, `\??\C:\dir`, ""), `C:\dir`},
+		{"unc symlink", reparseData(syscall.IO_REPARSE_TAG_SYMLINK, 0, `\??\UNC\server\share`, ""), `\\server\share`},
+		{"junction", reparseData(ioReparseTagMountPoint, 0, `\??\C:\target`, `C:\target`), `C:\target`},
+		{"volume mount point", reparseData(ioReparseTagMountPoint, 0, `\??\Volume{1234}\`, ""), `\\?\Volume{1234}\`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseReparseData(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("parseReparseData() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := parseReparseData(reparseData(0x80000023, 0, "x", "")); err == nil {
+		t.Error("parseReparseData() succeeded for an unsupported tag")
+	}
+	if _, err := parseReparseData([]byte{1, 2, 3}); err == nil {
+		t.Error("parseReparseData() succeeded for short data")
+	}
+}
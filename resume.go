@@ -0,0 +1,138 @@
+package dirtree
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+)
+
+// A Cursor records the position of a walk, so that it can be resumed later,
+// possibly by another process. It's the RelPath of the last file listed, the
+// empty Cursor being the beginning of the walk.
+//
+// A Cursor is also an Option: the walk then only lists the files coming after
+// it, in walk order, without walking the directories which entirely come
+// before. Resuming a walk with other options than those it started with, or
+// another Collation especially, gives unspecified results.
+type Cursor string
+
+func (c Cursor) apply(cfg *config) error {
+	cfg.cursor = string(c)
+	return nil
+}
+
+// ListFrom lists the files of the directory rooted at root, as List, coming
+// after cursor, and returns the cursor to resume from. In case of error, the
+// files listed so far are returned with it, so that the walk can be resumed in
+// chunks, after a Deadline for example:
+//
+//	var cur dirtree.Cursor // loaded from a previous run
+//	for {
+//		entries, next, err := dirtree.ListFrom("dir", cur, dirtree.Deadline(time.Minute))
+//		process(entries)
+//		cur = next
+//		if !errors.Is(err, dirtree.ErrDeadlineExceeded) {
+//			break
+//		}
+//	}
+//
+// Options computing information from the whole listing, DirChecksums for
+// example, only take into account the files returned.
+func ListFrom(root string, cursor Cursor, opts ...Option) ([]*Entry, Cursor, error) {
+	return ListFromFS(nil, root, cursor, opts...)
+}
+
+// ListFromFS is like ListFrom, for the directory rooted at root in the given
+// filesystem.
+func ListFromFS(fsys fs.FS, root string, cursor Cursor, opts ...Option) ([]*Entry, Cursor, error) {
+	cfg, err := newConfig(append(opts[:len(opts):len(opts)], cursor))
+	if err != nil {
+		return nil, cursor, fmt.Errorf("dirtree: %w", err)
+	}
+
+	entries, err := listTree(nil, root, fsys, &cfg)
+	if len(entries) != 0 {
+		cursor = Cursor(entries[len(entries)-1].RelPath)
+	}
+	if err != nil {
+		return entries, cursor, fmt.Errorf("dirtree: %w", err)
+	}
+	return entries, cursor, nil
+}
+
+// errPageFull stops a walk once a page of entries has been listed.
+var errPageFull = errors.New("page full")
+
+// ListPage lists a page of at most pageSize files of the directory rooted at
+// root, as List, coming after cursor. It returns the cursor of the next page,
+// which is empty if there are no more files to list. The walk stops as soon as
+// the page is full, so that user interfaces can browse huge directories
+// without listing them entirely:
+//
+//	entries, next, err := dirtree.ListPage("dir", 100, dirtree.Cursor(r.FormValue("cursor")))
+//
+// As with ListFrom, options computing information from the whole listing only
+// take into account the files of the page.
+func ListPage(root string, pageSize int, cursor Cursor, opts ...Option) ([]*Entry, Cursor, error) {
+	return ListPageFS(nil, root, pageSize, cursor, opts...)
+}
+
+// ListPageFS is like ListPage, for the directory rooted at root in the given
+// filesystem.
+func ListPageFS(fsys fs.FS, root string, pageSize int, cursor Cursor, opts ...Option) ([]*Entry, Cursor, error) {
+	if pageSize <= 0 {
+		return nil, "", fmt.Errorf("dirtree: %w: page size must be positive", ErrInvalidOption)
+	}
+	cfg, err := newConfig(append(opts[:len(opts):len(opts)], cursor))
+	if err != nil {
+		return nil, "", fmt.Errorf("dirtree: %w", err)
+	}
+	cfg.limit = pageSize
+
+	entries, err := listTree(nil, root, fsys, &cfg)
+	switch {
+	case err == errPageFull:
+		return entries, Cursor(entries[len(entries)-1].RelPath), nil
+	case err != nil:
+		return nil, "", fmt.Errorf("dirtree: %w", err)
+	}
+	return entries, "", nil
+}
+
+// Position of a file relative to a cursor, in walk order.
+const (
+	beforeCursor = iota - 1 // the file, and its content, come before the cursor
+	atCursor                // the file is the cursor, or one of its parents
+	afterCursor             // the file comes after the cursor
+)
+
+// cursorPos returns the position of the file at rel, relative to the cursor
+// cur, both slash-separated relative paths. Entries of a same directory are
+// walked in the order given by less.
+func cursorPos(rel, cur string, less func(a, b string) bool) int {
+	if cur == "" {
+		return afterCursor
+	}
+	if rel == "." {
+		return atCursor
+	}
+	if cur == "." {
+		return afterCursor
+	}
+	for {
+		relName, relRest, relMore := cut(rel, "/")
+		curName, curRest, curMore := cut(cur, "/")
+		switch {
+		case relName != curName && less(relName, curName):
+			return beforeCursor
+		case relName != curName:
+			return afterCursor
+		case !relMore:
+			return atCursor
+		case !curMore:
+			// rel is in the directory at cur.
+			return afterCursor
+		}
+		rel, cur = relRest, curRest
+	}
+}
@@ -0,0 +1,121 @@
+package dirtree
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func relPaths(ents []*Entry) []string {
+	paths := []string{}
+	for _, e := range ents {
+		paths = append(paths, e.RelPath)
+	}
+	return paths
+}
+
+func TestListFrom(t *testing.T) {
+	fsys := fstest.MapFS{
+		"A/file1":     &fstest.MapFile{Data: []byte("dummy")},
+		"A/B/file2":   &fstest.MapFile{Data: []byte("content")},
+		"A/B/file10":  &fstest.MapFile{Data: []byte("content")},
+		"A/B-C/file3": &fstest.MapFile{Data: []byte("content")},
+		"D/file4":     &fstest.MapFile{Data: []byte("dummy")},
+		"file5":       &fstest.MapFile{Data: []byte("dummy")},
+	}
+
+	for _, coll := range []Collation{CollateBytes, CollateNatural} {
+		all, err := ListFS(fsys, ".", coll)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := relPaths(all)
+		for i := range want {
+			ents, next, err := ListFromFS(fsys, ".", Cursor(want[i]), coll)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := relPaths(ents); !reflect.DeepEqual(got, want[i+1:]) {
+				t.Errorf("collation %d, cursor %q: got %q, want %q", coll, want[i], got, want[i+1:])
+			}
+			if wantNext := Cursor(want[len(want)-1]); next != wantNext {
+				t.Errorf("collation %d, cursor %q: next cursor = %q, want %q", coll, want[i], next, wantNext)
+			}
+		}
+	}
+
+	// The file at the cursor may not exist anymore.
+	ents, _, err := ListFromFS(fsys, ".", "A/B/file3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := relPaths(ents), []string{"A/B-C", "A/B-C/file3", "A/file1", "D", "D/file4", "file5"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("cursor of a missing file: got %q, want %q", got, want)
+	}
+
+	// Resume after an error.
+	ents, next, err := ListFromFS(fsys, ".", "", Type("f"), SizeBudget(20))
+	if !errors.Is(err, ErrOverBudget) {
+		t.Fatalf("error = %v, want ErrOverBudget", err)
+	}
+	if got, want := relPaths(ents), []string{"A/B/file10", "A/B/file2"}; !reflect.DeepEqual(got, want) || next != "A/B/file2" {
+		t.Errorf("got (%q, next=%q), want (%q, next=%q)", got, next, want, "A/B/file2")
+	}
+	ents, _, err = ListFromFS(fsys, ".", next, Type("f"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := relPaths(ents), []string{"A/B-C/file3", "A/file1", "D/file4", "file5"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("resumed: got %q, want %q", got, want)
+	}
+}
+
+func TestListPage(t *testing.T) {
+	fsys := fstest.MapFS{
+		"A/file1":   &fstest.MapFile{Data: []byte("dummy")},
+		"A/B/file2": &fstest.MapFile{Data: []byte("content")},
+		"C/file3":   &fstest.MapFile{Data: []byte("content")},
+		"file4":     &fstest.MapFile{Data: []byte("dummy")},
+	}
+	all, err := ListFS(fsys, ".", ModeCRC32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := relPaths(all)
+
+	for _, size := range []int{1, 3, len(want), len(want) + 1} {
+		for _, jobs := range []int{1, 2} {
+			var (
+				got   []string
+				cur   Cursor
+				pages int
+			)
+			for {
+				ents, next, err := ListPageFS(fsys, ".", size, cur, ModeCRC32, Jobs(jobs))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(ents) > size {
+					t.Errorf("page size %d: got %d entries", size, len(ents))
+				}
+				got = append(got, relPaths(ents)...)
+				pages++
+				if next == "" {
+					break
+				}
+				cur = next
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("page size %d, Jobs(%d): got %q, want %q", size, jobs, got, want)
+			}
+			if wantPages := (len(want) + size - 1) / size; pages != wantPages {
+				t.Errorf("page size %d, Jobs(%d): got %d pages, want %d", size, jobs, pages, wantPages)
+			}
+		}
+	}
+
+	if _, _, err := ListPageFS(fsys, ".", 0, ""); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("page size 0: error = %v, want ErrInvalidOption", err)
+	}
+}
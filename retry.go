@@ -0,0 +1,55 @@
+package dirtree
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The Retry option retries the operations gathering information about a file,
+// getting its info and reading it to compute its checksums, when they fail
+// with a transient error, as network filesystems like NFS or SMB may return.
+// Once the retries are exhausted the error is handled as without Retry: the
+// walk fails if a file can't be stat'ed and checksums which can't be computed
+// are reported as n/a.
+//
+// Transient errors are EINTR, EAGAIN, EBUSY, ETIMEDOUT and ESTALE on Unix
+// systems, and errors reporting a timeout. The zero Retry doesn't retry.
+//
+//	dirtree.Write(os.Stdout, "/mnt/nfs", dirtree.Retry{Count: 3, Backoff: 100 * time.Millisecond})
+type Retry struct {
+	Count   int           // Count is the maximum number of retries of an operation
+	Backoff time.Duration // Backoff is the delay before the first retry, doubled for each following one
+}
+
+func (r Retry) apply(cfg *config) error {
+	if r.Count < 0 || r.Backoff < 0 {
+		return fmt.Errorf("%w: negative Retry", ErrInvalidOption)
+	}
+	cfg.retry = r
+	return nil
+}
+
+// withRetry calls op, operating on the file at fullpath, then calls it again
+// as long as it fails with a transient error, as set by the Retry option.
+func (cfg *config) withRetry(fullpath string, op func() error) error {
+	err := op()
+	delay := cfg.retry.Backoff
+	for i := 0; i < cfg.retry.Count && err != nil && isTransient(err); i++ {
+		cfg.logf("%s: %v, retrying in %v", fullpath, err, delay)
+		time.Sleep(delay)
+		delay *= 2
+		err = op()
+	}
+	return err
+}
+
+// isTransient reports whether err may not happen again if the failed operation
+// is retried.
+func isTransient(err error) bool {
+	var te interface{ Timeout() bool }
+	if errors.As(err, &te) && te.Timeout() {
+		return true
+	}
+	return isTransientErrno(err)
+}
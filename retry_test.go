@@ -0,0 +1,66 @@
+package dirtree
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"testing"
+	"testing/fstest"
+)
+
+// flakyFS is a fstest.MapFS failing to open files, with err, the first fails
+// times.
+type flakyFS struct {
+	fstest.MapFS
+	err   error
+	fails int
+	opens int
+}
+
+func (fsys *flakyFS) Open(name string) (fs.File, error) {
+	if name == "file" {
+		fsys.opens++
+		if fsys.opens <= fsys.fails {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fsys.err}
+		}
+	}
+	return fsys.MapFS.Open(name)
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name  string
+		err   error
+		retry Retry
+		want  string
+		opens int
+	}{
+		{"no retry", os.ErrDeadlineExceeded, Retry{}, "crc=n/a      file\n", 1},
+		{"recovered", os.ErrDeadlineExceeded, Retry{Count: 2}, "crc=4ff4f23f file\n", 3},
+		{"exhausted", os.ErrDeadlineExceeded, Retry{Count: 1}, "crc=n/a      file\n", 2},
+		{"not transient", fs.ErrPermission, Retry{Count: 2}, "crc=n/a      file\n", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := &flakyFS{
+				MapFS: fstest.MapFS{"file": &fstest.MapFile{Data: []byte("dummy")}},
+				err:   tt.err,
+				fails: 2,
+			}
+			got, err := SprintFS(fsys, ".", Type("f"), ModeCRC32, tt.retry)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if fsys.opens != tt.opens {
+				t.Errorf("file opened %d times, want %d", fsys.opens, tt.opens)
+			}
+		})
+	}
+
+	if _, err := ListFS(fstest.MapFS{}, ".", Retry{Count: -1}); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Retry{Count: -1} error = %v, want ErrInvalidOption", err)
+	}
+}
@@ -0,0 +1,84 @@
+package dirtree
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// The SizeUnit option sets the unit in which ModeSize and ModeAlloc report
+// sizes. Sizes are rounded up to the next whole unit, so that only empty files
+// are reported with a size of 0.
+type SizeUnit uint8
+
+const (
+	// UnitBytes reports sizes in bytes, "1234b" for example. This is the
+	// default.
+	UnitBytes SizeUnit = iota
+
+	// UnitKiB reports sizes in kibibytes (1024 bytes), "2KiB" for example.
+	UnitKiB
+
+	// UnitMiB reports sizes in mebibytes (1024 KiB), "1MiB" for example.
+	UnitMiB
+
+	// UnitBlocks reports sizes in 512-byte blocks, as du does with
+	// POSIXLY_CORRECT, "3blk" for example.
+	UnitBlocks
+)
+
+func (u SizeUnit) apply(cfg *config) error {
+	if u > UnitBlocks {
+		return fmt.Errorf("%w: unknown SizeUnit %d", ErrInvalidOption, u)
+	}
+	cfg.format.unit = u
+	return nil
+}
+
+// size returns the number of bytes in u.
+func (u SizeUnit) size() int64 {
+	switch u {
+	case UnitKiB:
+		return 1 << 10
+	case UnitMiB:
+		return 1 << 20
+	case UnitBlocks:
+		return 512
+	}
+	return 1
+}
+
+// suffix returns the suffix printed after sizes in u.
+func (u SizeUnit) suffix() string {
+	switch u {
+	case UnitKiB:
+		return "KiB"
+	case UnitMiB:
+		return "MiB"
+	case UnitBlocks:
+		return "blk"
+	}
+	return "b"
+}
+
+// The NoSizeSuffix option, when true, prints sizes without the suffix
+// indicating their unit, "1234" instead of "1234b" for example.
+type NoSizeSuffix bool
+
+func (ns NoSizeSuffix) apply(cfg *config) error {
+	cfg.format.noSuffix = bool(ns)
+	return nil
+}
+
+// appendSize appends the size, of a file of type ft, to b, padded to width.
+// Nothing but padding is appended for other types than regular files.
+func (f *formatting) appendSize(b []byte, ft FileType, size int64, width int) []byte {
+	start := len(b)
+	if ft == File {
+		unit := f.unit.size()
+		b = strconv.AppendInt(b, (size+unit-1)/unit, 10)
+		if !f.noSuffix {
+			b = append(b, f.unit.suffix()...)
+		}
+	}
+	return appendPad(b, start, width)
+}
@@ -0,0 +1,86 @@
+package dirtree
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"time"
+)
+
+// A Snapshot is a listing of a directory tree, along with the settings it has
+// been taken with and when. Snapshots can be saved, loaded back later and
+// compared, to track how a tree drifts over time.
+type Snapshot struct {
+	Root    string    // Root is the listed directory
+	Time    time.Time // Time is when the listing started
+	Config  Config    // Config holds the settings used for the listing
+	Entries []*Entry  // Entries is the listing
+}
+
+// TakeSnapshot lists the directory rooted at root in the given filesystem, or
+// in the OS filesystem if fsys is nil, with the settings of c.
+func TakeSnapshot(fsys fs.FS, root string, c Config) (*Snapshot, error) {
+	now := time.Now()
+	ents, err := c.ListFS(fsys, root)
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot{Root: root, Time: now, Config: c, Entries: ents}, nil
+}
+
+// snapshotEntry is the serialized form of an Entry.
+type snapshotEntry struct {
+	*Entry
+	Partial bool `json:",omitempty"`
+}
+
+type snapshotJSON struct {
+	Root    string
+	Time    time.Time
+	Config  Config
+	Entries []snapshotEntry
+}
+
+// Save writes s to w, in JSON.
+func (s *Snapshot) Save(w io.Writer) error {
+	sj := snapshotJSON{
+		Root:    s.Root,
+		Time:    s.Time,
+		Config:  s.Config,
+		Entries: make([]snapshotEntry, len(s.Entries)),
+	}
+	for i, e := range s.Entries {
+		sj.Entries[i] = snapshotEntry{Entry: e, Partial: e.partial}
+	}
+	if err := json.NewEncoder(w).Encode(&sj); err != nil {
+		return fmt.Errorf("dirtree: can't save snapshot: %w", err)
+	}
+	return nil
+}
+
+// Load replaces s with the snapshot previously written by Save to r.
+func (s *Snapshot) Load(r io.Reader) error {
+	var sj snapshotJSON
+	if err := json.NewDecoder(r).Decode(&sj); err != nil {
+		return fmt.Errorf("dirtree: can't load snapshot: %w", err)
+	}
+
+	ents := make([]*Entry, len(sj.Entries))
+	for i, se := range sj.Entries {
+		if se.Entry == nil {
+			return fmt.Errorf("dirtree: can't load snapshot: null entry")
+		}
+		se.Entry.mode = sj.Config.Mode
+		se.Entry.partial = se.Partial
+		ents[i] = se.Entry
+	}
+	*s = Snapshot{Root: sj.Root, Time: sj.Time, Config: sj.Config, Entries: ents}
+	return nil
+}
+
+// Diff returns the changes from s to the more recent snapshot cur. Both
+// snapshots should have been taken with the same Config.
+func (s *Snapshot) Diff(cur *Snapshot) Changeset {
+	return NewChangeset(s.Entries, cur.Entries)
+}
@@ -0,0 +1,67 @@
+package dirtree
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSnapshot(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a":      &fstest.MapFile{Data: []byte("a")},
+		"b/file": &fstest.MapFile{Data: []byte("longer than the limit")},
+	}
+
+	c := DefaultConfig()
+	c.Mode = ModeAll
+	c.HashLimit = 10
+	snap, err := TakeSnapshot(fsys, ".", c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := snap.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded := &Snapshot{}
+	if err := loaded.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if !loaded.Time.Equal(snap.Time) || loaded.Root != snap.Root || !reflect.DeepEqual(loaded.Config, snap.Config) {
+		t.Errorf("loaded snapshot header = (%v, %q, %+v), want (%v, %q, %+v)",
+			loaded.Time, loaded.Root, loaded.Config, snap.Time, snap.Root, snap.Config)
+	}
+	if len(loaded.Entries) != len(snap.Entries) {
+		t.Fatalf("loaded %d entries, want %d", len(loaded.Entries), len(snap.Entries))
+	}
+	for i := range snap.Entries {
+		if got, want := loaded.Entries[i].String(), snap.Entries[i].String(); got != want {
+			t.Errorf("entry %d = %q, want %q", i, got, want)
+		}
+	}
+	if cs := loaded.Diff(snap); !cs.Empty() {
+		t.Errorf("Diff() of loaded snapshot isn't empty:\n%s", cs)
+	}
+
+	fsys["a"] = &fstest.MapFile{Data: []byte("b")}
+	cur, err := TakeSnapshot(fsys, ".", c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "~ f 1b         crc=71beeff9 a\n"
+	if got := loaded.Diff(cur).String(); got != want {
+		t.Errorf("Diff() = %q, want %q", got, want)
+	}
+}
+
+func TestLoadError(t *testing.T) {
+	for _, s := range []string{"", "{", `{"Entries": [null]}`, `{"Config": {"mode": "color"}}`} {
+		var snap Snapshot
+		if err := snap.Load(bytes.NewBufferString(s)); err == nil {
+			t.Errorf("Load(%q) should return an error", s)
+		}
+	}
+}
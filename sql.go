@@ -0,0 +1,155 @@
+package dirtree
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sqlSchema creates the tables filled by the "sqlite-script" format. Tables are only
+// created if missing, so that multiple listings can be loaded in the same
+// database.
+const sqlSchema = `BEGIN TRANSACTION;
+CREATE TABLE IF NOT EXISTS entries (
+	path TEXT NOT NULL,
+	relpath TEXT NOT NULL,
+	type TEXT NOT NULL,
+	size INTEGER,
+	alloc INTEGER,
+	checksum TEXT,
+	partial INTEGER NOT NULL,
+	link_target TEXT,
+	link TEXT,
+	birth_time TEXT,
+	hardlink_of TEXT
+);
+CREATE TABLE IF NOT EXISTS digests (
+	relpath TEXT NOT NULL,
+	algo TEXT NOT NULL,
+	sum TEXT NOT NULL
+);
+`
+
+// sqlIndexes are created once all the entries are inserted, which is faster
+// than maintaining them during the inserts.
+const sqlIndexes = `CREATE INDEX IF NOT EXISTS entries_relpath ON entries (relpath);
+CREATE INDEX IF NOT EXISTS digests_relpath ON digests (relpath);
+COMMIT;
+`
+
+// sqlEncoder writes a listing as a SQL script, in the SQLite dialect, inserting
+// the entries into the entries table, and their Hash digests into the digests
+// table. Unknown values are NULL.
+type sqlEncoder struct {
+	w   io.Writer
+	buf []byte
+}
+
+// NewSQLiteScriptEncoder returns the Encoder of the "sqlite-script" format,
+// writing into w the listing as a SQL script which, loaded with the sqlite3
+// shell, fills a database that can be queried without loading the listing in
+// memory. It doesn't write a database file itself, which would require a
+// SQLite driver:
+//
+//	$ dirtree -format sqlite-script dir | sqlite3 listing.db
+//	$ sqlite3 listing.db "SELECT relpath, size FROM entries ORDER BY size DESC LIMIT 10"
+//
+// The entries table has the path, relpath, type, size, alloc, checksum,
+// partial, link_target, link, birth_time and hardlink_of columns, indexed on
+// relpath. The digests table holds the relpath, algo and sum of each checksum
+// computed with the Hash option.
+func NewSQLiteScriptEncoder(w io.Writer) Encoder {
+	return &sqlEncoder{w: w}
+}
+
+func (enc *sqlEncoder) Begin() error {
+	_, err := io.WriteString(enc.w, sqlSchema)
+	return err
+}
+
+func (enc *sqlEncoder) Entry(e *Entry) error {
+	b := append(enc.buf[:0], "INSERT INTO entries VALUES ("...)
+	b = appendSQLString(b, e.Path)
+	b = appendSQLString(append(b, ','), e.RelPath)
+	b = appendSQLString(append(b, ','), e.Type.String())
+	b = append(b, ',')
+	if e.mode&ModeSize != 0 {
+		b = strconv.AppendInt(b, e.Size, 10)
+	} else {
+		b = append(b, "NULL"...)
+	}
+	b = append(b, ',')
+	if e.mode&ModeAlloc != 0 && e.Alloc >= 0 {
+		b = strconv.AppendInt(b, e.Alloc, 10)
+	} else {
+		b = append(b, "NULL"...)
+	}
+	b = appendSQLNullString(append(b, ','), e.Checksum)
+	if e.partial {
+		b = append(b, ",1"...)
+	} else {
+		b = append(b, ",0"...)
+	}
+	b = appendSQLNullString(append(b, ','), e.LinkTarget)
+	b = append(b, ',')
+	if e.Type == Symlink && e.Link != LinkUnresolved {
+		b = appendSQLString(b, e.Link.String())
+	} else {
+		b = append(b, "NULL"...)
+	}
+	b = append(b, ',')
+	if !e.BirthTime.IsZero() {
+		b = appendSQLString(b, e.BirthTime.UTC().Format(time.RFC3339))
+	} else {
+		b = append(b, "NULL"...)
+	}
+	b = appendSQLNullString(append(b, ','), e.HardlinkOf)
+	b = append(b, ");\n"...)
+
+	for _, d := range e.Digests {
+		if isNA(d.Sum) {
+			continue
+		}
+		b = append(b, "INSERT INTO digests VALUES ("...)
+		b = appendSQLString(b, e.RelPath)
+		b = appendSQLString(append(b, ','), d.Algo)
+		b = appendSQLString(append(b, ','), d.Sum)
+		b = append(b, ");\n"...)
+	}
+	enc.buf = b
+	_, err := enc.w.Write(b)
+	return err
+}
+
+func (enc *sqlEncoder) End() error {
+	_, err := io.WriteString(enc.w, sqlIndexes)
+	return err
+}
+
+// appendSQLString appends s to b as a SQL string literal.
+func appendSQLString(b []byte, s string) []byte {
+	b = append(b, '\'')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\'' {
+			b = append(b, '\'')
+		}
+		b = append(b, s[i])
+	}
+	return append(b, '\'')
+}
+
+// appendSQLNullString appends s to b as a SQL string literal, or NULL if s is
+// empty or n/a, padded or not.
+func appendSQLNullString(b []byte, s string) []byte {
+	if isNA(s) {
+		return append(b, "NULL"...)
+	}
+	return appendSQLString(b, s)
+}
+
+// isNA reports whether s is empty or n/a, possibly padded as by checksumNA.
+func isNA(s string) bool {
+	s = strings.TrimSpace(s)
+	return s == "" || s == na
+}
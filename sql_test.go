@@ -0,0 +1,48 @@
+package dirtree
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSQLiteScriptEncoder(t *testing.T) {
+	fsys := fstest.MapFS{
+		"it's":      &fstest.MapFile{Data: []byte("dummy")},
+		"dir/file2": &fstest.MapFile{Data: []byte("content")},
+		"link":      &fstest.MapFile{Data: []byte("it's"), Mode: fs.ModeSymlink},
+	}
+
+	got, err := SprintFS(fsys, ".", ModeType|ModeSize|ModeCRC32|ModeLink, Hash("md5"), Encoding(NewSQLiteScriptEncoder))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := sqlSchema +
+		`INSERT INTO entries VALUES ('.','.','d',0,NULL,NULL,0,NULL,NULL,NULL,NULL);
+INSERT INTO entries VALUES ('dir','dir','d',0,NULL,NULL,0,NULL,NULL,NULL,NULL);
+INSERT INTO entries VALUES ('dir/file2','dir/file2','f',7,NULL,'fec530a9',0,NULL,NULL,NULL,NULL);
+INSERT INTO digests VALUES ('dir/file2','md5','9a0364b9e99bb480dd25e1f0284c8555');
+INSERT INTO entries VALUES ('it''s','it''s','f',5,NULL,'4ff4f23f',0,NULL,NULL,NULL,NULL);
+INSERT INTO digests VALUES ('it''s','md5','275876e34cf609db118f3d84b799a790');
+INSERT INTO entries VALUES ('link','link','l',4,NULL,NULL,0,'it''s','valid',NULL,NULL);
+` + sqlIndexes
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestSQLiteScriptEncoderNA(t *testing.T) {
+	// Checksums not available, padded as printed, are NULL.
+	var sb strings.Builder
+	enc := NewSQLiteScriptEncoder(&sb)
+	ent := &Entry{Path: "dir", RelPath: "dir", Type: Dir, Checksum: checksumNA(), mode: ModeType | ModeCRC32}
+	ent.Digests = []Digest{{Algo: "md5", Sum: "n/a     "}}
+	if err := enc.Entry(ent); err != nil {
+		t.Fatal(err)
+	}
+	want := "INSERT INTO entries VALUES ('dir','dir','d',NULL,NULL,NULL,0,NULL,NULL,NULL,NULL);\n"
+	if sb.String() != want {
+		t.Errorf("got %q, want %q", sb.String(), want)
+	}
+}
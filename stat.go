@@ -0,0 +1,54 @@
+package dirtree
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Stat returns the Entry describing the single file at name, as it would be
+// listed by List. Symbolic links are not followed. RelPath and Path are both
+// set to the slash-separated name, and symbolic links are checked for escaping
+// from the current directory.
+//
+// Only the options controlling the information gathered for a file, like
+// PrintMode, HashLimit or Throttle, have an effect. Since each call describes a
+// single file, ModeHardlink never detects hard links, even between files
+// described by successive calls.
+func Stat(name string, opts ...Option) (*Entry, error) {
+	return StatFS(nil, name, opts...)
+}
+
+// StatFS is like Stat but for a file of the given filesystem, relative to its
+// root.
+func StatFS(fsys fs.FS, name string, opts ...Option) (*Entry, error) {
+	cfg, err := newConfig(opts)
+	if err != nil {
+		return nil, fmt.Errorf("dirtree: %w", err)
+	}
+	cfg.throttler = newThrottler(cfg.throttle)
+
+	var fi fs.FileInfo
+	switch fsys := fsys.(type) {
+	case nil:
+		fi, err = os.Lstat(name)
+	case readLinkFS:
+		fi, err = fsys.Lstat(name)
+	default:
+		fi, err = fs.Stat(fsys, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("dirtree: %w", err)
+	}
+
+	rel := filepath.ToSlash(name)
+	if cfg.normalize != nil {
+		rel = cfg.normalize(rel)
+	}
+	ent := &Entry{RelPath: rel, Path: filepath.ToSlash(name)}
+	if err := fillEntry(ent, &cfg, fsys, ".", name, infoDirEntry{fi}); err != nil {
+		return nil, fmt.Errorf("dirtree: %w", &WalkError{Path: name, Err: err})
+	}
+	return ent, nil
+}
@@ -0,0 +1,30 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package dirtree
+
+import "io/fs"
+
+// allocSize returns the number of bytes allocated on disk for the file
+// described by fi, if known.
+func allocSize(fi fs.FileInfo) (int64, bool) {
+	return 0, false
+}
+
+// ownerIDs returns the user and group IDs owning the file described by fi, if
+// known.
+func ownerIDs(fi fs.FileInfo) (uid, gid uint32, ok bool) {
+	return 0, 0, false
+}
+
+// fileID returns the identifier of the file described by fi, and its number of
+// hard links, if known.
+func fileID(fi fs.FileInfo) (id devIno, nlink uint64, ok bool) {
+	return devIno{}, 0, false
+}
+
+// isTransientErrno reports whether err is a system error which may not happen
+// again if the failed operation is retried.
+func isTransientErrno(err error) bool {
+	return false
+}
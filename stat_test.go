@@ -0,0 +1,59 @@
+package dirtree
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestStat(t *testing.T) {
+	name := filepath.Join("testdata", "dir", "A", "file1")
+	ent, err := Stat(name, ModeAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "f 13b        crc=0451ac5e testdata/dir/A/file1"; ent.String() != want {
+		t.Errorf("got %q, want %q", ent.String(), want)
+	}
+
+	ent, err = Stat(filepath.Join("testdata", "dir", "A", "symfile1"), ModeType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ent.Type != Symlink {
+		t.Errorf("Type = %v, want Symlink", ent.Type)
+	}
+
+	if _, err := Stat(filepath.Join("testdata", "missing")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat() error = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestStatFS(t *testing.T) {
+	fsys := fstest.MapFS{"dir/file": &fstest.MapFile{Data: []byte("dummy")}}
+
+	ent, err := StatFS(fsys, "dir/file", ModeAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "f 5b         crc=4ff4f23f dir/file"; ent.String() != want {
+		t.Errorf("got %q, want %q", ent.String(), want)
+	}
+}
+
+func TestStatThrottle(t *testing.T) {
+	fsys := fstest.MapFS{"file": &fstest.MapFile{Data: make([]byte, hashBufSize+1000)}}
+
+	// The second read waits for the first one, of hashBufSize bytes, to be
+	// spread over time.
+	start := time.Now()
+	if _, err := StatFS(fsys, "file", ModeCRC32, Throttle(2*hashBufSize)); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 400*time.Millisecond {
+		t.Errorf("Stat took %v, want at least 400ms", d)
+	}
+}
@@ -0,0 +1,55 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package dirtree
+
+import (
+	"errors"
+	"io/fs"
+	"syscall"
+)
+
+// allocSize returns the number of bytes allocated on disk for the file
+// described by fi, if known.
+func allocSize(fi fs.FileInfo) (int64, bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	// st_blocks is always expressed in 512-byte units.
+	return int64(st.Blocks) * 512, true
+}
+
+// ownerIDs returns the user and group IDs owning the file described by fi, if
+// known.
+func ownerIDs(fi fs.FileInfo) (uid, gid uint32, ok bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return st.Uid, st.Gid, true
+}
+
+// fileID returns the identifier of the file described by fi, and its number of
+// hard links, if known.
+func fileID(fi fs.FileInfo) (id devIno, nlink uint64, ok bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return devIno{}, 0, false
+	}
+	return devIno{dev: uint64(st.Dev), ino: uint64(st.Ino)}, uint64(st.Nlink), true
+}
+
+// isTransientErrno reports whether err is a system error which may not happen
+// again if the failed operation is retried.
+func isTransientErrno(err error) bool {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+	switch errno {
+	case syscall.EINTR, syscall.EAGAIN, syscall.EBUSY, syscall.ETIMEDOUT, syscall.ESTALE:
+		return true
+	}
+	return false
+}
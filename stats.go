@@ -0,0 +1,38 @@
+package dirtree
+
+import "time"
+
+// Stats holds statistics about a directory walk.
+//
+// A *Stats is an Option: when provided, it gets reset at the beginning of the
+// walk and then filled as the walk proceeds.
+//
+//	var st dirtree.Stats
+//	entries, err := dirtree.List("dir", &st)
+type Stats struct {
+	Visited int // Visited is the number of files met during the walk
+	Dirs    int // Dirs is the number of directories visited
+	Listed  int // Listed is the number of entries in the listing
+	Skipped int // Skipped is the number of files excluded by the options
+	Files   int // Files is the number of regular files in the listing
+
+	Bytes       int64 // Bytes is the total size of the listed regular files, with ModeSize
+	BytesHashed int64 // BytesHashed is the number of bytes read for checksums
+	MaxPathLen  int   // MaxPathLen is the length, in characters, of the longest listed RelPath
+
+	WalkTime time.Duration // WalkTime is the total duration of the walk
+	StatTime time.Duration // StatTime is the time spent getting file info
+	HashTime time.Duration // HashTime is the time spent computing checksums
+}
+
+func (st *Stats) apply(cfg *config) error {
+	cfg.stats = st
+	return nil
+}
+
+// skip records a file excluded from the listing. st can be nil.
+func (st *Stats) skip() {
+	if st != nil {
+		st.Skipped++
+	}
+}
@@ -0,0 +1,130 @@
+package dirtree
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// StoreFS walks the directory rooted at root in the given filesystem, or in
+// the OS filesystem if fsys is nil, and copies each listed regular file into
+// the content-addressable store at dir, created if needed. It writes into w
+// the listing, as WriteFS does, which is the index of the stored files.
+//
+// Files are stored at objects/ab/cdef… under dir, named after their digest
+// computed with the first Hash option, or SHA-256 if there's none, which is
+// then added to the listing. That first Hash must be collision resistant,
+// "sha256" or "sha512", since a file which digest is already in the store
+// isn't stored. A file already in the store, because it's been
+// stored by a previous call or has the same content as another listed file, is
+// not copied again. Stored files are read-only.
+//
+// StoreFS returns an error wrapping ErrInvalidOption if HashLimit is provided,
+// since partial checksums can't identify contents, or if the first Hash isn't
+// collision resistant.
+//
+//	f, err := os.Create("backup/index.txt")
+//	...
+//	err = dirtree.Store(f, "backup", "dir", dirtree.Hash("sha256"))
+func StoreFS(w io.Writer, dir string, fsys fs.FS, root string, opts ...Option) error {
+	cfg, err := newConfig(opts)
+	if err != nil {
+		return fmt.Errorf("dirtree: %w", err)
+	}
+	if cfg.hashLimit != 0 {
+		return fmt.Errorf("dirtree: %w: HashLimit can't be used to store files", ErrInvalidOption)
+	}
+	if len(cfg.format.hashes) == 0 {
+		if err := Hash("sha256").apply(&cfg); err != nil {
+			return fmt.Errorf("dirtree: %w", err)
+		}
+	}
+	algo := cfg.format.hashes[0].algo
+	if !storeAlgos[algo] {
+		return fmt.Errorf("dirtree: %w: can't store files by %s checksum, must be sha256 or sha512", ErrInvalidOption, algo)
+	}
+
+	return writeTree(w, root, fsys, &cfg, func(ent *Entry) error {
+		if ent.Type != File {
+			return nil
+		}
+		if err := storeObject(dir, algo, ent); err != nil {
+			return &WalkError{Path: ent.Path, Err: err}
+		}
+		return nil
+	})
+}
+
+// storeAlgos are the Hash algorithms which digests identify the stored files.
+var storeAlgos = map[string]bool{"sha256": true, "sha512": true}
+
+// Store walks the directory rooted at root and copies each listed regular file
+// into the content-addressable store at dir. See StoreFS.
+func Store(w io.Writer, dir, root string, opts ...Option) error {
+	return StoreFS(w, dir, nil, root, opts...)
+}
+
+// storeObject copies the file of ent into the store at dir, unless it's
+// already there, under its digest computed with algo.
+func storeObject(dir, algo string, ent *Entry) error {
+	var sum string
+	for _, d := range ent.Digests {
+		if d.Algo == algo {
+			sum = d.Sum
+		}
+	}
+	if len(sum) < 3 || sum == na {
+		return fmt.Errorf("no %s checksum to store the file", algo)
+	}
+
+	objdir := filepath.Join(dir, "objects", sum[:2])
+	obj := filepath.Join(objdir, sum[2:])
+	if _, err := os.Lstat(obj); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(objdir, 0o755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so that a partially written object is
+	// never taken for a stored one.
+	tmp, err := os.CreateTemp(objdir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := hashAlgos[algo]()
+	err = copyFile(io.MultiWriter(tmp, h), ent)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != sum {
+		return fmt.Errorf("file changed during the walk: %s %s, want %s", algo, got, sum)
+	}
+	if err := os.Chmod(tmp.Name(), 0o444); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), obj)
+}
+
+// copyFile copies the whole content of the file of ent into w.
+func copyFile(w io.Writer, ent *Entry) error {
+	f, err := ent.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	buf := hashBufPool.Get().(*[]byte)
+	defer hashBufPool.Put(buf)
+
+	_, err = io.CopyBuffer(w, struct{ io.Reader }{f}, *buf)
+	return err
+}
@@ -0,0 +1,83 @@
+package dirtree
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestStore(t *testing.T) {
+	fsys := fstest.MapFS{
+		"A/file1":   &fstest.MapFile{Data: []byte("dummy")},
+		"A/B/file2": &fstest.MapFile{Data: []byte("content")},
+		"A/B/copy":  &fstest.MapFile{Data: []byte("dummy")},
+	}
+	dir := t.TempDir()
+
+	var index bytes.Buffer
+	if err := StoreFS(&index, dir, fsys, ".", ModeType, Hash("sha256")); err != nil {
+		t.Fatal(err)
+	}
+	want := `d sha256=n/a                                                              .
+d sha256=n/a                                                              A
+d sha256=n/a                                                              A/B
+f sha256=b5a2c96250612366ea272ffac6d9744aaf4b45aacd96aa7cfcb931ee3b558259 A/B/copy
+f sha256=ed7002b439e9ac845f22357d822bac1444730fbdb6016d3ec9432297b9ec9f73 A/B/file2
+f sha256=b5a2c96250612366ea272ffac6d9744aaf4b45aacd96aa7cfcb931ee3b558259 A/file1
+`
+	if index.String() != want {
+		t.Errorf("index:\n%s\nwant:\n%s", index.String(), want)
+	}
+
+	var objects []string
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		objects = append(objects, filepath.ToSlash(rel)+" "+fi.Mode().String()+" "+string(data))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(objects)
+	wantObjects := []string{
+		"objects/b5/a2c96250612366ea272ffac6d9744aaf4b45aacd96aa7cfcb931ee3b558259 -r--r--r-- dummy",
+		"objects/ed/7002b439e9ac845f22357d822bac1444730fbdb6016d3ec9432297b9ec9f73 -r--r--r-- content",
+	}
+	if strings.Join(objects, "\n") != strings.Join(wantObjects, "\n") {
+		t.Errorf("objects:\n%s\nwant:\n%s", strings.Join(objects, "\n"), strings.Join(wantObjects, "\n"))
+	}
+
+	// Storing again reuses the stored objects, and SHA-256 is the default.
+	index.Reset()
+	if err := StoreFS(&index, dir, fsys, "A/B", ModeType); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(index.String(), "sha256=ed7002b439e9ac845f22357d822bac1444730fbdb6016d3ec9432297b9ec9f73 file2") {
+		t.Errorf("index doesn't contain the sha256 of A/B/file2:\n%s", index.String())
+	}
+
+	err = StoreFS(&index, dir, fsys, ".", HashLimit(10))
+	if !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("StoreFS with HashLimit: error = %v, want ErrInvalidOption", err)
+	}
+
+	// Checksums which collide can't identify stored files.
+	for _, algo := range []string{"crc32", "xxh64", "md5", "sha1"} {
+		err = StoreFS(&index, dir, fsys, ".", Hash(algo), Hash("sha256"))
+		if !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("StoreFS with Hash(%s): error = %v, want ErrInvalidOption", algo, err)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package dirtree
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/fs"
+	"time"
+)
+
+// WriteTarFS walks the directory rooted at root in the given filesystem, or in
+// the OS filesystem if fsys is nil, and writes into w a tar archive of the
+// listed files, in the listing order.
+//
+// Metadata are normalized so that identical trees produce byte-identical
+// archives, whatever the system: modification times are the Unix epoch, owners
+// are 0, and permissions are 0644 for files and 0755 for directories. The root
+// directory itself is not archived. Symbolic links are archived as such, unless
+// followed with FollowSymlinks. Other file types, which can't be archived
+// portably, are skipped.
+//
+// As with WriteFS, options control the files archived. The PrintMode has no
+// effect on the archive.
+func WriteTarFS(w io.Writer, fsys fs.FS, root string, opts ...Option) error {
+	tw := tar.NewWriter(w)
+	err := walkArchive(fsys, root, opts, func(ent *Entry) error {
+		hdr := &tar.Header{
+			Name:    ent.RelPath,
+			ModTime: time.Unix(0, 0),
+		}
+		switch ent.Type {
+		case File:
+			hdr.Typeflag = tar.TypeReg
+			hdr.Mode = 0o644
+			hdr.Size = ent.Size
+		case Dir:
+			hdr.Typeflag = tar.TypeDir
+			hdr.Name += "/"
+			hdr.Mode = 0o755
+		case Symlink:
+			hdr.Typeflag = tar.TypeSymlink
+			hdr.Linkname = ent.LinkTarget
+			hdr.Mode = 0o777
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("can't write archive: %w", err)
+		}
+		if ent.Type != File {
+			return nil
+		}
+		if err := copyContent(tw, ent); err != nil {
+			return &WalkError{Path: ent.Path, Err: err}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("dirtree: can't write archive: %w", err)
+	}
+	return nil
+}
+
+// WriteTar walks the directory rooted at root and writes into w a tar archive
+// of the listed files. See WriteTarFS.
+func WriteTar(w io.Writer, root string, opts ...Option) error {
+	return WriteTarFS(w, nil, root, opts...)
+}
+
+// walkArchive walks the directory rooted at root in fsys, and calls fn with
+// the entries to archive: the listed files, directories and symbolic links,
+// with their size and link target, but not the root itself. The other entries
+// are skipped, and logged.
+func walkArchive(fsys fs.FS, root string, opts []Option, fn func(ent *Entry) error) error {
+	cfg, err := newConfig(opts)
+	if err != nil {
+		return fmt.Errorf("dirtree: %w", err)
+	}
+	cfg.mode |= ModeSize | ModeLink
+
+	err = walkTree(root, fsys, &cfg, func(ent *Entry) error {
+		switch {
+		case ent.RelPath == ".":
+			return nil
+		case ent.Type == Symlink && ent.LinkTarget == "":
+			cfg.logf("%s: skipped from archive, can't read symbolic link", ent.Path)
+			return nil
+		case ent.Type != File && ent.Type != Dir && ent.Type != Symlink:
+			cfg.logf("%s: skipped from archive, unsupported file type", ent.Path)
+			return nil
+		}
+		return fn(ent)
+	})
+	if err != nil {
+		return fmt.Errorf("dirtree: %w", err)
+	}
+	return nil
+}
+
+// copyContent copies the content of the file of ent, which must be ent.Size
+// bytes long, into w.
+func copyContent(w io.Writer, ent *Entry) error {
+	f, err := ent.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	buf := hashBufPool.Get().(*[]byte)
+	defer hashBufPool.Put(buf)
+
+	n, err := io.CopyBuffer(w, io.LimitReader(struct{ io.Reader }{f}, ent.Size), *buf)
+	if err != nil {
+		return err
+	}
+	if n != ent.Size {
+		return fmt.Errorf("file changed during the walk: read %d bytes, want %d", n, ent.Size)
+	}
+	return nil
+}
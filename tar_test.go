@@ -0,0 +1,63 @@
+package dirtree
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestWriteTar(t *testing.T) {
+	fsys := fstest.MapFS{
+		"A/file1":    &fstest.MapFile{Data: []byte("dummy"), Mode: 0o600, ModTime: time.Now()},
+		"A/B/file2":  &fstest.MapFile{Data: []byte("content")},
+		"A/file.tmp": &fstest.MapFile{Data: []byte("tmp")},
+		"link":       &fstest.MapFile{Data: []byte("A/file1"), Mode: fs.ModeSymlink},
+	}
+
+	var buf1, buf2 bytes.Buffer
+	if err := WriteTarFS(&buf1, fsys, ".", Ignore("*/*.tmp")); err != nil {
+		t.Fatal(err)
+	}
+	fsys["A/file1"].ModTime = time.Now().Add(time.Hour)
+	if err := WriteTarFS(&buf2, fsys, ".", Ignore("*/*.tmp"), ModeAll, Jobs(2)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf1.Bytes(), buf2.Bytes()) {
+		t.Errorf("archives of the same tree differ")
+	}
+
+	var got []string
+	tr := tar.NewReader(&buf1)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hdr.ModTime.Unix() != 0 || hdr.Uid != 0 {
+			t.Errorf("%s: metadata not normalized: %v %d", hdr.Name, hdr.ModTime, hdr.Uid)
+		}
+		got = append(got, hdr.Name+" "+hdr.FileInfo().Mode().String()+" "+string(data)+hdr.Linkname)
+	}
+	want := []string{
+		"A/ drwxr-xr-x ",
+		"A/B/ drwxr-xr-x ",
+		"A/B/file2 -rw-r--r-- content",
+		"A/file1 -rw-r--r-- dummy",
+		"link Lrwxrwxrwx A/file1",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,64 @@
+package dirtree
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// The Throttle option limits the rate at which files are read to compute their
+// checksums to n bytes per second, in total for all the jobs set by the Jobs
+// option, so that background verifications don't saturate disks shared with
+// other workloads. 0, the default, means there's no limit.
+type Throttle int64
+
+func (n Throttle) apply(cfg *config) error {
+	if n < 0 {
+		return fmt.Errorf("%w: negative Throttle", ErrInvalidOption)
+	}
+	cfg.throttle = int64(n)
+	return nil
+}
+
+// A throttler spreads reads over time so that their rate doesn't exceed a
+// number of bytes per second. It's safe for concurrent use.
+type throttler struct {
+	rate int64 // bytes per second
+
+	mu   sync.Mutex
+	next time.Time // time at which the next read can start
+}
+
+func newThrottler(rate int64) *throttler {
+	if rate == 0 {
+		return nil
+	}
+	return &throttler{rate: rate}
+}
+
+// wait reserves n bytes to read, and waits for the reads reserved before to
+// be spread over time.
+func (t *throttler) wait(n int) {
+	t.mu.Lock()
+	now := time.Now()
+	if t.next.Before(now) {
+		t.next = now
+	}
+	d := t.next.Sub(now)
+	t.next = t.next.Add(time.Duration(n) * time.Second / time.Duration(t.rate))
+	t.mu.Unlock()
+	time.Sleep(d)
+}
+
+// throttledReader is an io.Reader which reads are throttled.
+type throttledReader struct {
+	r io.Reader
+	t *throttler
+}
+
+func (tr throttledReader) Read(p []byte) (int, error) {
+	n, err := tr.r.Read(p)
+	tr.t.wait(n)
+	return n, err
+}
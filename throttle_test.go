@@ -0,0 +1,31 @@
+package dirtree
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestThrottle(t *testing.T) {
+	fsys := fstest.MapFS{
+		"A/file1": &fstest.MapFile{Data: make([]byte, 3000)},
+		"A/file2": &fstest.MapFile{Data: make([]byte, 3000)},
+	}
+
+	for _, jobs := range []int{1, 2} {
+		start := time.Now()
+		if _, err := ListFS(fsys, ".", ModeCRC32, Throttle(20000), Jobs(jobs)); err != nil {
+			t.Fatal(err)
+		}
+		// The first read doesn't wait, the following ones wait for the
+		// previous reads, 3000 bytes at least, to be spread over time.
+		if d := time.Since(start); d < 150*time.Millisecond {
+			t.Errorf("Jobs(%d): listing took %v, want at least 150ms", jobs, d)
+		}
+	}
+
+	if _, err := ListFS(fsys, ".", Throttle(-1)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Throttle(-1) error = %v, want ErrInvalidOption", err)
+	}
+}
@@ -0,0 +1,56 @@
+package dirtree
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The EntryTimeout option bounds the time spent getting the info of a file, or
+// reading it to compute its checksums, to d. Once elapsed, the file is listed
+// without the information which couldn't be gathered, its checksums reported
+// as n/a, with a "(timed out)" annotation printed after the path, and the walk
+// moves on. A single file hung on a dying network filesystem then can't stall
+// the whole listing. 0, the default, means there's no timeout.
+//
+// Operations can't be interrupted, those which timed out keep running in the
+// background until the system returns.
+type EntryTimeout time.Duration
+
+func (d EntryTimeout) apply(cfg *config) error {
+	if d < 0 {
+		return fmt.Errorf("%w: negative EntryTimeout", ErrInvalidOption)
+	}
+	cfg.entryTimeout = time.Duration(d)
+	return nil
+}
+
+var errTimedOut = errors.New("operation timed out")
+
+// withTimeout calls op and returns its results, or gives up on it and returns
+// an error wrapping errTimedOut if it's still running after the EntryTimeout
+// option.
+func (cfg *config) withTimeout(op func() (interface{}, error)) (interface{}, error) {
+	if cfg.entryTimeout == 0 {
+		return op()
+	}
+
+	type result struct {
+		v   interface{}
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		v, err := op()
+		done <- result{v, err}
+	}()
+
+	t := time.NewTimer(cfg.entryTimeout)
+	defer t.Stop()
+	select {
+	case r := <-done:
+		return r.v, r.err
+	case <-t.C:
+		return nil, fmt.Errorf("%w after %v", errTimedOut, cfg.entryTimeout)
+	}
+}
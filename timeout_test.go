@@ -0,0 +1,72 @@
+package dirtree
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// hungFS is a fstest.MapFS on which opening the files named "hung", or getting
+// the info of those named "hung-stat", blocks until release is closed.
+type hungFS struct {
+	fstest.MapFS
+	release chan struct{}
+}
+
+func (fsys hungFS) Open(name string) (fs.File, error) {
+	if path.Base(name) == "hung" {
+		<-fsys.release
+	}
+	return fsys.MapFS.Open(name)
+}
+
+func (fsys hungFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	ents, err := fsys.MapFS.ReadDir(name)
+	for i, e := range ents {
+		if e.Name() == "hung-stat" {
+			ents[i] = hungDirEntry{e, fsys.release}
+		}
+	}
+	return ents, err
+}
+
+type hungDirEntry struct {
+	fs.DirEntry
+	release chan struct{}
+}
+
+func (d hungDirEntry) Info() (fs.FileInfo, error) {
+	<-d.release
+	return d.DirEntry.Info()
+}
+
+func TestEntryTimeout(t *testing.T) {
+	fsys := hungFS{
+		MapFS: fstest.MapFS{
+			"A/file1":     &fstest.MapFile{Data: []byte("dummy")},
+			"A/hung":      &fstest.MapFile{Data: []byte("dummy")},
+			"A/hung-stat": &fstest.MapFile{Data: []byte("dummy")},
+		},
+		release: make(chan struct{}),
+	}
+	defer close(fsys.release)
+
+	got, err := SprintFS(fsys, ".", Type("f"), ModeSize|ModeCRC32, EntryTimeout(20*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "" +
+		"5b         crc=4ff4f23f A/file1\n" +
+		"5b         crc=n/a      A/hung (timed out)\n" +
+		"0b         crc=n/a      A/hung-stat (timed out)\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	if _, err := ListFS(fsys, ".", EntryTimeout(-1)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("EntryTimeout(-1) error = %v, want ErrInvalidOption", err)
+	}
+}
@@ -0,0 +1,74 @@
+package dirtree
+
+import (
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+// A Usage holds the aggregates of an immediate child of a root directory and
+// of its content, as reported by TopLevelUsage.
+type Usage struct {
+	Name    string // Name is the name of the child of the root
+	Entries int    // Entries is the number of listed entries, the child included
+	Files   int    // Files is the number of listed regular files
+	Bytes   int64  // Bytes is the total size of the listed regular files
+}
+
+// TopLevelUsageFS walks the directory rooted at root in the given filesystem,
+// or in the OS filesystem if fsys is nil, and returns the aggregates of each
+// immediate child of root, like du -s -d1 does, sorted by name. Only listed
+// entries are counted, so the options filtering the listing also apply to the
+// report. A child having no listed entry is not reported.
+//
+//	usage, err := dirtree.TopLevelUsage("monorepo", dirtree.IgnoreVCS)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	for _, u := range usage {
+//		fmt.Printf("%s\t%d files\t%d bytes\n", u.Name, u.Files, u.Bytes)
+//	}
+func TopLevelUsageFS(fsys fs.FS, root string, opts ...Option) ([]Usage, error) {
+	cfg, err := newConfig(opts)
+	if err != nil {
+		return nil, fmt.Errorf("dirtree: %w", err)
+	}
+	cfg.mode |= ModeSize
+
+	var usage []Usage
+	index := make(map[string]int) // index in usage, by name
+	err = walkTree(root, fsys, &cfg, func(ent *Entry) error {
+		if ent.RelPath == "." {
+			return nil
+		}
+		name := ent.RelPath
+		if i := strings.IndexByte(name, '/'); i >= 0 {
+			name = name[:i]
+		}
+		i, ok := index[name]
+		if !ok {
+			i = len(usage)
+			index[name] = i
+			usage = append(usage, Usage{Name: name})
+		}
+		u := &usage[i]
+		u.Entries++
+		if ent.Type == File {
+			u.Files++
+			u.Bytes += ent.Size
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("dirtree: %w", err)
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Name < usage[j].Name })
+	return usage, nil
+}
+
+// TopLevelUsage walks the directory rooted at root and returns the aggregates
+// of each of its immediate children. See TopLevelUsageFS.
+func TopLevelUsage(root string, opts ...Option) ([]Usage, error) {
+	return TopLevelUsageFS(nil, root, opts...)
+}
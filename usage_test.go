@@ -0,0 +1,46 @@
+package dirtree
+
+import (
+	"io/fs"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestTopLevelUsage(t *testing.T) {
+	fsys := fstest.MapFS{
+		"b/file1":     &fstest.MapFile{Data: []byte("dummy")},
+		"b/c/file2":   &fstest.MapFile{Data: []byte("content")},
+		"b/c/file.o":  &fstest.MapFile{Data: []byte("object")},
+		"a/file3":     &fstest.MapFile{Data: []byte("x")},
+		"top":         &fstest.MapFile{Data: []byte("top")},
+		"empty/dir/d": &fstest.MapFile{Mode: fs.ModeDir | 0o755},
+	}
+
+	got, err := TopLevelUsageFS(fsys, ".", Ignore("*/*/*.o"), Type("f"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Usage{
+		{Name: "a", Entries: 1, Files: 1, Bytes: 1},
+		{Name: "b", Entries: 2, Files: 2, Bytes: 12},
+		{Name: "top", Entries: 1, Files: 1, Bytes: 3},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+
+	got, err = TopLevelUsageFS(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []Usage{
+		{Name: "a", Entries: 2, Files: 1, Bytes: 1},
+		{Name: "b", Entries: 5, Files: 3, Bytes: 18},
+		{Name: "empty", Entries: 3},
+		{Name: "top", Entries: 1, Files: 1, Bytes: 3},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
@@ -0,0 +1,242 @@
+package dirtree
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// dirWalker walks a file tree like fs.WalkDir, or filepath.WalkDir if fsys is
+// nil, with optional ordering of directory entries and following of symbolic
+// links.
+type dirWalker struct {
+	fsys   fs.FS
+	less   func(a, b string) bool // order of directory entries, nil for lexical order
+	follow bool                   // follow symbolic links
+	loop   func(path string)      // called with the symbolic links not followed since they would loop
+
+	maxChain int                      // longest chain of symbolic links followed, 0 for no limit
+	tooLong  func(path string, n int) // called with the symbolic links not followed since their chain is too long
+
+	// real paths of the directories being walked, used to detect loops when
+	// following symbolic links.
+	active map[string]bool
+}
+
+// walk walks the file tree rooted at root, calling fn for each file or
+// directory in the tree, including root.
+func (w *dirWalker) walk(root string, fn fs.WalkDirFunc) error {
+	if _, ok := w.fsys.(readLinkFS); w.fsys != nil && !ok {
+		// Symbolic links can't be resolved.
+		w.follow = false
+	}
+
+	info, err := w.lstat(root)
+	if err == nil && w.follow && info.Mode()&fs.ModeSymlink != 0 {
+		info, err = w.stat(root)
+	}
+
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		var real string
+		if w.follow {
+			w.active = make(map[string]bool)
+			if real, err = w.realpath(root); err != nil {
+				return fn(root, nil, err)
+			}
+		}
+		err = w.walk1(root, real, infoDirEntry{info}, fn)
+	}
+	if err == fs.SkipDir {
+		return nil
+	}
+	return err
+}
+
+// walk1 walks the file at name, which real path (only set when following
+// symbolic links) is real.
+func (w *dirWalker) walk1(name, real string, d fs.DirEntry, fn fs.WalkDirFunc) error {
+	if err := fn(name, d, nil); err != nil || !d.IsDir() {
+		if err == fs.SkipDir && d.IsDir() {
+			// Successfully skipped directory.
+			err = nil
+		}
+		return err
+	}
+
+	dirents, err := w.readDir(name)
+	if err != nil {
+		// Second call, to report ReadDir error.
+		err = fn(name, d, err)
+		if err != nil {
+			if err == fs.SkipDir && d.IsDir() {
+				err = nil
+			}
+			return err
+		}
+	}
+
+	if w.less != nil {
+		sort.SliceStable(dirents, func(i, j int) bool {
+			return w.less(dirents[i].Name(), dirents[j].Name())
+		})
+	}
+
+	if w.follow {
+		w.active[real] = true
+		defer delete(w.active, real)
+	}
+
+	for _, d1 := range dirents {
+		name1 := w.join(name, d1.Name())
+		var real1 string
+		if w.follow {
+			real1 = w.join(real, d1.Name())
+			if d1.Type()&fs.ModeSymlink != 0 {
+				d1, real1 = w.followLink(name1, d1, real1)
+			}
+		}
+		if err := w.walk1(name1, real1, d1, fn); err != nil {
+			if err == fs.SkipDir {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// followLink returns the DirEntry and real path of the target of the symbolic
+// link at name, as a linkDirEntry. The link itself is returned if it can't be
+// resolved, or if its target is a directory being walked, or if its chain is
+// longer than maxChain, as a linkDirEntry in this last case.
+func (w *dirWalker) followLink(name string, d fs.DirEntry, real string) (fs.DirEntry, string) {
+	info, err := w.stat(name)
+	if err != nil {
+		return d, real
+	}
+	target, err := w.realpath(name)
+	if err != nil {
+		return d, real
+	}
+	n := w.chainLen(name)
+	if w.maxChain != 0 && n > w.maxChain {
+		if w.tooLong != nil {
+			w.tooLong(name, n)
+		}
+		return linkDirEntry{d, n, target}, real
+	}
+	if info.IsDir() && w.active[target] {
+		if w.loop != nil {
+			w.loop(name)
+		}
+		return d, real
+	}
+	return linkDirEntry{infoDirEntry{renamedInfo{info, d.Name()}}, n, target}, target
+}
+
+// chainLen returns the number of symbolic links in the chain starting at the
+// link at name, each one pointing to the next, up to maxLinks.
+func (w *dirWalker) chainLen(name string) int {
+	n := 0
+	for ; n <= maxLinks; n++ {
+		info, err := w.lstat(name)
+		if err != nil || info.Mode()&fs.ModeSymlink == 0 {
+			break
+		}
+		target, err := w.readLink(name)
+		if err != nil {
+			return n + 1
+		}
+		switch {
+		case w.fsys != nil && path.IsAbs(target):
+			// Not resolvable in a fs.FS.
+			return n + 1
+		case w.fsys != nil:
+			name = path.Join(path.Dir(name), target)
+		case filepath.IsAbs(target):
+			name = target
+		default:
+			name = filepath.Join(filepath.Dir(name), target)
+		}
+	}
+	return n
+}
+
+func (w *dirWalker) join(dir, name string) string {
+	if w.fsys == nil {
+		return filepath.Join(dir, name)
+	}
+	return path.Join(dir, name)
+}
+
+func (w *dirWalker) lstat(name string) (fs.FileInfo, error) {
+	switch fsys := w.fsys.(type) {
+	case nil:
+		return os.Lstat(name)
+	case readLinkFS:
+		return fsys.Lstat(name)
+	}
+	return fs.Stat(w.fsys, name)
+}
+
+func (w *dirWalker) readLink(name string) (string, error) {
+	if w.fsys == nil {
+		return readOSLink(name)
+	}
+	return w.fsys.(readLinkFS).ReadLink(name)
+}
+
+func (w *dirWalker) stat(name string) (fs.FileInfo, error) {
+	if w.fsys == nil {
+		return os.Stat(name)
+	}
+	return fs.Stat(w.fsys, name)
+}
+
+func (w *dirWalker) readDir(name string) ([]fs.DirEntry, error) {
+	if w.fsys == nil {
+		return os.ReadDir(name)
+	}
+	return fs.ReadDir(w.fsys, name)
+}
+
+// realpath returns the path of the file at name, after the evaluation of all
+// symbolic links. For a fs.FS, it must implement readLinkFS.
+func (w *dirWalker) realpath(name string) (string, error) {
+	if w.fsys == nil {
+		p, err := filepath.EvalSymlinks(name)
+		if err != nil {
+			return "", err
+		}
+		return filepath.Abs(p)
+	}
+	return evalSymlinksFS(w.fsys.(readLinkFS), name)
+}
+
+// infoDirEntry is a fs.DirEntry built from a fs.FileInfo.
+type infoDirEntry struct{ fi fs.FileInfo }
+
+func (d infoDirEntry) Name() string               { return d.fi.Name() }
+func (d infoDirEntry) IsDir() bool                { return d.fi.IsDir() }
+func (d infoDirEntry) Type() fs.FileMode          { return d.fi.Mode().Type() }
+func (d infoDirEntry) Info() (fs.FileInfo, error) { return d.fi, nil }
+
+// linkDirEntry is the fs.DirEntry of a followed symbolic link, or of the link
+// itself if its chain is too long, with the information gathered following it.
+type linkDirEntry struct {
+	fs.DirEntry
+	chain    int    // number of links in the chain
+	resolved string // real path of the target
+}
+
+// renamedInfo is a fs.FileInfo with another name.
+type renamedInfo struct {
+	fs.FileInfo
+	name string
+}
+
+func (fi renamedInfo) Name() string { return fi.name }
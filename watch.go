@@ -0,0 +1,384 @@
+package dirtree
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// An Op describes the kind of change reported by an Event.
+type Op uint8
+
+const (
+	Added    Op = iota + 1 // Added is for entries that appeared
+	Removed                // Removed is for entries that disappeared
+	Modified               // Modified is for entries which information changed
+)
+
+func (op Op) String() string {
+	switch op {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Modified:
+		return "modified"
+	}
+	return fmt.Sprintf("Op(%d)", op)
+}
+
+// An Event describes a change in a watched directory tree.
+type Event struct {
+	Op    Op
+	Entry *Entry // For Removed events, the last known state of the entry
+}
+
+func (e Event) String() string {
+	return e.Op.String() + " " + e.Entry.RelPath
+}
+
+// A Watcher watches a directory tree and reports the changes to the entries it
+// contains.
+//
+// On Linux, a Watcher is notified of the changes to the OS filesystem with
+// inotify, and only lists again the files which changed, or the directories in
+// which files were added or removed. Elsewhere, and when watching a fs.FS, it
+// polls: it periodically walks the whole tree and compares the resulting
+// listing with the previous one.
+//
+// Either way, it sees the tree exactly as List does, with the same options,
+// and the granularity of the reported modifications depends on the PrintMode
+// in use: for example ModeCRC32 is required to detect modifications that don't
+// change a file size. With DirChecksums, the whole tree is listed again on
+// each change, since the checksums of directories depend on all their content.
+type Watcher struct {
+	// Events delivers the changes, in a deterministic order for a given set
+	// of changes: removals first, then additions and modifications, in
+	// listing order.
+	Events chan Event
+
+	// Errors delivers the errors occurring while walking the tree. The
+	// Watcher keeps going after an error.
+	Errors chan error
+
+	fsys     fs.FS
+	root     string
+	cfg      config
+	interval time.Duration
+	known    map[string]*Entry // listed entries, by RelPath
+	notify   *notifier         // nil when polling
+	changes  chan []change     // changes reported by notify
+
+	done chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+// A change is a file, in the OS filesystem, reported as changed by a notifier.
+type change struct {
+	path string
+	tree bool // the files below path may have changed too
+}
+
+// Watch starts watching the directory rooted at root. The returned Watcher
+// must be closed when not needed anymore.
+//
+// The changes notified during interval are reported together, or, when
+// polling, the tree is walked every interval.
+//
+// Options are the same as for List and control what entries the Watcher
+// reports changes about.
+func Watch(root string, interval time.Duration, opts ...Option) (*Watcher, error) {
+	return WatchFS(nil, root, interval, opts...)
+}
+
+// WatchFS starts watching the directory rooted at root in the given
+// filesystem, walking it every interval. The returned Watcher must be closed
+// when not needed anymore. With a nil fsys, WatchFS is Watch.
+//
+// Options are the same as for List and control what entries the Watcher
+// reports changes about.
+func WatchFS(fsys fs.FS, root string, interval time.Duration, opts ...Option) (*Watcher, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("dirtree: %w: non-positive watch interval", ErrInvalidOption)
+	}
+	cfg, err := newConfig(opts)
+	if err != nil {
+		return nil, fmt.Errorf("dirtree: %w", err)
+	}
+
+	w := &Watcher{
+		Events:   make(chan Event),
+		Errors:   make(chan error),
+		fsys:     fsys,
+		root:     root,
+		cfg:      cfg,
+		interval: interval,
+		known:    make(map[string]*Entry),
+		done:     make(chan struct{}),
+	}
+	if fsys == nil {
+		if w.notify, err = newNotifier(); err != nil {
+			w.cfg.logf("%s: polling, can't watch for changes: %v", root, err)
+		}
+	}
+
+	// The initial walk gives us the reference listing, and reports walk
+	// errors early.
+	ents, err := w.list(change{path: root, tree: true})
+	if err != nil {
+		if w.notify != nil {
+			w.notify.close()
+		}
+		return nil, fmt.Errorf("dirtree: %w", err)
+	}
+	for _, e := range ents {
+		w.known[e.RelPath] = e
+	}
+
+	if w.notify != nil {
+		w.changes = make(chan []change)
+		w.wg.Add(2)
+		go w.read()
+		go w.wait()
+		return w, nil
+	}
+	w.wg.Add(1)
+	go w.poll()
+	return w, nil
+}
+
+// Close stops watching and closes the Events and Errors channels.
+func (w *Watcher) Close() error {
+	w.once.Do(func() {
+		close(w.done)
+		if w.notify != nil {
+			w.notify.close()
+		}
+		w.wg.Wait()
+		close(w.Events)
+		close(w.Errors)
+	})
+	return nil
+}
+
+// poll walks the whole tree every interval.
+func (w *Watcher) poll() {
+	defer w.wg.Done()
+
+	tick := time.NewTicker(w.interval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-tick.C:
+		}
+		if !w.update([]change{{path: w.root, tree: true}}) {
+			return
+		}
+	}
+}
+
+// read forwards the changes reported by the notifier to wait.
+func (w *Watcher) read() {
+	defer w.wg.Done()
+
+	for {
+		changes, err := w.notify.read()
+		switch {
+		case err == errOverflow:
+			// Changes have been lost.
+			changes = []change{{path: w.root, tree: true}}
+		case err != nil:
+			// Closed.
+			return
+		}
+		select {
+		case w.changes <- changes:
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// wait waits for changes, and reports those occurring during interval
+// together.
+func (w *Watcher) wait() {
+	defer w.wg.Done()
+
+	for {
+		var changes []change
+		select {
+		case <-w.done:
+			return
+		case changes = <-w.changes:
+		}
+
+		timer := time.NewTimer(w.interval)
+	gather:
+		for {
+			select {
+			case <-w.done:
+				timer.Stop()
+				return
+			case more := <-w.changes:
+				changes = append(changes, more...)
+			case <-timer.C:
+				break gather
+			}
+		}
+		if !w.update(changes) {
+			return
+		}
+	}
+}
+
+// list lists the file at c.path, and the files below it if c.tree is true, as
+// they would be in a listing of the whole tree. Directories descended into are
+// watched.
+func (w *Watcher) list(c change) ([]*Entry, error) {
+	cfg := w.cfg
+	cfg.start = c.path
+	if !c.tree {
+		rel, err := filepath.Rel(w.root, c.path)
+		if err != nil {
+			return nil, err
+		}
+		if depth := strings.Count(rel, string(filepath.Separator)) + 1; cfg.depth == 0 || depth < cfg.depth {
+			cfg.depth = depth
+		}
+	}
+	if w.notify != nil {
+		cfg.descend = func(dir string) {
+			if err := w.notify.add(dir); err != nil {
+				cfg.logf("%s: can't watch for changes: %v", dir, err)
+			}
+		}
+	}
+	return listTree(nil, w.root, w.fsys, &cfg)
+}
+
+// update lists again the changed files and sends the resulting events. It
+// returns false if the Watcher has been closed in the meantime.
+func (w *Watcher) update(changes []change) bool {
+	less := w.cfg.collation.less()
+	if w.cfg.dirSums {
+		changes = []change{{path: w.root, tree: true}}
+	}
+
+	// Files below changed trees needn't be listed separately.
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].path != changes[j].path {
+			return changes[i].path < changes[j].path
+		}
+		return changes[i].tree
+	})
+	var tree string // last changed tree
+	var removed, others []Event
+	for i, c := range changes {
+		if tree != "" && (c.path == tree || strings.HasPrefix(c.path, tree+string(filepath.Separator))) ||
+			i > 0 && c.path == changes[i-1].path {
+			continue
+		}
+		rel, err := filepath.Rel(w.root, c.path)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		if c.tree {
+			tree = c.path
+		} else if rel == "." {
+			c.tree = true
+		}
+
+		cur, err := w.list(c)
+		if errors.Is(err, fs.ErrNotExist) {
+			cur, err = nil, nil
+		}
+		if err != nil {
+			select {
+			case w.Errors <- fmt.Errorf("dirtree: %w", err):
+				continue
+			case <-w.done:
+				return false
+			}
+		}
+
+		var prev []*Entry
+		for p, e := range w.known {
+			if p == rel || c.tree && (rel == "." || strings.HasPrefix(p, rel+"/")) {
+				prev = append(prev, e)
+				delete(w.known, p)
+			}
+		}
+		sort.Slice(prev, func(i, j int) bool { return walkLess(prev[i].RelPath, prev[j].RelPath, less) })
+		for _, e := range cur {
+			w.known[e.RelPath] = e
+		}
+		for _, ev := range diffEntries(prev, cur) {
+			if ev.Op == Removed {
+				removed = append(removed, ev)
+			} else {
+				others = append(others, ev)
+			}
+		}
+	}
+
+	sort.SliceStable(removed, func(i, j int) bool {
+		return walkLess(removed[i].Entry.RelPath, removed[j].Entry.RelPath, less)
+	})
+	sort.SliceStable(others, func(i, j int) bool {
+		return walkLess(others[i].Entry.RelPath, others[j].Entry.RelPath, less)
+	})
+	for _, ev := range append(removed, others...) {
+		select {
+		case w.Events <- ev:
+		case <-w.done:
+			return false
+		}
+	}
+	return true
+}
+
+// walkLess reports whether the file at a, a slash-separated path relative to
+// the root, is walked before the file at b. Entries of a same directory are
+// walked in the order given by less.
+func walkLess(a, b string, less func(a, b string) bool) bool {
+	return a != b && cursorPos(a, b, less) != afterCursor
+}
+
+// diffEntries returns the events describing the changes from prev to cur.
+func diffEntries(prev, cur []*Entry) []Event {
+	old := make(map[string]*Entry, len(prev))
+	for _, e := range prev {
+		old[e.RelPath] = e
+	}
+	now := make(map[string]bool, len(cur))
+	for _, e := range cur {
+		now[e.RelPath] = true
+	}
+
+	var evs []Event
+	for _, e := range prev {
+		if !now[e.RelPath] {
+			evs = append(evs, Event{Op: Removed, Entry: e})
+		}
+	}
+	for _, e := range cur {
+		o, ok := old[e.RelPath]
+		switch {
+		case !ok:
+			evs = append(evs, Event{Op: Added, Entry: e})
+		case o.Format() != e.Format():
+			evs = append(evs, Event{Op: Modified, Entry: e})
+		}
+	}
+	return evs
+}
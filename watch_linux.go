@@ -0,0 +1,102 @@
+package dirtree
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// inotifyMask is the set of inotify events a notifier watches directories for.
+const inotifyMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MODIFY | syscall.IN_ATTRIB |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF |
+	syscall.IN_ONLYDIR | syscall.IN_DONT_FOLLOW
+
+// A notifier reports the changes to the files of the directories it watches,
+// with inotify.
+type notifier struct {
+	fd int
+	f  *os.File // of fd, non-blocking so that closing it interrupts reads
+
+	mu   sync.Mutex
+	dirs map[int32]string // watched directories, by watch descriptor
+	buf  [64 << 10]byte
+}
+
+func newNotifier() (*notifier, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	return &notifier{
+		fd:   fd,
+		f:    os.NewFile(uintptr(fd), "inotify"),
+		dirs: make(map[int32]string),
+	}, nil
+}
+
+// add starts watching the directory dir. Watching a directory more than once
+// has no effect.
+func (n *notifier) add(dir string) error {
+	wd, err := syscall.InotifyAddWatch(n.fd, dir, inotifyMask)
+	if err != nil {
+		return &os.PathError{Op: "inotify_add_watch", Path: dir, Err: err}
+	}
+	n.mu.Lock()
+	n.dirs[int32(wd)] = dir
+	n.mu.Unlock()
+	return nil
+}
+
+// read blocks until changes are reported and returns them. Directories are
+// not watched anymore once deleted.
+func (n *notifier) read() ([]change, error) {
+	nr, err := n.f.Read(n.buf[:])
+	if err != nil {
+		return nil, err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var changes []change
+	for off := 0; off+syscall.SizeofInotifyEvent <= nr; {
+		ev := (*syscall.InotifyEvent)(unsafe.Pointer(&n.buf[off]))
+		name := n.buf[off+syscall.SizeofInotifyEvent : off+syscall.SizeofInotifyEvent+int(ev.Len)]
+		off += syscall.SizeofInotifyEvent + int(ev.Len)
+		for len(name) != 0 && name[len(name)-1] == 0 {
+			name = name[:len(name)-1]
+		}
+
+		if ev.Mask&syscall.IN_Q_OVERFLOW != 0 {
+			return nil, errOverflow
+		}
+		dir, ok := n.dirs[ev.Wd]
+		if !ok {
+			continue
+		}
+		switch {
+		case ev.Mask&syscall.IN_IGNORED != 0:
+			delete(n.dirs, ev.Wd)
+		case len(name) == 0:
+			// Changes to the directory itself are reported by its parent,
+			// except for the root.
+			if ev.Mask&(syscall.IN_DELETE_SELF|syscall.IN_MOVE_SELF) != 0 {
+				changes = append(changes, change{path: dir, tree: true})
+			}
+		default:
+			tree := ev.Mask&(syscall.IN_CREATE|syscall.IN_DELETE|syscall.IN_MOVED_FROM|syscall.IN_MOVED_TO) != 0
+			changes = append(changes, change{path: filepath.Join(dir, string(name)), tree: tree})
+		}
+	}
+	return changes, nil
+}
+
+// close stops watching and makes pending and future reads fail.
+func (n *notifier) close() error {
+	return n.f.Close()
+}
+
+// errOverflow is returned by notifier.read when changes have been lost.
+var errOverflow = errors.New("inotify queue overflow")
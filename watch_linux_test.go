@@ -0,0 +1,88 @@
+package dirtree
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchNotifications(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 10; i++ {
+		sub := filepath.Join(dir, fmt.Sprintf("dir%d", i))
+		mkdirs(t, sub)
+		for j := 0; j < 10; j++ {
+			touch(t, filepath.Join(sub, fmt.Sprintf("file%d", j)))
+		}
+	}
+
+	var (
+		st Stats
+		w  *Watcher
+	)
+	watch := func() {
+		t.Helper()
+		var err error
+		w, err = Watch(dir, 10*time.Millisecond, ModeSize, Ignore("*/*.tmp"), &st)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w.notify == nil {
+			t.Fatal("not notified of changes")
+		}
+	}
+	next := func() string {
+		t.Helper()
+		select {
+		case ev := <-w.Events:
+			return ev.String()
+		case err := <-w.Errors:
+			t.Fatal(err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout")
+		}
+		return ""
+	}
+
+	// Only the changed file is listed again.
+	watch()
+	if err := os.WriteFile(filepath.Join(dir, "dir3", "file4"), []byte("abc"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := next(); got != "modified dir3/file4" {
+		t.Errorf("got event %q, want %q", got, "modified dir3/file4")
+	}
+	w.Close()
+	if st.Visited != 1 {
+		t.Errorf("%d files visited, want 1", st.Visited)
+	}
+
+	// Created directories are watched.
+	watch()
+	defer w.Close()
+	mkdirs(t, filepath.Join(dir, "new"))
+	if got := next(); got != "added new" {
+		t.Errorf("got event %q, want %q", got, "added new")
+	}
+	touch(t, filepath.Join(dir, "new", "file.tmp"))
+	touch(t, filepath.Join(dir, "new", "file"))
+	if got := next(); got != "added new/file" {
+		t.Errorf("got event %q, want %q", got, "added new/file")
+	}
+
+	// Removed trees are reported in listing order.
+	if err := os.RemoveAll(filepath.Join(dir, "dir9")); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"removed dir9"}
+	for j := 0; j < 10; j++ {
+		want = append(want, fmt.Sprintf("removed dir9/file%d", j))
+	}
+	for _, w := range want {
+		if got := next(); got != w {
+			t.Errorf("got event %q, want %q", got, w)
+		}
+	}
+}
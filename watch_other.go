@@ -0,0 +1,21 @@
+//go:build !linux
+// +build !linux
+
+package dirtree
+
+import "errors"
+
+// A notifier reports the changes to the files of the directories it watches.
+// Notifications are only implemented on Linux, other systems are polled.
+type notifier struct{}
+
+func newNotifier() (*notifier, error) {
+	return nil, errors.New("file change notifications not supported")
+}
+
+func (*notifier) add(dir string) error    { return nil }
+func (*notifier) read() ([]change, error) { return nil, errOverflow }
+func (*notifier) close() error            { return nil }
+
+// errOverflow is returned by notifier.read when changes have been lost.
+var errOverflow = errors.New("notification queue overflow")
@@ -0,0 +1,82 @@
+package dirtree
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	testWatch(t, func(dir string, opts ...Option) (*Watcher, error) {
+		return Watch(dir, 10*time.Millisecond, opts...)
+	})
+}
+
+func TestWatchPolling(t *testing.T) {
+	testWatch(t, func(dir string, opts ...Option) (*Watcher, error) {
+		return WatchFS(os.DirFS(dir), ".", 10*time.Millisecond, opts...)
+	})
+}
+
+func testWatch(t *testing.T, watch func(dir string, opts ...Option) (*Watcher, error)) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "modified"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "removed"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := watch(dir, Type("f"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if err := os.WriteFile(filepath.Join(dir, "modified"), []byte("ab"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "removed")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "added"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The tree might be walked while being changed, so the same event can be
+	// reported more than once.
+	expected := map[string]bool{
+		"removed removed":   true,
+		"added added":       true,
+		"modified modified": true,
+	}
+	want := make(map[string]bool)
+	for ev := range expected {
+		want[ev] = true
+	}
+
+	timeout := time.After(5 * time.Second)
+	for len(want) != 0 {
+		select {
+		case ev := <-w.Events:
+			if !expected[ev.String()] {
+				t.Fatalf("unexpected event %q", ev)
+			}
+			delete(want, ev.String())
+		case err := <-w.Errors:
+			t.Fatal(err)
+		case <-timeout:
+			t.Fatalf("timeout, missing events: %v", want)
+		}
+	}
+}
+
+func TestWatchErrors(t *testing.T) {
+	if _, err := Watch(filepath.Join("testdata", "dir"), 0); err == nil {
+		t.Errorf("Watch() with zero interval should fail")
+	}
+	if _, err := Watch(filepath.Join("testdata", "dir"), time.Second, Depth(-1)); err == nil {
+		t.Errorf("Watch() with invalid option should fail")
+	}
+}
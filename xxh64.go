@@ -0,0 +1,127 @@
+package dirtree
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+// xxh64 implements the 64-bit xxHash algorithm, with a zero seed, as described
+// at https://github.com/Cyan4973/xxHash/blob/dev/doc/xxhash_spec.md.
+type xxh64 struct {
+	v1, v2, v3, v4 uint64
+	total          uint64
+	mem            [32]byte
+	n              int // number of bytes in mem
+}
+
+const (
+	xxhPrime1 uint64 = 11400714785074694791
+	xxhPrime2 uint64 = 14029467366897019727
+	xxhPrime3 uint64 = 1609587929392839161
+	xxhPrime4 uint64 = 9650029242287828579
+	xxhPrime5 uint64 = 2870177450012600261
+)
+
+func newXXH64() hash.Hash64 {
+	var h xxh64
+	h.Reset()
+	return &h
+}
+
+func (h *xxh64) Reset() {
+	p1, p2 := xxhPrime1, xxhPrime2 // avoid constant overflow
+	h.v1 = p1 + p2
+	h.v2 = p2
+	h.v3 = 0
+	h.v4 = -p1
+	h.total = 0
+	h.n = 0
+}
+
+func (h *xxh64) Size() int      { return 8 }
+func (h *xxh64) BlockSize() int { return 32 }
+
+func (h *xxh64) Write(b []byte) (int, error) {
+	n := len(b)
+	h.total += uint64(n)
+
+	if h.n+len(b) < 32 {
+		h.n += copy(h.mem[h.n:], b)
+		return n, nil
+	}
+
+	if h.n > 0 {
+		c := copy(h.mem[h.n:], b)
+		h.stripe(h.mem[:])
+		b = b[c:]
+		h.n = 0
+	}
+	for ; len(b) >= 32; b = b[32:] {
+		h.stripe(b)
+	}
+	h.n = copy(h.mem[:], b)
+	return n, nil
+}
+
+// stripe consumes the first 32 bytes of b.
+func (h *xxh64) stripe(b []byte) {
+	h.v1 = xxhRound(h.v1, binary.LittleEndian.Uint64(b[0:8]))
+	h.v2 = xxhRound(h.v2, binary.LittleEndian.Uint64(b[8:16]))
+	h.v3 = xxhRound(h.v3, binary.LittleEndian.Uint64(b[16:24]))
+	h.v4 = xxhRound(h.v4, binary.LittleEndian.Uint64(b[24:32]))
+}
+
+func (h *xxh64) Sum64() uint64 {
+	var acc uint64
+	if h.total >= 32 {
+		acc = bits.RotateLeft64(h.v1, 1) + bits.RotateLeft64(h.v2, 7) +
+			bits.RotateLeft64(h.v3, 12) + bits.RotateLeft64(h.v4, 18)
+		acc = xxhMerge(acc, h.v1)
+		acc = xxhMerge(acc, h.v2)
+		acc = xxhMerge(acc, h.v3)
+		acc = xxhMerge(acc, h.v4)
+	} else {
+		acc = xxhPrime5
+	}
+	acc += h.total
+
+	b := h.mem[:h.n]
+	for ; len(b) >= 8; b = b[8:] {
+		acc ^= xxhRound(0, binary.LittleEndian.Uint64(b))
+		acc = bits.RotateLeft64(acc, 27)*xxhPrime1 + xxhPrime4
+	}
+	if len(b) >= 4 {
+		acc ^= uint64(binary.LittleEndian.Uint32(b)) * xxhPrime1
+		acc = bits.RotateLeft64(acc, 23)*xxhPrime2 + xxhPrime3
+		b = b[4:]
+	}
+	for _, c := range b {
+		acc ^= uint64(c) * xxhPrime5
+		acc = bits.RotateLeft64(acc, 11) * xxhPrime1
+	}
+
+	acc ^= acc >> 33
+	acc *= xxhPrime2
+	acc ^= acc >> 29
+	acc *= xxhPrime3
+	acc ^= acc >> 32
+	return acc
+}
+
+func (h *xxh64) Sum(b []byte) []byte {
+	var s [8]byte
+	binary.BigEndian.PutUint64(s[:], h.Sum64())
+	return append(b, s[:]...)
+}
+
+func xxhRound(acc, input uint64) uint64 {
+	acc += input * xxhPrime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * xxhPrime1
+}
+
+func xxhMerge(acc, val uint64) uint64 {
+	acc ^= xxhRound(0, val)
+	return acc*xxhPrime1 + xxhPrime4
+}
@@ -0,0 +1,71 @@
+package dirtree
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"time"
+)
+
+// zipEpoch is the modification time of all the files of zip archives, the
+// earliest date of the MS-DOS format used by zip.
+var zipEpoch = time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// WriteZipFS walks the directory rooted at root in the given filesystem, or in
+// the OS filesystem if fsys is nil, and writes into w a zip archive of the
+// listed files, in the listing order.
+//
+// As with WriteTarFS, metadata are normalized so that identical trees produce
+// byte-identical archives: modification times are 1980-01-01, the zip epoch,
+// and permissions are 0644 for files and 0755 for directories. Files are
+// stored uncompressed, so that the archive doesn't depend on the compressor,
+// and the CRC-32 recorded for each file is the one reported by ModeCRC32.
+// Symbolic links are stored as such, with their target as content.
+func WriteZipFS(w io.Writer, fsys fs.FS, root string, opts ...Option) error {
+	zw := zip.NewWriter(w)
+	err := walkArchive(fsys, root, opts, func(ent *Entry) error {
+		hdr := &zip.FileHeader{
+			Name:     ent.RelPath,
+			Method:   zip.Store,
+			Modified: zipEpoch,
+		}
+		switch ent.Type {
+		case File:
+			hdr.SetMode(0o644)
+		case Dir:
+			hdr.Name += "/"
+			hdr.SetMode(fs.ModeDir | 0o755)
+		case Symlink:
+			hdr.SetMode(fs.ModeSymlink | 0o777)
+		}
+		fw, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return fmt.Errorf("can't write archive: %w", err)
+		}
+		switch ent.Type {
+		case File:
+			if err := copyContent(fw, ent); err != nil {
+				return &WalkError{Path: ent.Path, Err: err}
+			}
+		case Symlink:
+			if _, err := io.WriteString(fw, ent.LinkTarget); err != nil {
+				return fmt.Errorf("can't write archive: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("dirtree: can't write archive: %w", err)
+	}
+	return nil
+}
+
+// WriteZip walks the directory rooted at root and writes into w a zip archive
+// of the listed files. See WriteZipFS.
+func WriteZip(w io.Writer, root string, opts ...Option) error {
+	return WriteZipFS(w, nil, root, opts...)
+}
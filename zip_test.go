@@ -0,0 +1,79 @@
+package dirtree
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestWriteZip(t *testing.T) {
+	fsys := fstest.MapFS{
+		"A/file1":    &fstest.MapFile{Data: []byte("dummy"), Mode: 0o600, ModTime: time.Now()},
+		"A/B/file2":  &fstest.MapFile{Data: []byte("content")},
+		"A/file.tmp": &fstest.MapFile{Data: []byte("tmp")},
+		"link":       &fstest.MapFile{Data: []byte("A/file1"), Mode: fs.ModeSymlink},
+	}
+
+	var buf1, buf2 bytes.Buffer
+	if err := WriteZipFS(&buf1, fsys, ".", Ignore("*/*.tmp")); err != nil {
+		t.Fatal(err)
+	}
+	fsys["A/file1"].ModTime = time.Now().Add(time.Hour)
+	if err := WriteZipFS(&buf2, fsys, ".", Ignore("*/*.tmp"), ModeAll); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf1.Bytes(), buf2.Bytes()) {
+		t.Errorf("archives of the same tree differ")
+	}
+
+	ents, err := ListFS(fsys, ".", ModeCRC32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crcs := make(map[string]string)
+	for _, e := range ents {
+		crcs[e.RelPath] = e.Checksum
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf1.Bytes()), int64(buf1.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !f.Modified.Equal(zipEpoch) {
+			t.Errorf("%s: modification time not normalized: %v", f.Name, f.Modified)
+		}
+		if f.Mode().IsRegular() {
+			if crc := fmt.Sprintf("%08x", f.CRC32); crc != crcs[f.Name] {
+				t.Errorf("%s: crc32 = %s, want %s", f.Name, crc, crcs[f.Name])
+			}
+		}
+		got = append(got, f.Name+" "+f.Mode().String()+" "+string(data))
+	}
+	want := []string{
+		"A/ drwxr-xr-x ",
+		"A/B/ drwxr-xr-x ",
+		"A/B/file2 -rw-r--r-- content",
+		"A/file1 -rw-r--r-- dummy",
+		"link Lrwxrwxrwx A/file1",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}